@@ -0,0 +1,321 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Secret 保存密码、令牌等敏感信息，打印或编码为JSON时会被隐藏
+type Secret string
+
+// String 实现 fmt.Stringer，避免凭据出现在日志中
+func (s Secret) String() string {
+	return "******"
+}
+
+// MarshalJSON 避免凭据被写入结果文件
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal("******")
+}
+
+// LoadSecret 按来源读取凭据，支持 "env:变量名" 和 "file:文件路径"。
+// 不接受明文，避免凭据出现在命令行历史和进程列表中
+func LoadSecret(source string) (Secret, error) {
+	kind, value, ok := strings.Cut(source, ":")
+	if !ok {
+		return "", fmt.Errorf("凭据来源必须是 env:NAME 或 file:PATH")
+	}
+
+	switch kind {
+	case "env":
+		secret, ok := os.LookupEnv(value)
+		if !ok {
+			return "", fmt.Errorf("环境变量 %s 未设置", value)
+		}
+		return Secret(secret), nil
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("读取凭据文件失败: %w", err)
+		}
+		return Secret(strings.TrimRight(string(data), "\r\n")), nil
+	default:
+		return "", fmt.Errorf("未知的凭据来源 %q", kind)
+	}
+}
+
+// Authenticator 为请求添加认证信息
+type Authenticator interface {
+	// 是否对该主机生效
+	Matches(host string) bool
+
+	// 为请求添加认证信息
+	Apply(req *http.Request) error
+}
+
+// ReAuthenticator 是需要登录会话的认证方式，会话失效时可以重新登录
+type ReAuthenticator interface {
+	Authenticator
+
+	// 判断响应是否表示需要重新登录，例如被重定向到登录页
+	NeedsLogin(resp *http.Response) bool
+
+	// 执行登录，获取新的会话
+	Login(ctx context.Context, client *http.Client) error
+}
+
+// hostMatcher 按主机名匹配（忽略端口），支持 "*.example.com" 通配子域名
+type hostMatcher []string
+
+// Matches 检查主机是否匹配
+func (m hostMatcher) Matches(host string) bool {
+	host = hostWithoutPort(host)
+
+	for _, pattern := range m {
+		pattern = hostWithoutPort(pattern)
+		if pattern == host || pattern == "*" {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostWithoutPort 去掉主机的端口并转换为小写，支持 [::1]:8080 形式的IPv6地址。
+// 没有端口时返回原来的主机，IPv6地址去掉方括号
+func hostWithoutPort(host string) string {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// authIdentity 返回认证方式代表的身份，不含密码；令牌只取哈希的前几个字节
+func authIdentity(auth Authenticator) string {
+	switch a := auth.(type) {
+	case *BasicAuth:
+		return "basic " + a.Username
+	case *BearerAuth:
+		sum := sha256.Sum256([]byte(a.Token))
+		return "bearer " + hex.EncodeToString(sum[:4])
+	case *FormLogin:
+		return "form " + a.LoginURL + " " + a.Username
+	}
+	return fmt.Sprintf("%T", auth)
+}
+
+// BasicAuth HTTP Basic 认证
+type BasicAuth struct {
+	hostMatcher
+	Username string
+	Password Secret
+}
+
+// NewBasicAuth 创建对指定主机生效的 Basic 认证
+func NewBasicAuth(hosts []string, username string, password Secret) *BasicAuth {
+	return &BasicAuth{hostMatcher: hosts, Username: username, Password: password}
+}
+
+// Apply 设置 Authorization 头
+func (a *BasicAuth) Apply(req *http.Request) error {
+	req.SetBasicAuth(a.Username, string(a.Password))
+	return nil
+}
+
+// BearerAuth 静态 Bearer 令牌认证
+type BearerAuth struct {
+	hostMatcher
+	Token Secret
+}
+
+// NewBearerAuth 创建对指定主机生效的 Bearer 令牌认证
+func NewBearerAuth(hosts []string, token Secret) *BearerAuth {
+	return &BearerAuth{hostMatcher: hosts, Token: token}
+}
+
+// Apply 设置 Authorization 头
+func (a *BearerAuth) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(a.Token))
+	return nil
+}
+
+// FormLogin 通过提交登录表单获取会话Cookie的认证方式
+type FormLogin struct {
+	hostMatcher
+
+	// 登录表单的提交地址
+	LoginURL string
+
+	// 表单字段名和凭据
+	UsernameField string
+	PasswordField string
+	Username      string
+	Password      Secret
+
+	// 额外提交的表单字段，如 remember=1
+	ExtraFields map[string]string
+
+	// 登录后获得的会话Cookie
+	cookies []*http.Cookie
+	mu      sync.RWMutex
+}
+
+// NewFormLogin 创建表单登录认证
+func NewFormLogin(hosts []string, loginURL, username string, password Secret) *FormLogin {
+	return &FormLogin{
+		hostMatcher:   hosts,
+		LoginURL:      loginURL,
+		UsernameField: "username",
+		PasswordField: "password",
+		Username:      username,
+		Password:      password,
+	}
+}
+
+// Apply 为请求附加会话Cookie
+func (a *FormLogin) Apply(req *http.Request) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, cookie := range a.cookies {
+		req.AddCookie(cookie)
+	}
+	return nil
+}
+
+// NeedsLogin 判断响应是否被重定向到登录页，或者返回了401
+func (a *FormLogin) NeedsLogin(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+
+	login, err := url.Parse(a.LoginURL)
+	if err != nil || resp.Request == nil || resp.Request.URL == nil {
+		return false
+	}
+	final := resp.Request.URL
+	return final.Host == login.Host && final.Path == login.Path
+}
+
+// Login 提交登录表单并保存响应中的会话Cookie
+func (a *FormLogin) Login(ctx context.Context, client *http.Client) error {
+	form := url.Values{}
+	form.Set(a.UsernameField, a.Username)
+	form.Set(a.PasswordField, string(a.Password))
+	for k, v := range a.ExtraFields {
+		form.Set(k, v)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.LoginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("创建登录请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// 不跟随重定向，直接从登录响应中取出Set-Cookie
+	loginClient := *client
+	loginClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := loginClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("登录请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("登录失败，HTTP状态码: %d", resp.StatusCode)
+	}
+
+	cookies := resp.Cookies()
+	if len(cookies) == 0 && client.Jar == nil {
+		return fmt.Errorf("登录响应中没有会话Cookie")
+	}
+
+	a.mu.Lock()
+	a.cookies = cookies
+	a.mu.Unlock()
+
+	return nil
+}
+
+// ParseAuthenticator 解析命令行中的认证配置，格式为 "类型:键=值,键=值"，例如：
+//
+//	basic:host=intranet.local,user=alice,password=env:INTRANET_PASSWORD
+//	bearer:host=*.api.example.com,token=file:/run/secrets/token
+//	form:host=docs.local,url=https://docs.local/login,user=alice,password=env:DOCS_PASSWORD
+//
+// host 可以用 | 分隔多个主机，password 和 token 只接受 env: 或 file: 来源
+func ParseAuthenticator(spec string) (Authenticator, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("无效的认证配置: 缺少类型")
+	}
+
+	params := make(map[string]string)
+	for _, item := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的认证参数 %q", item)
+		}
+		params[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	if params["host"] == "" {
+		return nil, fmt.Errorf("认证配置缺少 host")
+	}
+	hosts := strings.Split(params["host"], "|")
+
+	secret := func(key string) (Secret, error) {
+		if params[key] == "" {
+			return "", fmt.Errorf("认证配置缺少 %s", key)
+		}
+		return LoadSecret(params[key])
+	}
+
+	switch kind {
+	case "basic":
+		password, err := secret("password")
+		if err != nil {
+			return nil, err
+		}
+		return NewBasicAuth(hosts, params["user"], password), nil
+	case "bearer":
+		token, err := secret("token")
+		if err != nil {
+			return nil, err
+		}
+		return NewBearerAuth(hosts, token), nil
+	case "form":
+		password, err := secret("password")
+		if err != nil {
+			return nil, err
+		}
+		if params["url"] == "" {
+			return nil, fmt.Errorf("认证配置缺少 url")
+		}
+		login := NewFormLogin(hosts, params["url"], params["user"], password)
+		if params["user-field"] != "" {
+			login.UsernameField = params["user-field"]
+		}
+		if params["password-field"] != "" {
+			login.PasswordField = params["password-field"]
+		}
+		return login, nil
+	default:
+		return nil, fmt.Errorf("未知的认证类型 %q", kind)
+	}
+}
@@ -0,0 +1,32 @@
+package core
+
+import "testing"
+
+func TestHostMatcherMatches(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"example.com"}, "example.com", true},
+		{[]string{"example.com"}, "EXAMPLE.com:8080", true},
+		{[]string{"example.com:443"}, "example.com", true},
+		{[]string{"example.com"}, "other.com", false},
+		{[]string{"*.example.com"}, "api.example.com:8443", true},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*"}, "anything.test", true},
+
+		// IPv6地址：带端口时有方括号，冒号不是端口分隔符
+		{[]string{"::1"}, "[::1]:8080", true},
+		{[]string{"[::1]"}, "[::1]", true},
+		{[]string{"[::1]:80"}, "::1", true},
+		{[]string{"2001:db8::1"}, "[2001:db8::1]:443", true},
+		{[]string{"2001:db8::1"}, "[2001:db8::2]:443", false},
+		{[]string{"2001"}, "[2001:db8::1]:443", false},
+	}
+	for _, tt := range tests {
+		if got := hostMatcher(tt.patterns).Matches(tt.host); got != tt.want {
+			t.Errorf("%v.Matches(%q) = %v，期望 %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
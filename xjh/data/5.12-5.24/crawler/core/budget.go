@@ -0,0 +1,135 @@
+package core
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// 预算耗尽时 Engine.Start 返回的错误，用于说明是哪个限制结束了爬取
+var (
+	ErrMaxPagesReached = errors.New("已达到最大页面数限制")
+	ErrMaxBytesReached = errors.New("已达到最大下载字节数限制")
+)
+
+// 单个URL因预算被跳过的原因
+const (
+	BudgetHostCap   = "host_cap"
+	BudgetPrefixCap = "prefix_cap"
+
+	// 持续运行模式下全局预算耗尽后，新发现的URL被跳过
+	BudgetExhausted = "exhausted"
+)
+
+// CrawlBudget 爬取预算，在所有worker之间原子地统计页面数和字节数
+type CrawlBudget struct {
+	// 全局最大页面数，0表示不限制
+	maxPages int64
+
+	// 全局最大下载字节数，0表示不限制
+	maxBytes int64
+
+	// 每个主机的最大页面数，0表示不限制
+	maxPagesPerHost int64
+
+	// 路径前缀（主机+路径，如 example.com/docs）-> 最大页面数
+	prefixCaps map[string]int64
+
+	pages       int64
+	bytes       int64
+	hostPages   map[string]int64
+	prefixPages map[string]int64
+	mu          sync.Mutex
+}
+
+// NewCrawlBudget 根据配置选项创建爬取预算
+func NewCrawlBudget(options *Options) *CrawlBudget {
+	prefixCaps := make(map[string]int64, len(options.PathPrefixCaps))
+	for prefix, limit := range options.PathPrefixCaps {
+		prefixCaps[prefix] = int64(limit)
+	}
+
+	return &CrawlBudget{
+		maxPages:        int64(options.MaxPages),
+		maxBytes:        options.MaxBytes,
+		maxPagesPerHost: int64(options.MaxPagesPerHost),
+		prefixCaps:      prefixCaps,
+		hostPages:       make(map[string]int64),
+		prefixPages:     make(map[string]int64),
+	}
+}
+
+// Reserve 为即将抓取的URL预留一个页面额度。
+// 全局预算耗尽时返回对应错误；URL所在主机或路径前缀超限时返回跳过原因
+func (b *CrawlBudget) Reserve(rawURL string) (string, error) {
+	host, path := rawURL, ""
+	if u, err := url.Parse(rawURL); err == nil {
+		host, path = u.Host, u.Path
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.exhausted(); err != nil {
+		return "", err
+	}
+
+	if b.maxPagesPerHost > 0 && b.hostPages[host] >= b.maxPagesPerHost {
+		return BudgetHostCap, nil
+	}
+
+	// 找出所有匹配的路径前缀，任何一个超限都跳过
+	var matched []string
+	for prefix, limit := range b.prefixCaps {
+		if hasPathPrefix(host+path, prefix) {
+			if b.prefixPages[prefix] >= limit {
+				return BudgetPrefixCap, nil
+			}
+			matched = append(matched, prefix)
+		}
+	}
+
+	b.pages++
+	b.hostPages[host]++
+	for _, prefix := range matched {
+		b.prefixPages[prefix]++
+	}
+	return "", nil
+}
+
+// hasPathPrefix 按路径段检查前缀，example.com/blog 匹配 example.com/blog 和 example.com/blog/post，
+// 不匹配 example.com/blogroll
+func hasPathPrefix(target, prefix string) bool {
+	if !strings.HasPrefix(target, prefix) {
+		return false
+	}
+	return len(target) == len(prefix) || strings.HasSuffix(prefix, "/") || target[len(prefix)] == '/'
+}
+
+// AddBytes 累加已下载的字节数
+func (b *CrawlBudget) AddBytes(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bytes += n
+}
+
+// Exhausted 检查全局预算是否已耗尽，未耗尽时返回nil
+func (b *CrawlBudget) Exhausted() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.exhausted()
+}
+
+// exhausted 检查全局预算，调用方需持有锁
+func (b *CrawlBudget) exhausted() error {
+	if b.maxPages > 0 && b.pages >= b.maxPages {
+		return ErrMaxPagesReached
+	}
+	if b.maxBytes > 0 && b.bytes >= b.maxBytes {
+		return ErrMaxBytesReached
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ContentRetention 页面内容在解析之后的保留方式
+type ContentRetention int
+
+const (
+	// ContentDiscard 解析后丢弃页面内容（默认）
+	ContentDiscard ContentRetention = iota
+
+	// ContentCompressed 把页面内容用gzip压缩后交给存储，存储需要实现 ContentStorage
+	ContentCompressed
+
+	// ContentRawStore 把页面保存到原始页面存储，需要通过 Engine.SetPageStore 设置
+	ContentRawStore
+)
+
+// ContentGzip StoreContent 中表示内容已用gzip压缩的编码名
+const ContentGzip = "gzip"
+
+// maxPooledBuffer 放回缓冲池的缓冲区的最大容量，更大的缓冲区交给GC回收
+const maxPooledBuffer = 4 * 1024 * 1024
+
+// String 返回保留方式的名称
+func (r ContentRetention) String() string {
+	switch r {
+	case ContentDiscard:
+		return "discard"
+	case ContentCompressed:
+		return "compress"
+	case ContentRawStore:
+		return "store"
+	}
+	return fmt.Sprintf("ContentRetention(%d)", int(r))
+}
+
+// ParseContentRetention 解析保留方式的名称：discard、compress 或 store
+func ParseContentRetention(name string) (ContentRetention, error) {
+	for _, r := range []ContentRetention{ContentDiscard, ContentCompressed, ContentRawStore} {
+		if r.String() == name {
+			return r, nil
+		}
+	}
+	return ContentDiscard, fmt.Errorf("未知的内容保留方式: %s", name)
+}
+
+// CompressContent 用gzip压缩页面内容
+func CompressContent(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(content); err != nil {
+		return nil, fmt.Errorf("压缩内容失败: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("压缩内容失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeContent 按编码还原 StoreContent 收到的页面内容
+func DecodeContent(content []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return content, nil
+	case ContentGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("解压内容失败: %w", err)
+		}
+		defer reader.Close()
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("解压内容失败: %w", err)
+		}
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("不支持的内容编码: %s", encoding)
+}
+
+// BufferPool 复用读取响应体的缓冲区，减少高并发爬取时的内存分配和GC压力
+type BufferPool struct {
+	pool sync.Pool
+}
+
+// NewBufferPool 创建缓冲池
+func NewBufferPool() *BufferPool {
+	return &BufferPool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return new(bytes.Buffer)
+			},
+		},
+	}
+}
+
+// Get 取出一个空的缓冲区
+func (p *BufferPool) Get() *bytes.Buffer {
+	buf := p.pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// Put 放回缓冲区，之后不能再使用其中的内容。过大的缓冲区不会放回
+func (p *BufferPool) Put(buf *bytes.Buffer) {
+	if p == nil || buf == nil || buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	p.pool.Put(buf)
+}
@@ -0,0 +1,459 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContentHashField 结果中记录页面内容哈希的字段名
+const ContentHashField = "content_hash"
+
+// 比较结果时忽略的字段，这些字段每次爬取都会变化，或者只记录结果的来源
+var volatileFields = map[string]bool{
+	ContentHashField: true,
+	ParserField:      true,
+	"timestamp":      true,
+	"length":         true,
+	"simhash":        true,
+	"duplicate_of":   true,
+}
+
+// ContentHash 计算页面的归一化内容哈希：只取可见文本，合并空白并转为小写，
+// 因此脚本、样式和排版的变化不会被当作内容变化
+func ContentHash(content []byte) string {
+	text := strings.ToLower(VisibleText(content))
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHasher 以流的方式计算 ContentHash，写入页面内容后调用 Sum 得到相同的哈希。
+// 与 VisibleText 一样分两步处理：先去掉脚本、样式和注释，再去掉标签。
+// 只缓冲尚未闭合的脚本、样式、注释和标签，没有闭合时按 VisibleText 的规则作为文本处理
+type ContentHasher struct {
+	hash hash.Hash
+	size int64
+
+	// 第一步：从 < 开始、尚未确定是否是脚本、样式或注释的内容
+	invisible []byte
+
+	// 已识别的开头（<script、<style 或 <!--）对应的结束标记，为空时还在识别开头；
+	// 脚本和样式的开始标签结束后 body 为内容的起始位置，之前为-1
+	rawEnd string
+	body   int
+
+	// 第二步：从 < 开始、尚未遇到 > 的标签
+	tag   []byte
+	inTag bool
+
+	// 当前的词，以及已写入的词数
+	word    []byte
+	words   int
+	partial bool
+}
+
+// 第一步识别的开头及对应的结束标记
+var invisibleBlocks = []struct{ open, end string }{
+	{"<script", "</script>"},
+	{"<style", "</style>"},
+	{"<!--", "-->"},
+}
+
+// maxWordSize 单个词的最大缓冲长度，超出时先写入哈希
+const maxWordSize = 64 * 1024
+
+// NewContentHasher 创建流式内容哈希计算器
+func NewContentHasher() *ContentHasher {
+	return &ContentHasher{hash: sha256.New(), body: -1}
+}
+
+// Write 实现 io.Writer 接口
+func (h *ContentHasher) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+	h.stripInvisible(p)
+	return len(p), nil
+}
+
+// stripInvisible 第一步：去掉脚本、样式和注释，其余内容交给 stripTags
+func (h *ContentHasher) stripInvisible(p []byte) {
+	// 待扫描的内容，重新扫描的缓冲内容压在后面，先于剩余的输入处理
+	pending := [][]byte{p}
+next:
+	for len(pending) > 0 {
+		chunk := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for i, c := range chunk {
+			if len(h.invisible) == 0 {
+				if c == '<' {
+					h.invisible = append(h.invisible, c)
+				} else {
+					h.stripTags(c)
+				}
+				continue
+			}
+			h.invisible = append(h.invisible, asciiLower(c))
+
+			switch {
+			case h.rawEnd == "":
+				// 识别开头，不可能匹配任何开头时从 < 之后重新扫描
+				if !h.matchOpen() {
+					pending = append(pending, chunk[i+1:], h.reject())
+					continue next
+				}
+			case h.body < 0:
+				// 脚本和样式的开始标签
+				if c == '>' {
+					h.body = len(h.invisible)
+				}
+			case len(h.invisible)-h.body >= len(h.rawEnd) && bytes.HasSuffix(h.invisible, []byte(h.rawEnd)):
+				// 整块内容替换为一个空格
+				h.invisible, h.rawEnd, h.body = h.invisible[:0], "", -1
+				h.stripTags(' ')
+			}
+		}
+	}
+}
+
+// matchOpen 检查缓冲的内容是否仍可能是脚本、样式或注释的开头，完整识别时记录结束标记
+func (h *ContentHasher) matchOpen() bool {
+	for _, block := range invisibleBlocks {
+		n := len(h.invisible)
+		if n > len(block.open) || string(h.invisible) != block.open[:n] {
+			continue
+		}
+		if n == len(block.open) {
+			h.rawEnd = block.end
+			if block.open == "<!--" {
+				h.body = n
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// reject 缓冲的 < 不是脚本、样式或注释的开始（或到结尾都没有闭合）：
+// 把 < 作为普通内容交给下一步，返回它之后需要重新扫描的内容。
+// 缓冲中保存的是小写形式，不影响结果：第一步的匹配不区分大小写，最终的文本也会转为小写
+func (h *ContentHasher) reject() []byte {
+	buffered := bytes.Clone(h.invisible[1:])
+	h.invisible, h.rawEnd, h.body = h.invisible[:0], "", -1
+	h.stripTags('<')
+	return buffered
+}
+
+// stripTags 第二步：去掉标签，其余内容交给 addText
+func (h *ContentHasher) stripTags(c byte) {
+	switch {
+	case h.inTag && c == '>':
+		h.tag, h.inTag = h.tag[:0], false
+		h.addText(' ')
+	case h.inTag:
+		h.tag = append(h.tag, c)
+	case c == '<':
+		h.tag, h.inTag = append(h.tag[:0], c), true
+	default:
+		h.addText(c)
+	}
+}
+
+// addText 按空白分词，把可见文本写入哈希
+func (h *ContentHasher) addText(c byte) {
+	if c == ' ' || (c >= '\t' && c <= '\r') {
+		h.flushWord()
+		return
+	}
+	h.word = append(h.word, c)
+	if len(h.word) >= maxWordSize {
+		h.flushPartial()
+	}
+}
+
+// finish 处理结尾没有闭合的内容：没有结束标记的脚本、样式和注释从 < 之后重新扫描，
+// 没有 > 的标签作为文本
+func (h *ContentHasher) finish() {
+	for len(h.invisible) > 0 {
+		h.stripInvisible(h.reject())
+	}
+	if h.inTag {
+		// 之后没有 >，其中的 < 也不会开始新的标签
+		tag := h.tag
+		h.tag, h.inTag = nil, false
+		for _, c := range tag {
+			h.addText(c)
+		}
+	}
+	h.flushWord()
+}
+
+// asciiLower 把ASCII大写字母转为小写
+func asciiLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// flushWord 把当前的词转为小写写入哈希，词之间用一个空格分隔
+func (h *ContentHasher) flushWord() {
+	if len(h.word) == 0 {
+		h.partial = false
+		return
+	}
+	for i, w := range strings.Fields(string(h.word)) {
+		if h.words > 0 && !(i == 0 && h.partial) {
+			h.hash.Write([]byte{' '})
+		}
+		h.hash.Write([]byte(strings.ToLower(w)))
+		h.words++
+	}
+	h.word = h.word[:0]
+	h.partial = false
+}
+
+// flushPartial 过长的词在字符边界处先写入一部分，之后的部分紧接着写入
+func (h *ContentHasher) flushPartial() {
+	cut := len(h.word)
+	for cut > 0 && !utf8.RuneStart(h.word[cut-1]) {
+		cut--
+	}
+	if cut > 0 && !utf8.FullRune(h.word[cut-1:]) {
+		cut--
+	}
+	if cut <= 0 {
+		return
+	}
+
+	rest := bytes.Clone(h.word[cut:])
+	h.word = h.word[:cut]
+	partial := h.partial
+	if h.words > 0 && !partial {
+		h.hash.Write([]byte{' '})
+	}
+	h.hash.Write(bytes.ToLower(h.word))
+	h.words++
+	h.word = append(h.word[:0], rest...)
+	h.partial = true
+}
+
+// Size 返回已写入的字节数
+func (h *ContentHasher) Size() int64 {
+	return h.size
+}
+
+// Sum 返回内容哈希，与对全部内容调用 ContentHash 的结果相同。调用后不能再写入
+func (h *ContentHasher) Sum() string {
+	h.finish()
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// FieldDiff 一个提取字段的变化
+type FieldDiff struct {
+	// 结果类型及其在页面结果中的序号
+	Type  string
+	Index int
+
+	// 字段名
+	Field string
+
+	// 按行的文本差异，每行以 "-"、"+" 或 " " 开头
+	Lines []string
+}
+
+// PageChange 一个内容发生变化的URL
+type PageChange struct {
+	URL     string
+	OldHash string
+	NewHash string
+	Fields  []FieldDiff
+}
+
+// CrawlDiff 两次爬取结果的比较报告
+type CrawlDiff struct {
+	New       []string
+	Removed   []string
+	Changed   []PageChange
+	Unchanged []string
+}
+
+// CompareCrawls 比较两次爬取的结果，按URL报告新增、删除、变化和未变化的页面。
+// 有内容哈希时以哈希判断是否变化，否则比较提取出的字段
+func CompareCrawls(oldResults, newResults map[string][]Result) *CrawlDiff {
+	diff := &CrawlDiff{}
+
+	for url, newPage := range newResults {
+		oldPage, ok := oldResults[url]
+		if !ok {
+			diff.New = append(diff.New, url)
+			continue
+		}
+
+		oldHash, newHash := pageHash(oldPage), pageHash(newPage)
+		fields := diffResults(oldPage, newPage)
+
+		changed := len(fields) > 0
+		if oldHash != "" && newHash != "" {
+			changed = oldHash != newHash
+		}
+
+		if changed {
+			diff.Changed = append(diff.Changed, PageChange{
+				URL:     url,
+				OldHash: oldHash,
+				NewHash: newHash,
+				Fields:  fields,
+			})
+		} else {
+			diff.Unchanged = append(diff.Unchanged, url)
+		}
+	}
+
+	for url := range oldResults {
+		if _, ok := newResults[url]; !ok {
+			diff.Removed = append(diff.Removed, url)
+		}
+	}
+
+	sort.Strings(diff.New)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Unchanged)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].URL < diff.Changed[j].URL
+	})
+
+	return diff
+}
+
+// pageHash 返回页面结果中记录的内容哈希
+func pageHash(results []Result) string {
+	for _, result := range results {
+		if hash, ok := result.Data[ContentHashField].(string); ok {
+			return hash
+		}
+	}
+	return ""
+}
+
+// diffResults 按序号逐个比较两组结果的字段
+func diffResults(oldResults, newResults []Result) []FieldDiff {
+	var diffs []FieldDiff
+
+	n := len(oldResults)
+	if len(newResults) > n {
+		n = len(newResults)
+	}
+
+	for i := 0; i < n; i++ {
+		var oldData, newData map[string]interface{}
+		typ := ""
+		if i < len(oldResults) {
+			oldData, typ = oldResults[i].Data, oldResults[i].Type
+		}
+		if i < len(newResults) {
+			newData, typ = newResults[i].Data, newResults[i].Type
+		}
+
+		// 收集两边出现过的字段
+		fields := make(map[string]bool)
+		for k := range oldData {
+			fields[k] = true
+		}
+		for k := range newData {
+			fields[k] = true
+		}
+		names := make([]string, 0, len(fields))
+		for k := range fields {
+			if !volatileFields[k] {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			oldText, newText := fieldText(oldData, name), fieldText(newData, name)
+			if oldText == newText {
+				continue
+			}
+			diffs = append(diffs, FieldDiff{
+				Type:  typ,
+				Index: i,
+				Field: name,
+				Lines: LineDiff(oldText, newText),
+			})
+		}
+	}
+
+	return diffs
+}
+
+// fieldText 把字段值转为文本
+func fieldText(data map[string]interface{}, name string) string {
+	v, ok := data[name]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// LineDiff 基于最长公共子序列按行比较两段文本，
+// 返回的每行以 "-"（删除）、"+"（新增）或 " "（未变）开头
+func LineDiff(oldText, newText string) []string {
+	a, b := strings.Split(oldText, "\n"), strings.Split(newText, "\n")
+	if oldText == "" {
+		a = nil
+	}
+	if newText == "" {
+		b = nil
+	}
+
+	// lcs[i][j] 表示 a[i:] 和 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+"+b[j])
+	}
+
+	return lines
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// streamHash 把内容按 chunk 字节分块写入 ContentHasher，chunk 为0时一次写入
+func streamHash(content string, chunk int) string {
+	h := NewContentHasher()
+	if chunk <= 0 {
+		chunk = len(content) + 1
+	}
+	for len(content) > 0 {
+		n := chunk
+		if n > len(content) {
+			n = len(content)
+		}
+		h.Write([]byte(content[:n]))
+		content = content[n:]
+	}
+	return h.Sum()
+}
+
+// checkContentHasher 检查各种分块方式下 ContentHasher 与 ContentHash 的结果相同
+func checkContentHasher(t *testing.T, content string) {
+	t.Helper()
+	want := ContentHash([]byte(content))
+	for _, chunk := range []int{0, 1, 2, 3, 7, 64} {
+		if got := streamHash(content, chunk); got != want {
+			t.Errorf("分块 %d 时 ContentHasher 与 ContentHash 不同，内容: %q，可见文本: %q",
+				chunk, content, VisibleText([]byte(content)))
+			return
+		}
+	}
+}
+
+func TestContentHasherMatchesContentHash(t *testing.T) {
+	inputs := []string{
+		"",
+		"plain text",
+		"a < b and c",
+		"a > b",
+		"x <b>bold</b> y",
+		"<p>Hello,   World</p>\n<p>Second\tline</p>",
+		"<html><head><title>T</title><style>p{color:red}</style></head><body>Body</body></html>",
+		"<SCRIPT type=\"text/javascript\">var a = '<p>';</SCRIPT>after",
+		"before<script>never closed <b>bold</b> text",
+		"before<style>never closed",
+		"<!-- comment -->visible<!-- another -->",
+		"<!-- unterminated comment > still here",
+		"<!---->empty comment<!-->odd-->",
+		"<!-- a --->b",
+		"<scriptx>x</script>y",
+		"<scr ipt>not a script</scr>",
+		"<a title=\"<script>\">x</script> tail",
+		"<a <!-- c --> href=x>link</a>",
+		"<div>unclosed tag <span",
+		"<<script>>x</script>>",
+		"<script><script>x</script></script>y",
+		"<script>a<!-- b</script>c-->d",
+		"<style>a</STYLE >b</style>c",
+		"Ünïcödé <b>TEXT</b> non-breaking",
+		strings.Repeat("word ", 1000) + "<script>" + strings.Repeat("x", 5000),
+		strings.Repeat("long", 20000) + " tail",
+	}
+	for _, input := range inputs {
+		checkContentHasher(t, input)
+	}
+}
+
+func TestContentHasherRandomHTML(t *testing.T) {
+	// 由容易组成标签、注释和脚本的片段随机拼接
+	pieces := []string{
+		"<", ">", "/", "!", "-", "--", "<!--", "-->", "<script", "</script>", "<style", "</STYLE>",
+		"<p>", "</p>", " ", "\n", "a", "B", "text", "x=\"<\"", "<b", "é",
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		var b strings.Builder
+		for n := rng.Intn(30); n > 0; n-- {
+			b.WriteString(pieces[rng.Intn(len(pieces))])
+		}
+		checkContentHasher(t, b.String())
+	}
+}
+
+func TestContentHasherSize(t *testing.T) {
+	content := "<p>hello</p><script>x"
+	h := NewContentHasher()
+	h.Write([]byte(content))
+	if h.Size() != int64(len(content)) {
+		t.Errorf("Size() = %d，期望 %d", h.Size(), len(content))
+	}
+}
@@ -0,0 +1,299 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// NodeType DOM节点的类型
+type NodeType int
+
+const (
+	// DocumentNode 文档根节点
+	DocumentNode NodeType = iota
+
+	// ElementNode 元素
+	ElementNode
+
+	// TextNode 文本
+	TextNode
+
+	// CommentNode 注释
+	CommentNode
+
+	// AttributeNode XPath选中的属性，Data 为属性名，Attrs 中只有这一个属性，Parent 为所属元素
+	AttributeNode
+)
+
+// Node HTML文档树中的节点
+type Node struct {
+	Type NodeType
+
+	// 元素的标签名（小写）、文本或注释内容，文本中的字符实体已解码
+	Data string
+
+	// 元素的属性，属性值中的字符实体已解码
+	Attrs []Attribute
+
+	Parent, FirstChild, LastChild, PrevSibling, NextSibling *Node
+}
+
+// voidElements 没有结束标签的元素
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// impliedEnd 开始标签隐式结束的元素：close 中最近的打开元素会被结束，
+// 向上查找时遇到 scope 中的元素就停止
+type impliedEnd struct {
+	close []string
+	scope []string
+}
+
+// blockScope 段落不会越过的元素
+var blockScope = []string{"button", "td", "th", "li", "dd", "dt", "table", "caption", "body", "html"}
+
+var impliedEnds = map[string]impliedEnd{
+	"li":     {[]string{"li"}, []string{"ul", "ol", "menu"}},
+	"dt":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr":     {[]string{"tr"}, []string{"table", "tbody", "thead", "tfoot"}},
+	"td":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"tbody":  {[]string{"tbody", "thead", "tfoot"}, []string{"table"}},
+	"thead":  {[]string{"tbody", "thead", "tfoot"}, []string{"table"}},
+	"tfoot":  {[]string{"tbody", "thead", "tfoot"}, []string{"table"}},
+	"option": {[]string{"option"}, []string{"select", "datalist", "optgroup"}},
+}
+
+// closesParagraph 会结束打开的段落的块级元素
+var closesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true, "dl": true,
+	"fieldset": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+// ParseHTML 把HTML文档解析为节点树，返回文档根节点。
+// 容错处理常见的不规范写法：省略的结束标签（li、p、td等）和多余的结束标签
+func ParseHTML(r io.Reader) (*Node, error) {
+	doc := &Node{Type: DocumentNode}
+	b := &treeBuilder{stack: []*Node{doc}}
+
+	tokenizer := NewTokenizer(r)
+	for {
+		token, err := tokenizer.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取页面失败: %w", err)
+		}
+		b.add(token)
+	}
+	b.flushText()
+
+	return doc, nil
+}
+
+// treeBuilder 由词法单元构建节点树
+type treeBuilder struct {
+	// 打开的元素，第一个是文档根节点
+	stack []*Node
+
+	// 尚未加入树的文本，词法分析器会把长文本拆开，合并后再解码字符实体
+	text strings.Builder
+}
+
+// current 返回当前打开的元素
+func (b *treeBuilder) current() *Node {
+	return b.stack[len(b.stack)-1]
+}
+
+// add 把一个词法单元加入树
+func (b *treeBuilder) add(token Token) {
+	if token.Type == TextToken {
+		b.text.WriteString(token.Data)
+		return
+	}
+	b.flushText()
+
+	switch token.Type {
+	case StartTagToken, SelfClosingTagToken:
+		if end, ok := impliedEnds[token.Data]; ok {
+			b.closeImplied(end.close, end.scope)
+		}
+		if closesParagraph[token.Data] {
+			b.closeImplied([]string{"p"}, blockScope)
+		}
+
+		attrs := make([]Attribute, len(token.Attrs))
+		for i, a := range token.Attrs {
+			attrs[i] = Attribute{Key: a.Key, Val: html.UnescapeString(a.Val)}
+		}
+		node := &Node{Type: ElementNode, Data: token.Data, Attrs: attrs}
+		b.current().AppendChild(node)
+
+		if token.Type == StartTagToken && !voidElements[token.Data] {
+			b.stack = append(b.stack, node)
+		}
+	case EndTagToken:
+		// 结束最近的同名元素，没有打开的同名元素时忽略
+		for i := len(b.stack) - 1; i > 0; i-- {
+			if b.stack[i].Data == token.Data {
+				b.stack = b.stack[:i]
+				break
+			}
+		}
+	case CommentToken:
+		b.current().AppendChild(&Node{Type: CommentNode, Data: token.Data})
+	}
+}
+
+// flushText 把累积的文本加入当前元素，script 和 style 中的文本不解码
+func (b *treeBuilder) flushText() {
+	if b.text.Len() == 0 {
+		return
+	}
+	data := b.text.String()
+	b.text.Reset()
+
+	if parent := b.current(); !isRawTextElement(parent) {
+		data = html.UnescapeString(data)
+	}
+	b.current().AppendChild(&Node{Type: TextNode, Data: data})
+}
+
+// closeImplied 结束 close 中最近的打开元素，遇到 scope 中的元素时停止
+func (b *treeBuilder) closeImplied(close, scope []string) {
+	for i := len(b.stack) - 1; i > 0; i-- {
+		tag := b.stack[i].Data
+		if containsString(close, tag) {
+			b.stack = b.stack[:i]
+			return
+		}
+		if containsString(scope, tag) {
+			return
+		}
+	}
+}
+
+// containsString 检查字符串是否在列表中
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isRawTextElement 检查节点是否是内容不解码的元素
+func isRawTextElement(n *Node) bool {
+	return n.Type == ElementNode && (n.Data == "script" || n.Data == "style")
+}
+
+// AppendChild 把节点追加为最后一个子节点
+func (n *Node) AppendChild(child *Node) {
+	child.Parent = n
+	child.PrevSibling = n.LastChild
+	if n.LastChild != nil {
+		n.LastChild.NextSibling = child
+	} else {
+		n.FirstChild = child
+	}
+	n.LastChild = child
+}
+
+// Attr 返回属性值，属性不存在时返回空字符串
+func (n *Node) Attr(key string) string {
+	val, _ := n.LookupAttr(key)
+	return val
+}
+
+// LookupAttr 返回属性值以及属性是否存在
+func (n *Node) LookupAttr(key string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// Text 返回节点及其所有后代的文本内容
+func (n *Node) Text() string {
+	switch n.Type {
+	case TextNode:
+		return n.Data
+	case AttributeNode:
+		return n.Attr(n.Data)
+	}
+	var b strings.Builder
+	n.Walk(func(d *Node) {
+		if d.Type == TextNode {
+			b.WriteString(d.Data)
+		}
+	})
+	return b.String()
+}
+
+// Walk 按文档顺序访问节点及其所有后代
+func (n *Node) Walk(visit func(*Node)) {
+	visit(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		c.Walk(visit)
+	}
+}
+
+// InnerHTML 返回子节点的HTML
+func (n *Node) InnerHTML() string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		c.render(&b)
+	}
+	return b.String()
+}
+
+// OuterHTML 返回节点本身的HTML
+func (n *Node) OuterHTML() string {
+	var b strings.Builder
+	n.render(&b)
+	return b.String()
+}
+
+// render 把节点输出为HTML
+func (n *Node) render(b *strings.Builder) {
+	switch n.Type {
+	case DocumentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			c.render(b)
+		}
+	case TextNode:
+		if n.Parent != nil && isRawTextElement(n.Parent) {
+			b.WriteString(n.Data)
+		} else {
+			b.WriteString(html.EscapeString(n.Data))
+		}
+	case CommentNode:
+		b.WriteString("<!--" + n.Data + "-->")
+	case AttributeNode:
+		b.WriteString(html.EscapeString(n.Attr(n.Data)))
+	case ElementNode:
+		b.WriteString("<" + n.Data)
+		for _, a := range n.Attrs {
+			b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		b.WriteString(">")
+		if voidElements[n.Data] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			c.render(b)
+		}
+		b.WriteString("</" + n.Data + ">")
+	}
+}
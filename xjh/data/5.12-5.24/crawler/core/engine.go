@@ -2,7 +2,14 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"sort"
 	"sync"
 	"time"
 )
@@ -24,9 +31,42 @@ type Engine struct {
 	// 结果存储
 	storage Storage
 
-	// 去重器
+	// 去重器，记录已处理完的请求
 	duplicateChecker DuplicateChecker
 
+	// 本次运行中已入队但尚未处理完的请求，下载中的资源链接的值为nil。
+	// 请求处理完成或被放弃后才在去重器中标记，持久化的去重器不会把爬取中途停止时仍在队列中的URL记为已爬取，
+	// 这些URL可以用 SaveFrontier 保存，下次运行时继续抓取
+	pending   map[string]*URL
+	pendingMu sync.Mutex
+
+	// 爬虫陷阱检测器，为nil时不检测
+	trapDetector *TrapDetector
+
+	// 爬取预算
+	budget *CrawlBudget
+
+	// robots.txt 检查器，Start 时按抓取器链底层的 HTTPFetcher 创建，为nil时不检查
+	robots *RobotsChecker
+
+	// 近似重复页面指纹索引，为nil时不检测
+	simHashIndex *SimHashIndex
+
+	// 条件请求的校验信息，为nil时不发送条件请求
+	validators *ValidatorStore
+
+	// 重爬调度器，为nil时每个URL只爬取一次
+	scheduler *RecrawlScheduler
+
+	// 原始页面存储，为nil时不保存页面内容
+	pageStore *PageStore
+
+	// 资源下载器，为nil时不下载资源
+	assets AssetDownloader
+
+	// 读取响应体的缓冲池
+	buffers *BufferPool
+
 	// 计数器
 	stats *Stats
 
@@ -53,7 +93,8 @@ type Options struct {
 	// 请求间隔
 	RequestDelay time.Duration
 
-	// 是否遵守robots.txt规则
+	// 是否遵守robots.txt规则，按发往各主机的User-Agent选择规则组，只对使用 HTTPFetcher 的抓取器生效。
+	// 在抓取前检查，robots.txt 暂时无法获取时推迟抓取该站点的URL
 	RespectRobotsTxt bool
 
 	// 自定义请求头
@@ -61,6 +102,37 @@ type Options struct {
 
 	// 是否启用Cookie
 	EnableCookies bool
+
+	// 默认Cookie管理器使用的公共后缀列表，用于拒绝为 com.cn、github.io 这类后缀设置的Cookie，
+	// 如 plugins.NewPublicSuffixList()。为nil时只按域名本身匹配，不识别公共后缀
+	PublicSuffixList cookiejar.PublicSuffixList
+
+	// 爬虫陷阱检测规则，为nil时不检测
+	Trap *TrapOptions
+
+	// 最大抓取页面数（包括失败的请求），0表示不限制
+	MaxPages int
+
+	// 最大下载字节数，0表示不限制
+	MaxBytes int64
+
+	// 每个主机的最大抓取页面数，0表示不限制
+	MaxPagesPerHost int
+
+	// 路径前缀的最大抓取页面数，键为主机+路径前缀，如 "example.com/docs"
+	PathPrefixCaps map[string]int
+
+	// 近似重复页面检测配置，为nil时不检测
+	NearDuplicate *NearDuplicateOptions
+
+	// 以流的方式边下载边解析页面，不在内存中保留完整的页面内容。
+	// 需要抓取器实现 StreamFetcher、解析器实现 StreamParser；
+	// 启用原始页面存储、近似重复检测或资源下载时仍读取完整页面
+	Streaming bool
+
+	// 页面内容在解析之后的保留方式，默认丢弃。设置了原始页面存储时页面总会被保存。
+	// 引擎会回收读取页面的缓冲区，解析器和存储不能在解析之后继续引用 Page.Content
+	ContentRetention ContentRetention
 }
 
 // Stats 爬虫统计信息
@@ -77,6 +149,32 @@ type Stats struct {
 	// 发现的URL总数
 	URLsFound int64
 
+	// 被陷阱检测拦截的URL数
+	URLsSuppressed int64
+
+	// 被robots.txt禁止抓取的URL数
+	URLsDisallowed int64
+
+	// 因主机或路径前缀预算（持续运行模式下还有全局预算）被跳过的URL数
+	URLsOverBudget int64
+
+	// 下载的总字节数
+	BytesFetched int64
+
+	// 近似重复的页面数
+	NearDuplicates int64
+
+	// 返回304未变化的页面数
+	PagesNotModified int64
+
+	// 因条件请求节省的下载字节数
+	BytesSaved int64
+
+	// 下载成功和失败的资源数，以及下载的资源字节数
+	AssetsDownloaded int64
+	AssetsFailed     int64
+	AssetBytes       int64
+
 	// 最近的错误
 	LastError error
 
@@ -102,17 +200,48 @@ func NewEngine(options *Options) *Engine {
 		}
 	}
 
-	return &Engine{
+	fetcher := NewHTTPFetcher(options.RequestTimeout)
+	if len(options.Headers) > 0 {
+		fetcher.SetHeaders(options.Headers)
+	}
+
+	// 每个引擎使用独立的Cookie管理器，不同爬取任务之间不共享Cookie
+	if options.EnableCookies {
+		jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: options.PublicSuffixList})
+		fetcher.SetCookieJar(jar)
+	}
+
+	buffers := NewBufferPool()
+	fetcher.SetBufferPool(buffers)
+
+	engine := &Engine{
 		options:          options,
 		queue:            NewSimpleQueue(),
-		fetcher:          NewHTTPFetcher(options.RequestTimeout),
+		fetcher:          fetcher,
 		parser:           NewDefaultParser(),
 		storage:          NewMemoryStorage(),
 		duplicateChecker: NewSimpleChecker(),
+		pending:          make(map[string]*URL),
+		budget:           NewCrawlBudget(options),
+		buffers:          buffers,
 		stats:            &Stats{},
 		ctx:              ctx,
 		cancel:           cancel,
 	}
+
+	if options.Trap != nil {
+		engine.trapDetector = NewTrapDetector(*options.Trap)
+	}
+
+	if options.NearDuplicate != nil {
+		maxDistance := options.NearDuplicate.MaxDistance
+		if maxDistance <= 0 {
+			maxDistance = 3
+		}
+		engine.simHashIndex = NewSimHashIndex(maxDistance)
+	}
+
+	return engine
 }
 
 // SetFetcher 设置自定义的页面抓取器
@@ -120,6 +249,32 @@ func (e *Engine) SetFetcher(fetcher Fetcher) {
 	e.fetcher = fetcher
 }
 
+// GetFetcher 获取页面抓取器
+func (e *Engine) GetFetcher() Fetcher {
+	return e.fetcher
+}
+
+// httpFetcher 沿包装链查找底层的 HTTPFetcher，找不到时返回nil
+func (e *Engine) httpFetcher() *HTTPFetcher {
+	fetcher := e.fetcher
+	for fetcher != nil {
+		if f, ok := fetcher.(*HTTPFetcher); ok {
+			return f
+		}
+		wrapper, ok := fetcher.(FetcherWrapper)
+		if !ok {
+			return nil
+		}
+		fetcher = wrapper.Unwrap()
+	}
+	return nil
+}
+
+// GetHTTPFetcher 返回抓取器本身或它包装的HTTPFetcher，没有时返回nil
+func (e *Engine) GetHTTPFetcher() *HTTPFetcher {
+	return e.httpFetcher()
+}
+
 // SetParser 设置自定义的页面解析器
 func (e *Engine) SetParser(parser Parser) {
 	e.parser = parser
@@ -135,16 +290,232 @@ func (e *Engine) SetStorage(storage Storage) {
 	e.storage = storage
 }
 
-// AddURL 添加URL到爬取队列
+// SetValidatorStore 设置校验信息存储，启用增量重爬的条件请求。
+// 如果抓取器是（或包装了）HTTPFetcher，会同时为其设置该存储
+func (e *Engine) SetValidatorStore(store *ValidatorStore) {
+	e.validators = store
+	if fetcher := e.httpFetcher(); fetcher != nil {
+		fetcher.SetValidatorStore(store)
+	}
+}
+
+// SetRecrawlScheduler 设置重爬调度器，启用持续运行模式：
+// 队列为空时引擎不会退出，而是等待调度器送回到期的URL，直到超时或被停止
+func (e *Engine) SetRecrawlScheduler(scheduler *RecrawlScheduler) {
+	e.scheduler = scheduler
+}
+
+// SetHeaderProvider 设置按主机提供请求头的组件，仅当抓取器是（或包装了）HTTPFetcher 时生效
+func (e *Engine) SetHeaderProvider(provider HeaderProvider) {
+	if fetcher := e.httpFetcher(); fetcher != nil {
+		fetcher.SetHeaderProvider(provider)
+	}
+}
+
+// SetCookieJar 替换默认的Cookie管理器，仅当抓取器是（或包装了）HTTPFetcher 时生效
+func (e *Engine) SetCookieJar(jar http.CookieJar) {
+	if fetcher := e.httpFetcher(); fetcher != nil {
+		fetcher.SetCookieJar(jar)
+	}
+}
+
+// AddAuthenticator 添加认证方式，仅当抓取器是（或包装了）HTTPFetcher 时生效
+func (e *Engine) AddAuthenticator(auth Authenticator) {
+	if fetcher := e.httpFetcher(); fetcher != nil {
+		fetcher.AddAuthenticator(auth)
+	}
+}
+
+// SetResponseRecorder 设置原始响应记录器，仅当抓取器是（或包装了）HTTPFetcher 时生效
+func (e *Engine) SetResponseRecorder(recorder ResponseRecorder) {
+	if fetcher := e.httpFetcher(); fetcher != nil {
+		fetcher.SetResponseRecorder(recorder)
+	}
+}
+
+// SetPageStore 设置原始页面存储，抓取成功的页面会被保存，之后可以用 Reparse 重新解析
+func (e *Engine) SetPageStore(store *PageStore) {
+	e.pageStore = store
+}
+
+// SetAssetDownloader 设置资源下载器。解析器实现了 AssetParser 时，
+// 页面中的资源链接会交给下载器，下载完成后存储 asset 类型的结果
+func (e *Engine) SetAssetDownloader(downloader AssetDownloader) {
+	e.assets = downloader
+}
+
+// SetDuplicateChecker 设置自定义的URL去重器
+func (e *Engine) SetDuplicateChecker(checker DuplicateChecker) {
+	e.duplicateChecker = checker
+}
+
+// AddURL 添加URL到爬取队列，已见过的URL会被忽略
 func (e *Engine) AddURL(url string) {
-	e.queue.Push(&URL{
+	e.enqueue(&URL{
 		Address: url,
 		Depth:   0,
 	})
 }
 
+// AddRequest 添加带请求元数据（如 MetadataMethod、MetadataBody）的URL到爬取队列，
+// 同一URL的不同请求体分别去重
+func (e *Engine) AddRequest(url *URL) {
+	e.enqueue(&URL{
+		Address:  url.Address,
+		Depth:    0,
+		Metadata: url.Metadata,
+	})
+}
+
+// enqueue 在入队时检查并认领URL，保证队列中不会出现已见过的URL，
+// 返回URL是否被加入队列
+func (e *Engine) enqueue(url *URL) bool {
+	if !e.claim(RequestKey(url), url) {
+		return false
+	}
+	e.queue.Push(url)
+	return true
+}
+
+// robotsAllowed 在抓取前检查robots.txt是否允许抓取URL，被禁止时计入统计。
+// robots.txt 暂时无法获取时返回 ErrRobotsUnavailable，URL应推迟而不是放弃
+func (e *Engine) robotsAllowed(ctx context.Context, url *URL) (bool, error) {
+	if e.robots == nil {
+		return true, nil
+	}
+	allowed, err := e.robots.Allowed(ctx, url.Address)
+	if err != nil || allowed {
+		return allowed, err
+	}
+
+	e.stats.mu.Lock()
+	e.stats.URLsDisallowed++
+	e.stats.mu.Unlock()
+	fmt.Printf("robots.txt禁止抓取 %s\n", url.Address)
+	return false, nil
+}
+
+// claim 检查请求既没有处理过也不在队列中，并把它记为待处理，返回是否认领成功
+func (e *Engine) claim(key string, url *URL) bool {
+	e.pendingMu.Lock()
+	defer e.pendingMu.Unlock()
+
+	if _, ok := e.pending[key]; ok || e.duplicateChecker.IsDuplicate(key) {
+		return false
+	}
+	e.pending[key] = url
+	return true
+}
+
+// drop 放弃认领的请求（陷阱、robots.txt 或深度限制），在去重器中标记后移出未完成队列，
+// 请求不再入队，也不会被 SaveFrontier 保存。被放弃的请求只记录在去重器中，内存占用不随之增长
+func (e *Engine) drop(key string) {
+	e.pendingMu.Lock()
+	defer e.pendingMu.Unlock()
+
+	e.duplicateChecker.MarkAsDuplicate(key)
+	delete(e.pending, key)
+}
+
+// settle 在去重器中标记处理完的请求。爬取被停止或超时时不做标记，
+// 请求留在未完成队列中，本次运行不会再入队，可以由 SaveFrontier 保存后在下次运行时重新抓取
+func (e *Engine) settle(ctx context.Context, key string) {
+	e.pendingMu.Lock()
+	defer e.pendingMu.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+	e.duplicateChecker.MarkAsDuplicate(key)
+	delete(e.pending, key)
+}
+
+// Frontier 返回已入队但尚未处理完的URL，包括爬取被停止时仍在队列中的URL和进行中的URL
+func (e *Engine) Frontier() []*URL {
+	e.pendingMu.Lock()
+	defer e.pendingMu.Unlock()
+
+	urls := make([]*URL, 0, len(e.pending))
+	for _, url := range e.pending {
+		if url != nil {
+			urls = append(urls, url)
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if urls[i].Depth != urls[j].Depth {
+			return urls[i].Depth < urls[j].Depth
+		}
+		return urls[i].Address < urls[j].Address
+	})
+	return urls
+}
+
+// SaveFrontier 把未处理完的URL保存到文件，配合持久化的去重器使用，下次运行时用 LoadFrontier 继续抓取
+func (e *Engine) SaveFrontier(filename string) error {
+	urls := e.Frontier()
+	for i, url := range urls {
+		// 请求体按字符串保存，避免JSON把 []byte 编码为base64
+		if body, ok := url.Metadata[MetadataBody].([]byte); ok {
+			copied := *url
+			copied.Metadata = make(map[string]interface{}, len(url.Metadata))
+			for k, v := range url.Metadata {
+				copied.Metadata[k] = v
+			}
+			copied.Metadata[MetadataBody] = string(body)
+			urls[i] = &copied
+		}
+	}
+
+	// 创建文件
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(urls); err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+	return nil
+}
+
+// LoadFrontier 把上次运行保存的未处理完的URL加入队列，保留原来的深度和请求元数据，返回加入队列的URL数
+func (e *Engine) LoadFrontier(filename string) (int, error) {
+	// 打开文件
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var urls []*URL
+	if err := json.NewDecoder(file).Decode(&urls); err != nil {
+		return 0, fmt.Errorf("解码数据失败: %w", err)
+	}
+
+	n := 0
+	for _, url := range urls {
+		if url != nil && e.enqueue(url) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 // Start 启动爬虫引擎
 func (e *Engine) Start() error {
+	// 检查内容保留方式所需的组件
+	switch e.options.ContentRetention {
+	case ContentCompressed:
+		if _, ok := e.storage.(ContentStorage); !ok {
+			return fmt.Errorf("存储 %T 不支持保存页面内容", e.storage)
+		}
+	case ContentRawStore:
+		if e.pageStore == nil {
+			return fmt.Errorf("保存原始页面需要先设置原始页面存储")
+		}
+	}
+
 	// 设置全局超时
 	var ctx context.Context
 	var cancel context.CancelFunc
@@ -156,6 +527,14 @@ func (e *Engine) Start() error {
 		ctx = e.ctx
 	}
 
+	// 抓取器链此时已配置完成，robots.txt 与页面通过同一个 HTTPFetcher 获取。
+	// 抓取器没有包装 HTTPFetcher 时（如WARC回放）不检查
+	if e.options.RespectRobotsTxt && e.robots == nil {
+		if fetcher := e.httpFetcher(); fetcher != nil {
+			e.robots = NewRobotsChecker(fetcher)
+		}
+	}
+
 	// 并发控制
 	semaphore := make(chan struct{}, e.options.Concurrency)
 
@@ -166,14 +545,31 @@ func (e *Engine) Start() error {
 	activeWorkers := 0
 	var activeMu sync.Mutex
 
+	// 持续运行模式下全局预算是否已耗尽
+	exhausted := false
+
+	// 因robots.txt暂时无法获取而推迟的URL，按到期时间排列，到期后送回队列。
+	// 爬取结束时仍未到期的URL留在未完成队列中，由 SaveFrontier 保存
+	var deferred []deferredURL
+
+	// 持续运行模式下，由调度器把到期的URL送回队列
+	if e.scheduler != nil {
+		go e.scheduler.Run(ctx, e.queue)
+	}
+
 	// 主循环
 	for {
 		select {
 		case <-ctx.Done():
 			fmt.Println("爬虫已超时或被取消")
-			e.wg.Wait()
+			e.wait()
 			return ctx.Err()
 		default:
+			for len(deferred) > 0 && !time.Now().Before(deferred[0].retryAt) {
+				e.queue.Push(deferred[0].url)
+				deferred = deferred[1:]
+			}
+
 			// 获取下一个URL
 			url, ok := e.queue.Pop()
 			if !ok {
@@ -182,7 +578,8 @@ func (e *Engine) Start() error {
 				active := activeWorkers
 				activeMu.Unlock()
 
-				if active == 0 {
+				if active == 0 && e.scheduler == nil && len(deferred) == 0 {
+					e.wait()
 					fmt.Println("队列为空，爬取完成")
 					return nil
 				}
@@ -193,14 +590,46 @@ func (e *Engine) Start() error {
 
 			// 检查深度
 			if url.Depth > e.options.MaxDepth {
+				e.drop(RequestKey(url))
 				continue
 			}
 
-			// 检查是否已访问过
-			if e.duplicateChecker.IsDuplicate(url.Address) {
+			// 检查robots.txt，在预留预算之前进行，被禁止的URL不占用预算
+			allowed, err := e.robotsAllowed(ctx, url)
+			if errors.Is(err, ErrRobotsUnavailable) {
+				deferred = append(deferred, deferredURL{url: url, retryAt: time.Now().Add(robotsRetry)})
+				continue
+			}
+			if !allowed {
+				e.drop(RequestKey(url))
 				continue
 			}
 
+			// 检查爬取预算，全局预算耗尽时等待进行中的任务后结束。
+			// 重爬的URL不受预算限制，否则达到主机或前缀上限的URL会永远离开调度
+			if !IsRecrawl(url) {
+				skip, err := e.budget.Reserve(url.Address)
+				if err != nil && e.scheduler == nil {
+					fmt.Printf("爬取预算耗尽: %v\n", err)
+					e.wait()
+					return err
+				}
+				if err != nil {
+					// 持续运行模式下全局预算只限制新发现的URL，已抓取的URL继续按计划重爬
+					if !exhausted {
+						fmt.Printf("爬取预算耗尽，不再抓取新的URL: %v\n", err)
+						exhausted = true
+					}
+					skip = BudgetExhausted
+				}
+				if skip != "" {
+					e.stats.mu.Lock()
+					e.stats.URLsOverBudget++
+					e.stats.mu.Unlock()
+					continue
+				}
+			}
+
 			// 请求限速
 			if e.options.RequestDelay > 0 {
 				time.Sleep(e.options.RequestDelay)
@@ -227,21 +656,25 @@ func (e *Engine) Start() error {
 					activeMu.Unlock()
 				}()
 
-				// 标记为已访问
-				e.duplicateChecker.MarkAsDuplicate(u.Address)
-
-				// 处理URL
+				// 处理URL，完成后才标记为已爬取
 				e.processURL(ctx, u)
+				e.settle(ctx, RequestKey(u))
 			}(url)
 		}
 	}
 }
 
+// deferredURL 推迟抓取的URL及其重试时间
+type deferredURL struct {
+	url     *URL
+	retryAt time.Time
+}
+
 // Stop 停止爬虫引擎
 func (e *Engine) Stop() {
 	e.cancel()
 	// 等待所有工作完成
-	e.wg.Wait()
+	e.wait()
 }
 
 // GetStats 获取当前统计信息
@@ -249,7 +682,32 @@ func (e *Engine) GetStats() Stats {
 	e.stats.mu.RLock()
 	defer e.stats.mu.RUnlock()
 
-	return *e.stats
+	// 逐字段复制，避免复制锁
+	return Stats{
+		URLsProcessed:    e.stats.URLsProcessed,
+		PagesSucceeded:   e.stats.PagesSucceeded,
+		PagesFailed:      e.stats.PagesFailed,
+		URLsFound:        e.stats.URLsFound,
+		URLsSuppressed:   e.stats.URLsSuppressed,
+		URLsDisallowed:   e.stats.URLsDisallowed,
+		URLsOverBudget:   e.stats.URLsOverBudget,
+		BytesFetched:     e.stats.BytesFetched,
+		NearDuplicates:   e.stats.NearDuplicates,
+		PagesNotModified: e.stats.PagesNotModified,
+		BytesSaved:       e.stats.BytesSaved,
+		AssetsDownloaded: e.stats.AssetsDownloaded,
+		AssetsFailed:     e.stats.AssetsFailed,
+		AssetBytes:       e.stats.AssetBytes,
+		LastError:        e.stats.LastError,
+	}
+}
+
+// GetTrapReport 获取每个主机被陷阱检测拦截的URL统计，未启用检测时返回nil
+func (e *Engine) GetTrapReport() map[string]map[string]int64 {
+	if e.trapDetector == nil {
+		return nil
+	}
+	return e.trapDetector.Report()
 }
 
 // processURL 处理单个URL
@@ -261,47 +719,321 @@ func (e *Engine) processURL(ctx context.Context, url *URL) {
 
 	fmt.Printf("正在处理 [%d] %s\n", url.Depth, url.Address)
 
+	if fetcher, parser, ok := e.streamPipeline(); ok {
+		e.processStream(ctx, url, fetcher, parser)
+		return
+	}
+
 	// 抓取页面
-	page, err := e.fetcher.Fetch(ctx, url.Address)
+	page, err := e.fetcher.Fetch(ContextWithRequest(ctx, RequestOf(url)), url.Address)
 	if err != nil {
-		e.stats.mu.Lock()
-		e.stats.PagesFailed++
-		e.stats.LastError = err
-		e.stats.mu.Unlock()
+		e.fetchFailed(url, err)
+		return
+	}
 
-		fmt.Printf("抓取失败 %s: %v\n", url.Address, err)
+	// 页面未变化，无需重新解析，沿用上次解析出的链接
+	if page.StatusCode == http.StatusNotModified {
+		e.handleNotModified(url)
 		return
 	}
+	defer e.releaseContent(url, page)
 
 	// 更新统计信息
+	e.pageFetched(url, int64(len(page.Content)))
+
+	// 保存原始页面，供改进解析器后重新解析
+	if e.pageStore != nil {
+		if err := e.pageStore.Save(url, page); err != nil {
+			fmt.Printf("保存原始页面失败 %s: %v\n", url.Address, err)
+		}
+	}
+
+	// 解析页面
+	results, links, requests := e.parse(page)
+	if e.validators != nil && RequestOf(url).IsGet() {
+		e.validators.SetLinks(url.Address, links)
+	}
+
+	// 把资源链接交给下载器
+	if e.assets != nil {
+		e.downloadAssets(ctx, page)
+	}
+
+	e.finishPage(url, page, results, links, requests, ContentHash(page.Content))
+}
+
+// parse 解析页面，解析器实现了 RequestParser 时还返回带请求元数据的URL
+func (e *Engine) parse(page *Page) ([]Result, []string, []*URL) {
+	if parser, ok := e.parser.(RequestParser); ok {
+		return parser.ParseRequests(page)
+	}
+	results, links := e.parser.Parse(page)
+	return results, links, nil
+}
+
+// releaseContent 按保留方式处理解析后的页面内容，并回收读取页面的缓冲区。
+// 内容与结果一样以 RequestKey 为键保存
+func (e *Engine) releaseContent(url *URL, page *Page) {
+	if e.options.ContentRetention == ContentCompressed && len(page.Content) > 0 {
+		if storage, ok := e.storage.(ContentStorage); ok {
+			compressed, err := CompressContent(page.Content)
+			if err == nil {
+				err = storage.StoreContent(RequestKey(url), compressed, ContentGzip)
+			}
+			if err != nil {
+				fmt.Printf("保存页面内容失败 %s: %v\n", page.URL, err)
+			}
+		}
+	}
+
+	releasePage(page, e.buffers)
+}
+
+// streamPipeline 返回流式处理页面所需的抓取器和解析器，不能流式处理时返回false
+func (e *Engine) streamPipeline() (StreamFetcher, StreamParser, bool) {
+	if !e.options.Streaming || e.pageStore != nil || e.simHashIndex != nil || e.assets != nil ||
+		e.options.ContentRetention != ContentDiscard {
+		return nil, nil, false
+	}
+	fetcher, ok := e.fetcher.(StreamFetcher)
+	if !ok {
+		return nil, nil, false
+	}
+	parser, ok := e.parser.(StreamParser)
+	if !ok {
+		return nil, nil, false
+	}
+	return fetcher, parser, true
+}
+
+// processStream 边下载边解析页面，同时计算内容哈希
+func (e *Engine) processStream(ctx context.Context, url *URL, fetcher StreamFetcher, parser StreamParser) {
+	page, body, err := fetcher.FetchStream(ContextWithRequest(ctx, RequestOf(url)), url.Address)
+	if err != nil {
+		e.fetchFailed(url, err)
+		return
+	}
+
+	// 页面未变化，无需重新解析，沿用上次解析出的链接
+	if body == nil {
+		e.handleNotModified(url)
+		return
+	}
+
+	hasher := NewContentHasher()
+	results, links, err := parser.ParseStream(page, io.TeeReader(body, hasher))
+	body.Close()
+	if err != nil {
+		e.fetchFailed(url, err)
+		return
+	}
+
+	// 页面读取完成后才知道大小
+	e.pageFetched(url, hasher.Size())
+
+	if e.validators != nil && RequestOf(url).IsGet() {
+		e.validators.SetLinks(url.Address, links)
+	}
+
+	e.finishPage(url, page, results, links, nil, hasher.Sum())
+}
+
+// fetchFailed 记录抓取失败的页面
+func (e *Engine) fetchFailed(url *URL, err error) {
+	e.stats.mu.Lock()
+	e.stats.PagesFailed++
+	e.stats.LastError = err
+	e.stats.mu.Unlock()
+
+	fmt.Printf("抓取失败 %s: %v\n", url.Address, err)
+	if e.scheduler != nil {
+		e.scheduler.RecordFailure(url, time.Now())
+	}
+}
+
+// pageFetched 记录抓取成功的页面及其大小，重爬下载的字节不计入预算
+func (e *Engine) pageFetched(url *URL, size int64) {
 	e.stats.mu.Lock()
 	e.stats.PagesSucceeded++
+	e.stats.BytesFetched += size
 	e.stats.mu.Unlock()
+	if !IsRecrawl(url) {
+		e.budget.AddBytes(size)
+	}
+}
 
-	// 解析页面
-	results, links := e.parser.Parse(page)
+// finishPage 处理解析后的页面：记录内容哈希，安排重爬，存储结果并把链接和请求加入队列
+func (e *Engine) finishPage(url *URL, page *Page, results []Result, links []string, requests []*URL, contentHash string) {
+	// 记录归一化内容哈希和解析器版本，用于比较两次爬取之间的变化
+	annotateResults(results, contentHash, e.parser)
 
-	// 存储结果
-	if len(results) > 0 {
-		e.storage.Store(url.Address, results)
+	// 根据内容变化安排下次访问
+	if e.scheduler != nil {
+		e.scheduler.Record(url, contentHash, page.Headers["Last-Modified"], time.Now())
 	}
 
+	// 检测近似重复页面
+	if e.simHashIndex != nil && e.checkNearDuplicate(page, results) && e.options.NearDuplicate.SkipLinks {
+		links, requests = nil, nil
+	}
+
+	// 记录结果，页面未变化时沿用
+	if e.validators != nil && RequestOf(url).IsGet() {
+		e.validators.SetResults(url.Address, results)
+	}
+
+	// 存储结果，同一URL的不同请求（如POST分页）分别存储。
+	// 没有结果时删除旧结果，重爬后不再提取出结果的页面不会留下过时的结果
+	e.storage.Store(RequestKey(url), results)
+
 	// 更新统计信息
 	e.stats.mu.Lock()
-	e.stats.URLsFound += int64(len(links))
+	e.stats.URLsFound += int64(len(links) + len(requests))
 	e.stats.mu.Unlock()
 
-	// 将新的链接添加到队列
+	e.enqueueLinks(url, links)
+	e.enqueueRequests(url, requests)
+}
+
+// wait 等待进行中的页面和资源下载完成
+func (e *Engine) wait() {
+	e.wg.Wait()
+	if e.assets != nil {
+		e.assets.Wait()
+	}
+}
+
+// downloadAssets 把页面中未下载过的资源链接提交给下载器
+func (e *Engine) downloadAssets(ctx context.Context, page *Page) {
+	parser, ok := e.parser.(AssetParser)
+	if !ok {
+		return
+	}
+
+	for _, link := range parser.Assets(page) {
+		if !e.assets.Matches(link) || !e.claim(link, nil) {
+			continue
+		}
+
+		e.assets.Download(ctx, link, page.URL, func(asset *Asset, err error) {
+			e.settle(ctx, link)
+
+			if err != nil {
+				e.stats.mu.Lock()
+				e.stats.AssetsFailed++
+				e.stats.LastError = err
+				e.stats.mu.Unlock()
+				fmt.Printf("下载资源失败 %s: %v\n", link, err)
+				return
+			}
+
+			e.stats.mu.Lock()
+			e.stats.AssetsDownloaded++
+			e.stats.AssetBytes += asset.Size
+			e.stats.mu.Unlock()
+
+			// 存储可能较慢，不在持有统计锁时进行
+			e.storage.Store(asset.URL, []Result{asset.Result()})
+		})
+	}
+}
+
+// handleNotModified 处理返回304的页面：计入节省的流量，并沿用上次解析出的结果和链接
+func (e *Engine) handleNotModified(url *URL) {
+	var results []Result
+	var links []string
+	var size int64
+	if e.validators != nil {
+		if v, ok := e.validators.Get(url.Address); ok {
+			results, links, size = v.Results, v.Links, v.Size
+		}
+	}
+
+	// 未变化的页面仍然出现在结果中，比较两次爬取时不会被当作已删除
+	if len(results) > 0 {
+		e.storage.Store(RequestKey(url), results)
+	}
+
+	e.stats.mu.Lock()
+	e.stats.PagesNotModified++
+	e.stats.BytesSaved += size
+	e.stats.mu.Unlock()
+
+	fmt.Printf("页面未变化 %s\n", url.Address)
+	if e.scheduler != nil {
+		e.scheduler.Record(url, "", "", time.Now())
+	}
+	e.enqueueLinks(url, links)
+}
+
+// enqueueLinks 将页面中发现的链接添加到队列
+func (e *Engine) enqueueLinks(url *URL, links []string) {
+	requests := make([]*URL, len(links))
+	for i, link := range links {
+		requests[i] = &URL{Address: link}
+	}
+	e.enqueueRequests(url, requests)
+}
+
+// enqueueRequests 将页面中发现的请求添加到队列，保留请求的元数据
+func (e *Engine) enqueueRequests(url *URL, requests []*URL) {
 	newDepth := url.Depth + 1
 	if newDepth <= e.options.MaxDepth {
-		for _, link := range links {
-			e.queue.Push(&URL{
-				Address: link,
-				Depth:   newDepth,
-				Parent:  url.Address,
-			})
+		for _, request := range requests {
+			next := &URL{
+				Address:  request.Address,
+				Depth:    newDepth,
+				Parent:   url.Address,
+				Metadata: request.Metadata,
+			}
+			key := RequestKey(next)
+			if !e.claim(key, next) {
+				continue
+			}
+
+			// 过滤疑似爬虫陷阱的URL。只检查新发现的URL，每个页面都出现的导航链接不会重复占用模板预算，
+			// 被拦截的URL在去重器中标记，再次出现时也不会重复计数
+			if e.trapDetector != nil && e.trapDetector.Check(next.Address) != "" {
+				e.drop(key)
+				e.stats.mu.Lock()
+				e.stats.URLsSuppressed++
+				e.stats.mu.Unlock()
+				continue
+			}
+			e.queue.Push(next)
+		}
+	}
+}
+
+// checkNearDuplicate 计算页面可见文本的SimHash指纹，并为结果标记所属的重复簇，返回页面是否是近似重复页面
+func (e *Engine) checkNearDuplicate(page *Page, results []Result) bool {
+	text := VisibleText(page.Content)
+	if text == "" {
+		return false
+	}
+
+	fingerprint := SimHash(text, e.options.NearDuplicate.ShingleSize)
+	cluster, duplicate := e.simHashIndex.FindOrAdd(page.URL, fingerprint)
+
+	for _, result := range results {
+		if result.Data == nil {
+			continue
+		}
+		result.Data["simhash"] = fmt.Sprintf("%016x", fingerprint)
+		if duplicate {
+			result.Data["duplicate_of"] = cluster
 		}
 	}
+
+	if duplicate {
+		e.stats.mu.Lock()
+		e.stats.NearDuplicates++
+		e.stats.mu.Unlock()
+
+		fmt.Printf("近似重复页面 %s -> %s\n", page.URL, cluster)
+	}
+
+	return duplicate
 }
 
 // GetStorage 获取结果存储组件
@@ -1,17 +1,75 @@
 package core
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultUserAgent 默认的User-Agent
+const DefaultUserAgent = "GoCrawler/1.0"
+
+// HeaderProvider 按主机提供请求头，如按主机选择的User-Agent、Accept-Language
+type HeaderProvider interface {
+	// 返回发往该主机的请求应附加的请求头
+	Headers(host string) map[string]string
+}
+
+// Exchange 一次抓取的原始请求和响应，用于归档
+type Exchange struct {
+	// 页面URL，即 Page.URL
+	URL string
+
+	// 页面的请求方式（方法、请求体和附加的请求头），与 RequestKey 使用的相同，
+	// 回放时用它区分同一URL的不同请求（如POST分页）
+	PageRequest Request
+
+	// 最终发出的请求（跟随重定向之后）和对应的响应，响应体已读入 Body
+	Request  *http.Request
+	Response *http.Response
+	Body     []byte
+
+	// 重定向链，从页面URL开始，不含最终请求的URL
+	Redirects []string
+
+	// 抓取时间
+	Time time.Time
+}
+
+// ResponseRecorder 记录每次抓取的原始请求和响应，例如写入WARC文件
+type ResponseRecorder interface {
+	RecordResponse(exchange *Exchange) error
+}
+
 // HTTPFetcher 是一个基于HTTP的页面抓取器
 type HTTPFetcher struct {
 	client  *http.Client
 	headers map[string]string
+
+	// 按主机提供的请求头，优先于 headers
+	headerProvider HeaderProvider
+
+	// 条件请求所需的校验信息，为nil时总是发送无条件请求
+	validators *ValidatorStore
+
+	// 认证方式，按主机匹配
+	authenticators []Authenticator
+
+	// 串行化登录，避免多个worker同时重新登录
+	loginMu sync.Mutex
+
+	// 原始响应记录器，为nil时不记录
+	recorder ResponseRecorder
+
+	// 读取响应体的缓冲池，为nil时每次分配新的内存
+	buffers *BufferPool
 }
 
 // NewHTTPFetcher 创建一个新的HTTP抓取器
@@ -35,63 +93,394 @@ func (f *HTTPFetcher) SetHeaders(headers map[string]string) {
 	f.headers = headers
 }
 
-// Fetch 实现Fetcher接口，抓取指定URL的页面
-func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (*Page, error) {
-	// 创建HTTP请求
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+// SetHeaderProvider 设置按主机提供请求头的组件
+func (f *HTTPFetcher) SetHeaderProvider(provider HeaderProvider) {
+	f.headerProvider = provider
+}
+
+// UserAgent 返回发往该主机的请求所使用的User-Agent，
+// 检查robots.txt时应使用同一个值匹配规则组
+func (f *HTTPFetcher) UserAgent(host string) string {
+	return f.requestHeaders(host)["User-Agent"]
+}
+
+// RequestVariant 返回发往该主机的请求中影响响应内容的部分：请求头和生效的认证身份（不含密码、令牌）。
+// 响应缓存用它区分同一URL在不同请求头配置或登录身份下得到的响应
+func (f *HTTPFetcher) RequestVariant(host string) string {
+	headers := make(map[string]string)
+	for k, v := range f.requestHeaders(host) {
+		headers[http.CanonicalHeaderKey(k)] = v
 	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	// 设置通用头信息
-	req.Header.Set("User-Agent", "GoCrawler/1.0")
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, headers[name])
+	}
+	for _, auth := range f.authenticators {
+		if auth.Matches(host) {
+			fmt.Fprintf(&b, "auth: %s\n", authIdentity(auth))
+		}
+	}
+	return b.String()
+}
 
-	// 设置自定义头信息
+// requestHeaders 按优先级合并默认UA、自定义请求头和按主机提供的请求头
+func (f *HTTPFetcher) requestHeaders(host string) map[string]string {
+	headers := map[string]string{"User-Agent": DefaultUserAgent}
 	for k, v := range f.headers {
-		req.Header.Set(k, v)
+		headers[k] = v
+	}
+	if f.headerProvider != nil {
+		for k, v := range f.headerProvider.Headers(host) {
+			headers[k] = v
+		}
 	}
+	return headers
+}
+
+// SetTransport 设置底层的 http.RoundTripper，例如使用代理的 Transport
+func (f *HTTPFetcher) SetTransport(transport http.RoundTripper) {
+	f.client.Transport = transport
+}
+
+// SetCookieJar 设置Cookie管理器，为nil时不保存Cookie
+func (f *HTTPFetcher) SetCookieJar(jar http.CookieJar) {
+	f.client.Jar = jar
+}
+
+// SetValidatorStore 设置校验信息存储，启用基于ETag和Last-Modified的条件请求
+func (f *HTTPFetcher) SetValidatorStore(store *ValidatorStore) {
+	f.validators = store
+}
 
+// AddAuthenticator 添加认证方式。凭据只用于请求头，不会出现在页面和结果中
+func (f *HTTPFetcher) AddAuthenticator(auth Authenticator) {
+	f.authenticators = append(f.authenticators, auth)
+}
+
+// SetResponseRecorder 设置原始响应记录器，每个收到的响应（包括错误状态码）都会被记录
+func (f *HTTPFetcher) SetResponseRecorder(recorder ResponseRecorder) {
+	f.recorder = recorder
+}
+
+// SetBufferPool 设置读取响应体的缓冲池。页面内容位于池中的缓冲区，
+// 引擎处理完页面后会把缓冲区放回池中
+func (f *HTTPFetcher) SetBufferPool(pool *BufferPool) {
+	f.buffers = pool
+}
+
+// Fetch 实现Fetcher接口，抓取指定URL的页面
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (*Page, error) {
 	// 发送请求
-	resp, err := f.client.Do(req)
+	resp, err := f.do(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	// 读取响应体
+	content, buffer, err := f.readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 记录原始请求和响应
+	fetched := time.Now()
+	if f.recorder != nil {
+		if err := f.recorder.RecordResponse(newExchange(url, RequestFromContext(ctx), resp, content, fetched)); err != nil {
+			fmt.Printf("记录响应失败 %s: %v\n", url, err)
+		}
+	}
+
+	// 页面未变化，返回没有内容的页面
+	if resp.StatusCode == http.StatusNotModified && f.validators != nil {
+		f.buffers.Put(buffer)
+		return &Page{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Headers:    firstHeaderValues(resp.Header),
+			Timestamp:  fetched.Unix(),
+		}, nil
+	}
+
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
+		f.buffers.Put(buffer)
 		return nil, fmt.Errorf("HTTP状态码异常: %d", resp.StatusCode)
 	}
 
-	// 读取响应体
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+	// 记录校验信息，供下次条件请求使用
+	if f.validators != nil && RequestFromContext(ctx).IsGet() {
+		f.validators.Update(url, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), int64(len(content)))
 	}
 
-	// 提取页面标题
-	title := extractTitle(content)
+	page := NewPage(url, resp, content, fetched)
+	page.buffer = buffer
+	return page, nil
+}
 
-	// 获取响应头
-	headers := make(map[string]string)
-	for k, v := range resp.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
+// readBody 读取响应体，设置了缓冲池时读入池中的缓冲区
+func (f *HTTPFetcher) readBody(resp *http.Response) ([]byte, *bytes.Buffer, error) {
+	if f.buffers == nil {
+		content, err := io.ReadAll(resp.Body)
+		return content, nil, err
+	}
+
+	buffer := f.buffers.Get()
+	if resp.ContentLength > 0 && resp.ContentLength <= maxPooledBuffer {
+		buffer.Grow(int(resp.ContentLength) + bytes.MinRead)
+	}
+	if _, err := buffer.ReadFrom(resp.Body); err != nil {
+		f.buffers.Put(buffer)
+		return nil, nil, err
+	}
+	return buffer.Bytes(), buffer, nil
+}
+
+// releasePage 丢弃页面内容，内容来自缓冲池时把缓冲区放回 pool。
+// 之后不能再使用页面内容，包括解析时得到的子切片
+func releasePage(page *Page, pool *BufferPool) {
+	page.Content = nil
+	if page.buffer != nil {
+		pool.Put(page.buffer)
+		page.buffer = nil
+	}
+}
+
+// FetchStream 实现StreamFetcher接口，返回没有内容的页面和尚未读取的响应体，
+// 标题由流式解析器填写。设置了原始响应记录器时需要完整的响应体，此时先读入内存再返回
+func (f *HTTPFetcher) FetchStream(ctx context.Context, url string) (*Page, io.ReadCloser, error) {
+	resp, err := f.do(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fetched := time.Now()
+	var body io.Reader = resp.Body
+	if f.recorder != nil {
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取响应失败: %w", err)
 		}
+		if err := f.recorder.RecordResponse(newExchange(url, RequestFromContext(ctx), resp, content, fetched)); err != nil {
+			fmt.Printf("记录响应失败 %s: %v\n", url, err)
+		}
+		body = bytes.NewReader(content)
 	}
 
-	// 创建页面对象
 	page := &Page{
 		URL:        url,
-		Title:      title,
+		StatusCode: resp.StatusCode,
+		Headers:    firstHeaderValues(resp.Header),
+		Timestamp:  fetched.Unix(),
+	}
+
+	// 页面未变化，返回没有内容的页面
+	if resp.StatusCode == http.StatusNotModified && f.validators != nil {
+		resp.Body.Close()
+		return page, nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("HTTP状态码异常: %d", resp.StatusCode)
+	}
+
+	// 编码声明一般在页面开头，只检查前1KB
+	buffered := bufio.NewReaderSize(body, 4096)
+	head, _ := buffered.Peek(1024)
+	page.Charset = detectCharset(resp.Header, head)
+
+	stream := &streamBody{reader: buffered, closer: resp.Body}
+	if f.validators != nil && RequestFromContext(ctx).IsGet() {
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		stream.complete = func(size int64) {
+			f.validators.Update(url, etag, lastModified, size)
+		}
+	}
+	return page, stream, nil
+}
+
+// streamBody 流式响应体，统计读取的字节数；完整读取后关闭时调用 complete
+type streamBody struct {
+	reader   io.Reader
+	closer   io.Closer
+	size     int64
+	eof      bool
+	complete func(size int64)
+}
+
+// Read 实现 io.Reader 接口
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.size += int64(n)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// Close 关闭响应体，只有完整读取的响应才记录校验信息
+func (b *streamBody) Close() error {
+	if b.eof && b.complete != nil {
+		b.complete(b.size)
+		b.complete = nil
+	}
+	return b.closer.Close()
+}
+
+// NewPage 由HTTP响应和响应体创建页面对象，提取标题和编码。
+// 回放归档的抓取器也使用它，保证回放得到的页面与在线抓取一致
+func NewPage(url string, resp *http.Response, content []byte, fetched time.Time) *Page {
+	return &Page{
+		URL:        url,
+		Title:      extractTitle(content),
 		Content:    content,
 		StatusCode: resp.StatusCode,
-		Headers:    headers,
+		Headers:    firstHeaderValues(resp.Header),
 		Charset:    detectCharset(resp.Header, content),
-		Timestamp:  time.Now().Unix(),
+		Timestamp:  fetched.Unix(),
 	}
+}
 
-	return page, nil
+// Open 发送GET请求并返回尚未读取的响应，使用与 Fetch 相同的请求头、认证、代理和Cookie，
+// header 中的请求头（如 Range）会附加到请求上。调用方负责关闭响应体
+func (f *HTTPFetcher) Open(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := f.newRequest(ContextWithRequest(ctx, Request{Method: http.MethodGet}), url)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	return resp, nil
+}
+
+// do 发送请求；响应表明登录会话失效时重新登录并重试一次
+func (f *HTTPFetcher) do(ctx context.Context, url string) (*http.Response, error) {
+	req, err := f.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+
+	for _, auth := range f.authenticators {
+		reauth, ok := auth.(ReAuthenticator)
+		if !ok || !auth.Matches(req.URL.Host) || !reauth.NeedsLogin(resp) {
+			continue
+		}
+		resp.Body.Close()
+
+		f.loginMu.Lock()
+		err := reauth.Login(ctx, f.client)
+		f.loginMu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("重新登录失败: %w", err)
+		}
+
+		// 使用新的会话重试
+		if req, err = f.newRequest(ctx, url); err != nil {
+			return nil, err
+		}
+		if resp, err = f.client.Do(req); err != nil {
+			return nil, fmt.Errorf("请求失败: %w", err)
+		}
+		break
+	}
+
+	return resp, nil
+}
+
+// newRequest 创建带有请求头、条件请求头和认证信息的请求，
+// 请求方法、请求体和附加的请求头由 ctx 中的请求方式决定，默认为GET
+func (f *HTTPFetcher) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	spec := RequestFromContext(ctx)
+	var body io.Reader
+	if len(spec.Body) > 0 {
+		body = bytes.NewReader(spec.Body)
+	}
+
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(ctx, spec.Method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	// 设置通用头信息、自定义头信息、按主机提供的头信息和请求自带的头信息
+	for k, v := range f.requestHeaders(req.URL.Host) {
+		req.Header.Set(k, v)
+	}
+	for k, v := range spec.Headers {
+		req.Header.Set(k, v)
+	}
+
+	// 设置条件请求头，只用于GET请求
+	if f.validators != nil && spec.IsGet() {
+		if v, ok := f.validators.Get(url); ok {
+			if v.ETag != "" {
+				req.Header.Set("If-None-Match", v.ETag)
+			}
+			if v.LastModified != "" {
+				req.Header.Set("If-Modified-Since", v.LastModified)
+			}
+		}
+	}
+
+	// 添加认证信息
+	for _, auth := range f.authenticators {
+		if auth.Matches(req.URL.Host) {
+			if err := auth.Apply(req); err != nil {
+				return nil, fmt.Errorf("添加认证信息失败: %w", err)
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// newExchange 从最终响应还原请求、响应和重定向链
+func newExchange(url string, pageRequest Request, resp *http.Response, body []byte, fetched time.Time) *Exchange {
+	var redirects []string
+	for req := resp.Request; req.Response != nil; {
+		req = req.Response.Request
+		redirects = append([]string{req.URL.String()}, redirects...)
+	}
+
+	return &Exchange{
+		URL:         url,
+		PageRequest: pageRequest,
+		Request:     resp.Request,
+		Response:    resp,
+		Body:        body,
+		Redirects:   redirects,
+		Time:        fetched,
+	}
+}
+
+// firstHeaderValues 取出每个响应头的第一个值
+func firstHeaderValues(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range header {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+	return headers
 }
 
 // extractTitle 从HTML内容中提取标题
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"io"
 )
 
 // URL 表示一个待爬取的URL
@@ -41,6 +43,9 @@ type Page struct {
 
 	// 爬取时间戳
 	Timestamp int64
+
+	// Content 所在的缓冲区，来自缓冲池，页面处理完后由引擎回收
+	buffer *bytes.Buffer
 }
 
 // Result 表示从页面中提取的结果项
@@ -73,15 +78,100 @@ type Fetcher interface {
 	Fetch(ctx context.Context, url string) (*Page, error)
 }
 
+// StreamFetcher 是能以流的方式返回响应体的抓取器
+type StreamFetcher interface {
+	Fetcher
+
+	// 抓取指定URL，返回没有内容的页面和响应体，调用方负责关闭响应体。
+	// 页面未变化（304）时响应体为nil
+	FetchStream(ctx context.Context, url string) (*Page, io.ReadCloser, error)
+}
+
+// FetcherWrapper 表示包装了另一个抓取器的抓取器，如代理、缓存抓取器
+type FetcherWrapper interface {
+	// 返回被包装的抓取器
+	Unwrap() Fetcher
+}
+
 // Parser 表示页面解析器的接口
 type Parser interface {
 	// 解析页面，返回提取的结果和发现的链接
 	Parse(page *Page) ([]Result, []string)
 }
 
+// StreamParser 是能边读取边解析页面的解析器，页面的内存占用与页面大小无关
+type StreamParser interface {
+	Parser
+
+	// 从 body 中读取并解析页面，page.Content 为空，解析器可以填写 page.Title。
+	// 返回提取的结果和发现的链接，读取失败时返回错误
+	ParseStream(page *Page, body io.Reader) ([]Result, []string, error)
+}
+
+// RequestParser 是能发现需要以特定方式请求的链接（如POST接口的下一页）的解析器
+type RequestParser interface {
+	Parser
+
+	// 解析页面，除结果和链接外还返回带请求元数据（MetadataMethod 等）的URL，
+	// 引擎抓取时调用它代替 Parse
+	ParseRequests(page *Page) ([]Result, []string, []*URL)
+}
+
+// AssetParser 是能提取资源链接（图片、PDF、压缩包等）的解析器
+type AssetParser interface {
+	// 返回页面中的资源链接，这些链接不会出现在 Parse 返回的链接中
+	Assets(page *Page) []string
+}
+
+// Asset 表示一个下载完成的资源
+type Asset struct {
+	// 资源URL和引用它的页面URL
+	URL    string
+	Parent string
+
+	// 本地文件路径
+	Path string
+
+	// MIME类型、大小和SHA-256校验和
+	MIME   string
+	Size   int64
+	SHA256 string
+
+	// 内容与之前下载的资源相同，Path 指向已有的文件
+	Duplicate bool
+}
+
+// Result 把资源转换为 asset 类型的结果
+func (a *Asset) Result() Result {
+	return Result{
+		Type: "asset",
+		Data: map[string]interface{}{
+			"url":       a.URL,
+			"parent":    a.Parent,
+			"path":      a.Path,
+			"mime":      a.MIME,
+			"size":      a.Size,
+			"sha256":    a.SHA256,
+			"duplicate": a.Duplicate,
+		},
+	}
+}
+
+// AssetDownloader 表示资源下载器的接口，使用独立于页面抓取的下载池
+type AssetDownloader interface {
+	// 是否下载该URL
+	Matches(url string) bool
+
+	// 提交下载任务，下载完成或失败后调用 done
+	Download(ctx context.Context, url, parent string, done func(*Asset, error))
+
+	// 等待已提交的任务全部完成
+	Wait()
+}
+
 // Storage 表示结果存储的接口
 type Storage interface {
-	// 存储URL及其关联的结果
+	// 存储URL及其关联的结果，results 为空时删除URL已有的结果
 	Store(url string, results []Result) error
 
 	// 获取指定URL的结果
@@ -94,6 +184,14 @@ type Storage interface {
 	Clear()
 }
 
+// ContentStorage 是能保存页面内容的存储，Options.ContentRetention 为 ContentCompressed 时使用
+type ContentStorage interface {
+	Storage
+
+	// 保存页面内容，url 与 Store 一样为 RequestKey，encoding 为内容的编码，如 ContentGzip
+	StoreContent(url string, content []byte, encoding string) error
+}
+
 // DuplicateChecker 表示URL去重器的接口
 type DuplicateChecker interface {
 	// 检查URL是否已经爬取过
@@ -0,0 +1,732 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath 编译后的JSONPath表达式，在 encoding/json 解码得到的值上求值。支持：
+//   - $ 根节点、.name 和 ['name'] 子节点、.* 和 [*] 通配符、.. 递归下降
+//   - [0]、[-1] 下标，[0,2] 和 ['a','b'] 并集，[start:end:step] 切片
+//   - [?(@.price < 10 && @.tags)] 过滤器：比较运算、&&、||、!、存在性测试，@ 为当前值，$ 为根节点
+//
+// 对象的成员按键名排序后访问，保证结果的顺序稳定
+type JSONPath struct {
+	source string
+	steps  []jsonStep
+}
+
+// CompileJSONPath 编译JSONPath表达式，表达式必须以 $ 开头
+func CompileJSONPath(source string) (*JSONPath, error) {
+	p := &jsonPathParser{s: source}
+	p.skipSpace()
+	if !p.consume("$") {
+		return nil, fmt.Errorf("JSONPath %q 无效: 必须以 $ 开头", source)
+	}
+
+	steps, err := p.parseSteps()
+	if err == nil {
+		p.skipSpace()
+		if p.pos < len(p.s) {
+			err = fmt.Errorf("位置 %d 有多余的内容 %q", p.pos, p.s[p.pos:])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("JSONPath %q 无效: %w", source, err)
+	}
+	return &JSONPath{source: source, steps: steps}, nil
+}
+
+// MustCompileJSONPath 编译JSONPath表达式，无效时panic
+func MustCompileJSONPath(source string) *JSONPath {
+	p, err := CompileJSONPath(source)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String 返回表达式的原文
+func (p *JSONPath) String() string {
+	return p.source
+}
+
+// Definite 检查表达式是否最多选中一个值，即只由名称和下标组成
+func (p *JSONPath) Definite() bool {
+	for _, step := range p.steps {
+		if step.recursive || len(step.selectors) != 1 {
+			return false
+		}
+		if kind := step.selectors[0].kind; kind != selectName && kind != selectIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// Find 返回表达式在 doc 中选中的所有值
+func (p *JSONPath) Find(doc interface{}) []interface{} {
+	return evalJSONSteps(p.steps, doc, doc)
+}
+
+// ---- 求值 ----
+
+// jsonSelectorKind 选择器类型
+type jsonSelectorKind int
+
+const (
+	selectName jsonSelectorKind = iota
+	selectIndex
+	selectWildcard
+	selectSlice
+	selectFilter
+)
+
+// jsonSelector 一个选择器，如名称、下标、切片或过滤器
+type jsonSelector struct {
+	kind  jsonSelectorKind
+	name  string
+	index int
+
+	// 切片的起止位置和步长，未指定时为nil
+	start, end, step *int
+
+	filter jsonExpr
+}
+
+// jsonStep 一步：对当前值（递归下降时为当前值及其所有后代）应用选择器
+type jsonStep struct {
+	recursive bool
+	selectors []jsonSelector
+}
+
+// evalJSONSteps 从 value 开始依次应用每一步，root 为过滤器中 $ 指向的根节点
+func evalJSONSteps(steps []jsonStep, value, root interface{}) []interface{} {
+	values := []interface{}{value}
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range values {
+			candidates := []interface{}{v}
+			if step.recursive {
+				candidates = appendJSONDescendants(candidates, v)
+			}
+			for _, c := range candidates {
+				for _, sel := range step.selectors {
+					next = sel.apply(next, c, root)
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// apply 把选择器在 v 中选中的值追加到 out
+func (s *jsonSelector) apply(out []interface{}, v, root interface{}) []interface{} {
+	switch s.kind {
+	case selectName:
+		if obj, ok := v.(map[string]interface{}); ok {
+			if child, ok := obj[s.name]; ok {
+				out = append(out, child)
+			}
+		}
+	case selectIndex:
+		if arr, ok := v.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				out = append(out, arr[i])
+			}
+		}
+	case selectWildcard:
+		out = append(out, jsonChildren(v)...)
+	case selectSlice:
+		if arr, ok := v.([]interface{}); ok {
+			out = appendJSONSlice(out, arr, s.start, s.end, s.step)
+		}
+	case selectFilter:
+		for _, child := range jsonChildren(v) {
+			if jsonTruthy(s.filter.eval(child, root)) {
+				out = append(out, child)
+			}
+		}
+	}
+	return out
+}
+
+// jsonChildren 返回数组的元素或按键名排序的对象成员
+func jsonChildren(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		children := make([]interface{}, len(keys))
+		for i, k := range keys {
+			children[i] = v[k]
+		}
+		return children
+	}
+	return nil
+}
+
+// appendJSONDescendants 按先序追加 v 的所有后代
+func appendJSONDescendants(out []interface{}, v interface{}) []interface{} {
+	for _, child := range jsonChildren(v) {
+		out = append(out, child)
+		out = appendJSONDescendants(out, child)
+	}
+	return out
+}
+
+// appendJSONSlice 按Python切片的规则追加数组的一段
+func appendJSONSlice(out []interface{}, arr []interface{}, start, end, step *int) []interface{} {
+	n := len(arr)
+	stride := 1
+	if step != nil {
+		stride = *step
+	}
+	if stride == 0 {
+		return out
+	}
+
+	// 规范化位置：负数从末尾计算，再限制在有效范围内
+	low, high := 0, n
+	if stride < 0 {
+		low, high = -1, n-1
+	}
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		if i < low {
+			return low
+		}
+		if i > high {
+			return high
+		}
+		return i
+	}
+
+	if stride > 0 {
+		for i, stop := bound(start, 0), bound(end, n); i < stop; i += stride {
+			out = append(out, arr[i])
+		}
+	} else {
+		for i, stop := bound(start, n-1), bound(end, -1); i > stop; i += stride {
+			out = append(out, arr[i])
+		}
+	}
+	return out
+}
+
+// ---- 过滤器表达式 ----
+
+// jsonNothing 表示路径没有选中任何值
+type jsonNothing struct{}
+
+// jsonExpr 过滤器中的表达式
+type jsonExpr interface {
+	eval(current, root interface{}) interface{}
+}
+
+// jsonLiteral 字符串、数字、true、false 或 null
+type jsonLiteral struct {
+	value interface{}
+}
+
+func (e jsonLiteral) eval(_, _ interface{}) interface{} {
+	return e.value
+}
+
+// jsonPathOperand 以 @ 或 $ 开头的路径，取选中的第一个值
+type jsonPathOperand struct {
+	fromRoot bool
+	steps    []jsonStep
+}
+
+func (e *jsonPathOperand) eval(current, root interface{}) interface{} {
+	start := current
+	if e.fromRoot {
+		start = root
+	}
+	values := evalJSONSteps(e.steps, start, root)
+	if len(values) == 0 {
+		return jsonNothing{}
+	}
+	return values[0]
+}
+
+// jsonNot 逻辑非
+type jsonNot struct {
+	operand jsonExpr
+}
+
+func (e *jsonNot) eval(current, root interface{}) interface{} {
+	return !jsonTruthy(e.operand.eval(current, root))
+}
+
+// jsonBinary 逻辑或比较运算
+type jsonBinary struct {
+	op          string
+	left, right jsonExpr
+}
+
+func (e *jsonBinary) eval(current, root interface{}) interface{} {
+	left := e.left.eval(current, root)
+	switch e.op {
+	case "&&":
+		return jsonTruthy(left) && jsonTruthy(e.right.eval(current, root))
+	case "||":
+		return jsonTruthy(left) || jsonTruthy(e.right.eval(current, root))
+	}
+	return compareJSON(e.op, left, e.right.eval(current, root))
+}
+
+// jsonTruthy 过滤器中值的真假：路径没有选中值或值为false时为假，其他值（包括null）为真
+func jsonTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case jsonNothing:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+// compareJSON 比较两个值：数字按数值、字符串按字典序比较，其他类型只能判断是否相等。
+// 路径没有选中值时只与同样没有选中值的路径相等
+func compareJSON(op string, left, right interface{}) bool {
+	_, leftNothing := left.(jsonNothing)
+	_, rightNothing := right.(jsonNothing)
+	if leftNothing || rightNothing {
+		switch op {
+		case "==", "<=", ">=":
+			return leftNothing && rightNothing
+		case "!=":
+			return !(leftNothing && rightNothing)
+		}
+		return false
+	}
+
+	var cmp int
+	a, aNum := jsonNumber(left)
+	b, bNum := jsonNumber(right)
+	as, aStr := left.(string)
+	bs, bStr := right.(string)
+	switch {
+	case aNum && bNum:
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	case aStr && bStr:
+		cmp = strings.Compare(as, bs)
+	default:
+		equal := jsonEqual(left, right)
+		switch op {
+		case "==":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// jsonNumber 把数字转换为 float64，支持 json.Number
+func jsonNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// jsonEqual 比较布尔值、null、数组和对象是否相等
+func jsonEqual(a, b interface{}) bool {
+	ea, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	eb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ea) == string(eb)
+}
+
+// ---- 解析 ----
+
+// jsonPathParser JSONPath表达式的解析器
+type jsonPathParser struct {
+	s   string
+	pos int
+}
+
+// skipSpace 跳过空白
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.s) && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+// consume 当前位置是 prefix 时跳过它并返回true
+func (p *jsonPathParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+// parseSteps 解析 $ 或 @ 之后的各步，遇到不能继续路径的字符时停止
+func (p *jsonPathParser) parseSteps() ([]jsonStep, error) {
+	var steps []jsonStep
+	for p.pos < len(p.s) {
+		var step jsonStep
+		switch {
+		case p.consume(".."):
+			step.recursive = true
+			if p.pos < len(p.s) && p.s[p.pos] == '[' {
+				sels, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				step.selectors = sels
+			} else {
+				sel, err := p.parseDotName()
+				if err != nil {
+					return nil, err
+				}
+				step.selectors = []jsonSelector{sel}
+			}
+		case p.consume("."):
+			sel, err := p.parseDotName()
+			if err != nil {
+				return nil, err
+			}
+			step.selectors = []jsonSelector{sel}
+		case p.s[p.pos] == '[':
+			sels, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			step.selectors = sels
+		default:
+			return steps, nil
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// parseDotName 解析 . 之后的名称或 *
+func (p *jsonPathParser) parseDotName() (jsonSelector, error) {
+	if p.consume("*") {
+		return jsonSelector{kind: selectWildcard}, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if isLetter(c) || isDigit(c) || c == '_' || c == '-' || c == '$' || c >= 0x80 {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		return jsonSelector{}, fmt.Errorf("位置 %d 缺少成员名", start)
+	}
+	return jsonSelector{kind: selectName, name: p.s[start:p.pos]}, nil
+}
+
+// parseBracket 解析 [...] 中的选择器
+func (p *jsonPathParser) parseBracket() ([]jsonSelector, error) {
+	open := p.pos
+	p.pos++
+	p.skipSpace()
+
+	if p.consume("?") {
+		p.skipSpace()
+		parens := p.consume("(")
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if parens && !p.consume(")") {
+			return nil, fmt.Errorf("位置 %d 的过滤器缺少 )", p.pos)
+		}
+		if err := p.closeBracket(open); err != nil {
+			return nil, err
+		}
+		return []jsonSelector{{kind: selectFilter, filter: expr}}, nil
+	}
+
+	var sels []jsonSelector
+	for {
+		p.skipSpace()
+		sel, err := p.parseBracketItem()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+
+		p.skipSpace()
+		if !p.consume(",") {
+			break
+		}
+	}
+	if err := p.closeBracket(open); err != nil {
+		return nil, err
+	}
+	return sels, nil
+}
+
+// closeBracket 读取 ]
+func (p *jsonPathParser) closeBracket(open int) error {
+	p.skipSpace()
+	if !p.consume("]") {
+		return fmt.Errorf("位置 %d 的 [ 没有结束", open)
+	}
+	return nil
+}
+
+// parseBracketItem 解析 [] 中的一项：*、带引号的名称、下标或切片
+func (p *jsonPathParser) parseBracketItem() (jsonSelector, error) {
+	if p.pos >= len(p.s) {
+		return jsonSelector{}, fmt.Errorf("表达式意外结束")
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '*':
+		p.pos++
+		return jsonSelector{kind: selectWildcard}, nil
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return jsonSelector{}, err
+		}
+		return jsonSelector{kind: selectName, name: name}, nil
+	}
+
+	// 下标或切片，各部分都可以省略
+	var parts [3]*int
+	count := 0
+	for count < 3 {
+		p.skipSpace()
+		if n, ok := p.parseInt(); ok {
+			parts[count] = &n
+		}
+		count++
+		p.skipSpace()
+		if !p.consume(":") {
+			break
+		}
+	}
+
+	switch {
+	case count == 1 && parts[0] == nil:
+		return jsonSelector{}, fmt.Errorf("位置 %d 缺少下标", p.pos)
+	case count == 1:
+		return jsonSelector{kind: selectIndex, index: *parts[0]}, nil
+	}
+	return jsonSelector{kind: selectSlice, start: parts[0], end: parts[1], step: parts[2]}, nil
+}
+
+// parseInt 读取可能带负号的整数
+func (p *jsonPathParser) parseInt() (int, bool) {
+	start := p.pos
+	if p.pos < len(p.s) && p.s[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.s) && isDigit(p.s[p.pos]) {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return n, true
+}
+
+// parseString 读取单引号或双引号字符串，支持反斜杠转义
+func (p *jsonPathParser) parseString() (string, error) {
+	quote := p.s[p.pos]
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("位置 %d 的字符串没有结束", start)
+}
+
+// parseOr 解析 ||
+func (p *jsonPathParser) parseOr() (jsonExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonBinary{op: "||", left: left, right: right}
+	}
+}
+
+// parseAnd 解析 &&
+func (p *jsonPathParser) parseAnd() (jsonExpr, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonBinary{op: "&&", left: left, right: right}
+	}
+}
+
+// parseComparison 解析比较运算，没有运算符时是存在性或真值测试
+func (p *jsonPathParser) parseComparison() (jsonExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &jsonBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parseOperand 解析操作数：!、括号、路径或字面量
+func (p *jsonPathParser) parseOperand() (jsonExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("过滤器意外结束")
+	}
+
+	c := p.s[p.pos]
+	switch {
+	case c == '!':
+		p.pos++
+		operand, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNot{operand: operand}, nil
+	case c == '(':
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, fmt.Errorf("位置 %d 缺少 )", p.pos)
+		}
+		return expr, nil
+	case c == '@' || c == '$':
+		p.pos++
+		steps, err := p.parseSteps()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathOperand{fromRoot: c == '$', steps: steps}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return jsonLiteral{value: s}, nil
+	case c == '-' || isDigit(c):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || strings.IndexByte(".eE+-", p.s[p.pos]) >= 0) {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d 的数字无效: %s", start, p.s[start:p.pos])
+		}
+		return jsonLiteral{value: f}, nil
+	}
+
+	for _, keyword := range []struct {
+		text  string
+		value interface{}
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		if p.consume(keyword.text) {
+			return jsonLiteral{value: keyword.value}, nil
+		}
+	}
+	return nil, fmt.Errorf("位置 %d 有意外的字符 %q", p.pos, c)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// jsonPathTestDocument 测试用的JSON文档
+const jsonPathTestDocument = `{
+	"arr": [0, 1, 2, 3, 4, 5],
+	"items": [
+		{"id": 1, "x": null},
+		{"id": 2, "x": 0},
+		{"id": 3},
+		{"id": 4, "x": false},
+		{"id": 5, "x": "", "tags": ["a"]}
+	],
+	"big": 12345678901234567890
+}`
+
+// describeJSON 把选中的值编码为JSON并用空格连接
+func describeJSON(t *testing.T, values []interface{}) string {
+	t.Helper()
+	parts := make([]string, len(values))
+	for i, v := range values {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("编码 %v 失败: %v", v, err)
+		}
+		parts[i] = string(encoded)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestJSONPathFind(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(jsonPathTestDocument))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		t.Fatalf("解码文档失败: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		// 下标和并集
+		{"$.arr[0]", "0"},
+		{"$.arr[-1]", "5"},
+		{"$.arr[6]", ""},
+		{"$.arr[0,2,-1]", "0 2 5"},
+		{"$.big", "12345678901234567890"},
+
+		// 正步长切片
+		{"$.arr[1:3]", "1 2"},
+		{"$.arr[:2]", "0 1"},
+		{"$.arr[-2:]", "4 5"},
+		{"$.arr[::2]", "0 2 4"},
+		{"$.arr[4:1]", ""},
+		{"$.arr[-10:10]", "0 1 2 3 4 5"},
+
+		// 负步长切片：省略的起点为末尾、终点为开头之前
+		{"$.arr[::-1]", "5 4 3 2 1 0"},
+		{"$.arr[::-2]", "5 3 1"},
+		{"$.arr[4:1:-1]", "4 3 2"},
+		{"$.arr[-1:-4:-1]", "5 4 3"},
+		{"$.arr[:2:-1]", "5 4 3"},
+		{"$.arr[2::-1]", "2 1 0"},
+		{"$.arr[10:0:-3]", "5 2"},
+		{"$.arr[1:4:-1]", ""},
+		{"$.arr[::0]", ""},
+
+		// 过滤器：值为null的成员也存在，只有false和不存在为假
+		{"$.items[?(@.x)].id", "1 2 5"},
+		{"$.items[?(!@.x)].id", "3 4"},
+		{"$.items[?(@.x == null)].id", "1"},
+		{"$.items[?(@.x != null)].id", "2 3 4 5"},
+		{"$.items[?(@.x == 0)].id", "2"},
+		{"$.items[?(@.x == '')].id", "5"},
+		{"$.items[?(@.x == false)].id", "4"},
+		{"$.items[?(@.id > 2 && @.x)].id", "5"},
+		{"$.items[?(@.id < 2 || @.tags)].id", "1 5"},
+		{"$.items[?(@.tags[0] == 'a')].id", "5"},
+		{"$.items[?(@.id == $.arr[3])].id", "3"},
+
+		// 通配符和递归下降，对象成员按键名排序
+		{"$.items[4].*", `5 ["a"] ""`},
+		{"$..tags[*]", `"a"`},
+		{"$.items[?(@.id > 3)]..id", "4 5"},
+	}
+	for _, tt := range tests {
+		path, err := CompileJSONPath(tt.path)
+		if err != nil {
+			t.Errorf("编译 %s 失败: %v", tt.path, err)
+			continue
+		}
+		if got := describeJSON(t, path.Find(doc)); got != tt.want {
+			t.Errorf("%s = %s，期望 %s", tt.path, got, tt.want)
+		}
+	}
+}
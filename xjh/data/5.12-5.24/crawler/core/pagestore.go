@@ -0,0 +1,186 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ParserField 结果中记录解析器版本的字段
+const ParserField = "parser"
+
+// VersionedParser 是带有版本号的解析器，版本号会记录在每个结果中
+type VersionedParser interface {
+	Parser
+
+	// 返回解析器的名称和版本，如 "default/1.0"
+	Version() string
+}
+
+// ParserVersion 返回解析器的版本；没有实现 VersionedParser 时使用类型名
+func ParserVersion(parser Parser) string {
+	if p, ok := parser.(VersionedParser); ok {
+		return p.Version()
+	}
+	return fmt.Sprintf("%T", parser)
+}
+
+// annotateResults 为结果添加内容哈希和解析器版本，在线爬取和重新解析共用
+func annotateResults(results []Result, contentHash string, parser Parser) {
+	version := ParserVersion(parser)
+	for _, result := range results {
+		if result.Data != nil {
+			result.Data[ContentHashField] = contentHash
+			result.Data[ParserField] = version
+		}
+	}
+}
+
+// PageStore 在磁盘上保存抓取到的原始页面，改进解析器后可以重新解析而无需再次抓取。
+// 每个页面连同请求方式按 RequestKey 的SHA-256保存为一个文件，同一请求的新页面会覆盖旧页面，
+// 同一接口不同请求体的页面（如POST分页）分别保存
+type PageStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewPageStore 创建原始页面存储
+func NewPageStore(dir string) (*PageStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+	return &PageStore{dir: dir}, nil
+}
+
+// path 返回页面文件的路径，按哈希前两位分目录
+func (s *PageStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, name[:2], name+".page")
+}
+
+// storedRequest 与页面一起保存的请求：请求的URL和请求方式
+type storedRequest struct {
+	Address string
+	Request Request
+}
+
+// Save 保存请求 url 得到的页面
+func (s *PageStore) Save(url *URL, page *Page) error {
+	path := s.path(RequestKey(url))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer file.Close()
+
+	// 页面之后是请求，只有页面的旧文件按对页面URL的GET请求读取
+	encoder := gob.NewEncoder(file)
+	if err := encoder.Encode(page); err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+	if err := encoder.Encode(storedRequest{Address: url.Address, Request: RequestOf(url)}); err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+	return nil
+}
+
+// Load 读取 RequestKey 对应的页面，同时返回请求的URL，其元数据中带有请求方式
+func (s *PageStore) Load(key string) (*Page, *URL, error) {
+	return loadPage(s.path(key))
+}
+
+// Each 按文件路径顺序遍历所有页面及其请求的URL，fn 返回错误时停止遍历
+func (s *PageStore) Each(fn func(page *Page, url *URL) error) error {
+	var paths []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".page" {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("遍历页面失败: %w", err)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		page, url, err := loadPage(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(page, url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPage 解码页面文件
+func loadPage(path string) (*Page, *URL, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(file)
+	var page Page
+	if err := decoder.Decode(&page); err != nil {
+		return nil, nil, fmt.Errorf("解码页面失败 %s: %w", path, err)
+	}
+	stored := storedRequest{Address: page.URL, Request: Request{Method: http.MethodGet}}
+	if err := decoder.Decode(&stored); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("解码页面失败 %s: %w", path, err)
+	}
+	return &page, stored.url(), nil
+}
+
+// url 还原请求的URL，请求方式记录在元数据中，普通的GET请求没有元数据
+func (r storedRequest) url() *URL {
+	u := &URL{Address: r.Address}
+	if r.Request.IsGet() && len(r.Request.Headers) == 0 {
+		return u
+	}
+	u.Metadata = map[string]interface{}{
+		MetadataMethod:  r.Request.Method,
+		MetadataBody:    r.Request.Body,
+		MetadataHeaders: r.Request.Headers,
+	}
+	return u
+}
+
+// Reparse 用解析器重新解析存储的所有页面，把结果写入 storage（与在线爬取一样以 RequestKey 为键，
+// 覆盖同一请求的旧结果，新解析器没有提取出结果时删除旧结果），返回解析的页面数。
+// 结果与在线爬取一样带有内容哈希和解析器版本
+func Reparse(store *PageStore, parser Parser, storage Storage) (int, error) {
+	count := 0
+	err := store.Each(func(page *Page, url *URL) error {
+		results, _ := parser.Parse(page)
+		annotateResults(results, ContentHash(page.Content), parser)
+		key := RequestKey(url)
+		if err := storage.Store(key, results); err != nil {
+			return fmt.Errorf("存储结果失败 %s: %w", key, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
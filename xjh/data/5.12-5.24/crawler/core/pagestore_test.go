@@ -0,0 +1,107 @@
+package core
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// contentParser 把页面内容作为唯一的结果
+type contentParser struct{}
+
+func (contentParser) Parse(page *Page) ([]Result, []string) {
+	return []Result{{Type: "page", Data: map[string]interface{}{"content": string(page.Content)}}}, nil
+}
+
+// postURL 返回以 body 为请求体的POST请求
+func postURL(address, body string) *URL {
+	return &URL{Address: address, Metadata: map[string]interface{}{
+		MetadataMethod:  "POST",
+		MetadataBody:    body,
+		MetadataHeaders: map[string]string{"Content-Type": "application/json"},
+	}}
+}
+
+func TestPageStoreKeysByRequest(t *testing.T) {
+	store, err := NewPageStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建原始页面存储失败: %v", err)
+	}
+
+	const api = "http://example.com/api"
+	requests := map[string]*URL{
+		"get":   {Address: api},
+		"page1": postURL(api, `{"page":1}`),
+		"page2": postURL(api, `{"page":2}`),
+	}
+	for content, url := range requests {
+		if err := store.Save(url, &Page{URL: api, Content: []byte(content), StatusCode: 200}); err != nil {
+			t.Fatalf("保存页面失败: %v", err)
+		}
+	}
+
+	// 同一接口不同请求体的页面互不覆盖，读取时还原请求方式
+	for content, url := range requests {
+		page, loaded, err := store.Load(RequestKey(url))
+		if err != nil {
+			t.Fatalf("读取 %s 失败: %v", RequestKey(url), err)
+		}
+		if string(page.Content) != content {
+			t.Errorf("%s 的内容为 %q，期望 %q", RequestKey(url), page.Content, content)
+		}
+		if RequestKey(loaded) != RequestKey(url) {
+			t.Errorf("还原的请求键为 %s，期望 %s", RequestKey(loaded), RequestKey(url))
+		}
+		if got, want := RequestOf(loaded).Headers["Content-Type"], RequestOf(url).Headers["Content-Type"]; got != want {
+			t.Errorf("%s 还原的请求头为 %q，期望 %q", RequestKey(url), got, want)
+		}
+	}
+
+	// 重新解析的结果与在线爬取一样以 RequestKey 为键
+	storage := NewMemoryStorage()
+	count, err := Reparse(store, contentParser{}, storage)
+	if err != nil {
+		t.Fatalf("重新解析失败: %v", err)
+	}
+	if count != len(requests) {
+		t.Errorf("重新解析了 %d 个页面，期望 %d", count, len(requests))
+	}
+	for content, url := range requests {
+		results, ok := storage.Get(RequestKey(url))
+		if !ok || len(results) != 1 || results[0].Data["content"] != content {
+			t.Errorf("%s 的结果为 %v，期望内容 %q", RequestKey(url), results, content)
+		}
+	}
+}
+
+func TestPageStoreLoadsPageOnlyFile(t *testing.T) {
+	store, err := NewPageStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建原始页面存储失败: %v", err)
+	}
+
+	// 旧版本只保存页面，按对页面URL的GET请求读取
+	const address = "http://example.com/old"
+	path := store.path(address)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	err = gob.NewEncoder(file).Encode(&Page{URL: address, Content: []byte("old")})
+	file.Close()
+	if err != nil {
+		t.Fatalf("编码页面失败: %v", err)
+	}
+
+	page, url, err := store.Load(address)
+	if err != nil {
+		t.Fatalf("读取旧页面失败: %v", err)
+	}
+	if string(page.Content) != "old" || url.Address != address || !RequestOf(url).IsGet() {
+		t.Errorf("读取到 %q，请求 %s %s，期望GET请求的旧页面", page.Content, RequestOf(url).Method, url.Address)
+	}
+}
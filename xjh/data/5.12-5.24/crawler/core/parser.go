@@ -1,6 +1,8 @@
 package core
 
 import (
+	"fmt"
+	"io"
 	"regexp"
 	"strings"
 )
@@ -13,6 +15,9 @@ type DefaultParser struct {
 	// 标题提取正则表达式
 	titleRegex *regexp.Regexp
 
+	// 图片提取正则表达式
+	imageRegex *regexp.Regexp
+
 	// 忽略的URL后缀
 	ignoreSuffixes []string
 }
@@ -22,6 +27,7 @@ func NewDefaultParser() *DefaultParser {
 	return &DefaultParser{
 		linkRegex:  regexp.MustCompile(`<a\s+[^>]*href="([^"]+)"[^>]*>`),
 		titleRegex: regexp.MustCompile(`<title[^>]*>(.*?)</title>`),
+		imageRegex: regexp.MustCompile(`<img\s+[^>]*src="([^"]+)"[^>]*>`),
 		ignoreSuffixes: []string{
 			".jpg", ".jpeg", ".png", ".gif", ".pdf", ".zip", ".tar.gz",
 			".css", ".js", ".xml", ".json", ".mp3", ".mp4", ".avi", ".mov",
@@ -29,6 +35,11 @@ func NewDefaultParser() *DefaultParser {
 	}
 }
 
+// Version 实现VersionedParser接口，解析规则变化时应更新版本号
+func (p *DefaultParser) Version() string {
+	return "default/1.0"
+}
+
 // Parse 实现Parser接口，解析HTML页面内容
 func (p *DefaultParser) Parse(page *Page) ([]Result, []string) {
 	if page == nil || len(page.Content) == 0 {
@@ -49,48 +60,133 @@ func (p *DefaultParser) extractLinks(page *Page) []string {
 	matches := p.linkRegex.FindAllSubmatch(page.Content, -1)
 	uniqueLinks := make(map[string]bool)
 
-	baseURL := page.URL
-
 	for _, match := range matches {
 		if len(match) < 2 {
 			continue
 		}
 
-		href := string(match[1])
-
-		// 忽略空链接
-		if href == "" || href == "#" || strings.HasPrefix(href, "javascript:") {
-			continue
+		if link, ok := p.pageLink(page.URL, string(match[1])); ok {
+			uniqueLinks[link] = true
 		}
+	}
 
-		// 忽略指定后缀的链接
-		if p.hasSuffix(href, p.ignoreSuffixes) {
-			continue
+	// 转换为切片
+	links := make([]string, 0, len(uniqueLinks))
+	for link := range uniqueLinks {
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// pageLink 把页面中的链接转换为要爬取的绝对URL，需要忽略的链接返回false
+func (p *DefaultParser) pageLink(baseURL, href string) (string, bool) {
+	// 忽略空链接
+	if href == "" || href == "#" || strings.HasPrefix(href, "javascript:") {
+		return "", false
+	}
+
+	// 忽略指定后缀的链接
+	if p.hasSuffix(href, p.ignoreSuffixes) {
+		return "", false
+	}
+
+	// 处理相对链接
+	absURL := p.resolveURL(baseURL, href)
+
+	// 将URL标准化
+	return p.normalizeURL(absURL), true
+}
+
+// ParseStream 实现StreamParser接口，用流式词法分析器提取标题和链接，
+// 不需要把整个页面读入内存
+func (p *DefaultParser) ParseStream(page *Page, body io.Reader) ([]Result, []string, error) {
+	tokenizer := NewTokenizer(body)
+	uniqueLinks := make(map[string]bool)
+
+	var title strings.Builder
+	inTitle, titleDone := false, false
+	for {
+		token, err := tokenizer.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取页面失败: %w", err)
 		}
 
-		// 处理相对链接
-		absURL := p.resolveURL(baseURL, href)
+		switch token.Type {
+		case StartTagToken, SelfClosingTagToken:
+			switch token.Data {
+			case "a":
+				if link, ok := p.pageLink(page.URL, token.Attr("href")); ok {
+					uniqueLinks[link] = true
+				}
+			case "title":
+				// 只取第一个标题
+				inTitle = !titleDone && token.Type == StartTagToken
+			}
+		case EndTagToken:
+			if token.Data == "title" && inTitle {
+				inTitle, titleDone = false, true
+			}
+		case TextToken:
+			if inTitle && title.Len() < maxTextChunk {
+				title.WriteString(token.Data)
+			}
+		}
+	}
 
-		// 将URL标准化
-		absURL = p.normalizeURL(absURL)
+	if tokenizer.BytesRead() == 0 {
+		return nil, nil, nil
+	}
 
-		// 保存唯一链接
-		uniqueLinks[absURL] = true
+	if page.Title == "" {
+		page.Title = title.String()
 	}
 
-	// 转换为切片
 	links := make([]string, 0, len(uniqueLinks))
 	for link := range uniqueLinks {
 		links = append(links, link)
 	}
 
-	return links
+	return p.pageResults(page, page.Title, int(tokenizer.BytesRead())), links, nil
+}
+
+// Assets 实现AssetParser接口，返回因后缀被忽略的链接和图片地址
+func (p *DefaultParser) Assets(page *Page) []string {
+	if page == nil || len(page.Content) == 0 {
+		return nil
+	}
+
+	var hrefs []string
+	for _, match := range p.linkRegex.FindAllSubmatch(page.Content, -1) {
+		if href := string(match[1]); p.hasSuffix(href, p.ignoreSuffixes) {
+			hrefs = append(hrefs, href)
+		}
+	}
+	for _, match := range p.imageRegex.FindAllSubmatch(page.Content, -1) {
+		hrefs = append(hrefs, string(match[1]))
+	}
+
+	uniqueAssets := make(map[string]bool)
+	assets := make([]string, 0, len(hrefs))
+	for _, href := range hrefs {
+		if strings.HasPrefix(href, "data:") {
+			continue
+		}
+		asset := p.normalizeURL(p.resolveURL(page.URL, href))
+		if !uniqueAssets[asset] {
+			uniqueAssets[asset] = true
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets
 }
 
 // extractResults 从页面中提取结果
 func (p *DefaultParser) extractResults(page *Page) []Result {
-	var results []Result
-
 	// 提取标题
 	title := page.Title
 	if title == "" {
@@ -101,21 +197,24 @@ func (p *DefaultParser) extractResults(page *Page) []Result {
 		}
 	}
 
-	// 创建基本结果
-	if title != "" {
-		result := Result{
-			Type: "page",
-			Data: map[string]interface{}{
-				"url":       page.URL,
-				"title":     title,
-				"length":    len(page.Content),
-				"timestamp": page.Timestamp,
-			},
-		}
-		results = append(results, result)
+	return p.pageResults(page, title, len(page.Content))
+}
+
+// pageResults 创建页面的基本结果，没有标题时不产生结果
+func (p *DefaultParser) pageResults(page *Page, title string, length int) []Result {
+	if title == "" {
+		return nil
 	}
 
-	return results
+	return []Result{{
+		Type: "page",
+		Data: map[string]interface{}{
+			"url":       page.URL,
+			"title":     title,
+			"length":    length,
+			"timestamp": page.Timestamp,
+		},
+	}}
 }
 
 // hasSuffix 检查URL是否有指定的后缀
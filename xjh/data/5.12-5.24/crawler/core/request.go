@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// URL.Metadata 中描述请求方式的键，设置后抓取器按指定的方法、请求体和请求头发送请求，
+// 用于POST等非GET方式的接口
+const (
+	// 请求方法，string，默认为GET
+	MetadataMethod = "method"
+
+	// 请求体，string 或 []byte
+	MetadataBody = "body"
+
+	// 附加的请求头，map[string]string
+	MetadataHeaders = "headers"
+)
+
+// Request 由URL元数据描述的请求方式
+type Request struct {
+	Method  string
+	Body    []byte
+	Headers map[string]string
+}
+
+// RequestOf 从URL元数据中读取请求方式，没有设置时为GET请求
+func RequestOf(url *URL) Request {
+	req := Request{Method: http.MethodGet}
+	if url == nil {
+		return req
+	}
+
+	if method, ok := url.Metadata[MetadataMethod].(string); ok && method != "" {
+		req.Method = strings.ToUpper(method)
+	}
+	switch body := url.Metadata[MetadataBody].(type) {
+	case string:
+		req.Body = []byte(body)
+	case []byte:
+		req.Body = body
+	}
+	switch headers := url.Metadata[MetadataHeaders].(type) {
+	case map[string]string:
+		req.Headers = headers
+	case map[string]interface{}:
+		// 从JSON文件恢复的元数据
+		req.Headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			if s, ok := v.(string); ok {
+				req.Headers[k] = s
+			}
+		}
+	}
+	return req
+}
+
+// IsGet 检查是否是没有请求体的GET请求，只有这类请求使用条件请求和响应缓存
+func (r Request) IsGet() bool {
+	return r.Method == http.MethodGet && len(r.Body) == 0
+}
+
+// RequestKey 返回URL的去重和存储键：GET请求为URL本身，其他请求由方法、URL和请求体的哈希组成，
+// 同一接口不同请求体的请求（如POST分页）互不影响
+func RequestKey(url *URL) string {
+	req := RequestOf(url)
+	if req.IsGet() {
+		return url.Address
+	}
+	sum := sha256.Sum256(req.Body)
+	return req.Method + " " + url.Address + " " + hex.EncodeToString(sum[:8])
+}
+
+// requestMetadata 复制元数据中描述请求方式的键，没有这些键时返回nil
+func requestMetadata(metadata map[string]interface{}) map[string]interface{} {
+	var copied map[string]interface{}
+	for _, key := range []string{MetadataMethod, MetadataBody, MetadataHeaders} {
+		if v, ok := metadata[key]; ok {
+			if copied == nil {
+				copied = make(map[string]interface{})
+			}
+			copied[key] = v
+		}
+	}
+	return copied
+}
+
+// requestContextKey context 中保存请求方式的键
+type requestContextKey struct{}
+
+// ContextWithRequest 返回携带请求方式的 context，引擎抓取每个URL时用它把请求方式传给抓取器
+func ContextWithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, req)
+}
+
+// RequestFromContext 返回 context 中的请求方式，没有时为GET请求
+func RequestFromContext(ctx context.Context) Request {
+	if req, ok := ctx.Value(requestContextKey{}).(Request); ok {
+		return req
+	}
+	return Request{Method: http.MethodGet}
+}
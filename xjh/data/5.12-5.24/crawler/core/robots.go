@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robots.txt 的读取上限、缓存时间和暂时无法获取时的重试间隔
+const (
+	maxRobotsSize = 500 << 10
+	robotsTTL     = 24 * time.Hour
+	robotsRetry   = time.Minute
+)
+
+// ErrRobotsUnavailable 站点的robots.txt暂时无法获取（服务器错误或网络错误），稍后重试
+var ErrRobotsUnavailable = errors.New("robots.txt暂时无法获取")
+
+// RobotsRules 解析后的robots.txt
+type RobotsRules struct {
+	groups []*robotsGroup
+}
+
+// robotsGroup 一个规则组，适用于组内列出的所有User-agent
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// robotsRule 一条 Allow 或 Disallow 规则，路径支持 * 通配和结尾的 $
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// ParseRobots 解析robots.txt内容，无法识别的行被忽略
+func ParseRobots(content []byte) *RobotsRules {
+	rules := &RobotsRules{}
+	var group *robotsGroup
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 4096), maxRobotsSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// 规则之后的User-agent开始一个新的组，连续的User-agent共用一个组
+			if group == nil || inRules {
+				group = &robotsGroup{}
+				rules.groups = append(rules.groups, group)
+				inRules = false
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if group == nil {
+				continue
+			}
+			inRules = true
+			// 空的 Disallow 表示允许全部
+			if value != "" {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		}
+	}
+	return rules
+}
+
+// Allowed 检查 userAgent 是否可以抓取路径，path 包括查询字符串。
+// 使用与UA的产品名（如 GoCrawler/1.0 中的 GoCrawler）相同的规则组，没有时使用 * 组；
+// 最长的匹配规则生效，长度相同时 Allow 优先
+func (r *RobotsRules) Allowed(userAgent, path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	rules := r.rulesFor(userAgent)
+	best := -1
+	for i, rule := range rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if best == -1 || len(rule.pattern) > len(rules[best].pattern) ||
+			(len(rule.pattern) == len(rules[best].pattern) && rule.allow) {
+			best = i
+		}
+	}
+	return best == -1 || rules[best].allow
+}
+
+// rulesFor 合并适用于UA的所有规则组
+func (r *RobotsRules) rulesFor(userAgent string) []robotsRule {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var matched, fallback []robotsRule
+	found := false
+	for _, group := range r.groups {
+		switch {
+		case token != "" && group.has(token):
+			matched = append(matched, group.rules...)
+			found = true
+		case group.has("*"):
+			fallback = append(fallback, group.rules...)
+		}
+	}
+	if found {
+		return matched
+	}
+	return fallback
+}
+
+// has 检查规则组是否列出了该User-agent
+func (g *robotsGroup) has(agent string) bool {
+	for _, a := range g.agents {
+		if a == agent {
+			return true
+		}
+	}
+	return false
+}
+
+// robotsMatch 用规则的路径模式匹配路径，* 匹配任意字符，结尾的 $ 要求匹配到路径末尾
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if anchored && i == len(parts)-1 {
+			return len(path)-pos >= len(part) && strings.HasSuffix(path, part)
+		}
+		j := strings.Index(path[pos:], part)
+		if j < 0 {
+			return false
+		}
+		pos += j + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+// robotsEntry 一个站点的robots.txt缓存，rules 为nil表示暂时无法获取
+type robotsEntry struct {
+	rules   *RobotsRules
+	expires time.Time
+
+	// 获取完成后关闭，同一站点的并发检查只获取一次
+	ready chan struct{}
+}
+
+// RobotsChecker 按站点获取并缓存robots.txt，检查URL是否允许抓取。
+// 规则组按 HTTPFetcher 发往该主机的User-Agent选择，与实际发送的UA一致
+type RobotsChecker struct {
+	fetcher *HTTPFetcher
+	entries map[string]*robotsEntry
+	mu      sync.Mutex
+}
+
+// NewRobotsChecker 创建robots.txt检查器，robots.txt 通过 fetcher 获取
+func NewRobotsChecker(fetcher *HTTPFetcher) *RobotsChecker {
+	return &RobotsChecker{
+		fetcher: fetcher,
+		entries: make(map[string]*robotsEntry),
+	}
+}
+
+// Allowed 检查URL是否允许抓取，非HTTP的URL总是允许。
+// robots.txt 暂时无法获取或 ctx 已取消时返回 ErrRobotsUnavailable，调用方应稍后重试而不是放弃URL
+func (c *RobotsChecker) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true, nil
+	}
+
+	rules := c.rules(ctx, u.Scheme+"://"+u.Host)
+	if rules == nil {
+		return false, ErrRobotsUnavailable
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.Allowed(c.fetcher.UserAgent(u.Host), path), nil
+}
+
+// rules 返回站点的robots.txt规则，缓存过期或不存在时获取，暂时无法获取时返回nil
+func (c *RobotsChecker) rules(ctx context.Context, site string) *RobotsRules {
+	c.mu.Lock()
+	entry, ok := c.entries[site]
+	if !ok || (entry.done() && time.Now().After(entry.expires)) {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		c.entries[site] = entry
+		c.mu.Unlock()
+
+		entry.rules, entry.expires = c.fetch(ctx, site)
+		close(entry.ready)
+		return entry.rules
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.rules
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// done 检查获取是否已完成，完成后才能读取规则和过期时间
+func (e *robotsEntry) done() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// fetch 获取并解析站点的robots.txt，返回规则和缓存的过期时间。
+// 不存在（4xx）时允许全部；服务器错误或网络错误时返回nil，robotsRetry 后重试
+func (c *RobotsChecker) fetch(ctx context.Context, site string) (*RobotsRules, time.Time) {
+	now := time.Now()
+	resp, err := c.fetcher.Open(ctx, site+"/robots.txt", nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			// 爬取已停止，不缓存结果
+			return nil, now
+		}
+		fmt.Printf("获取robots.txt失败 %s: %v\n", site, err)
+		return nil, now.Add(robotsRetry)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		fmt.Printf("获取robots.txt失败 %s: HTTP状态码异常: %d\n", site, resp.StatusCode)
+		return nil, now.Add(robotsRetry)
+	case resp.StatusCode != http.StatusOK:
+		return &RobotsRules{}, now.Add(robotsTTL)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		fmt.Printf("读取robots.txt失败 %s: %v\n", site, err)
+		return nil, now.Add(robotsRetry)
+	}
+	return ParseRobots(content), now.Add(robotsTTL)
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRobotsRulesAllowed(t *testing.T) {
+	rules := ParseRobots([]byte(`# 示例
+User-agent: *
+Disallow: /private/
+Disallow: /*.pdf$
+Allow: /private/public
+
+User-agent: GoCrawler
+User-agent: OtherBot
+Disallow: /search
+Disallow:
+
+User-agent: EmptyBot
+Disallow:
+`))
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"Mozilla/5.0", "/", true},
+		{"Mozilla/5.0", "/private/page", false},
+		{"Mozilla/5.0", "/private/public/page", true},
+		{"Mozilla/5.0", "/docs/a.pdf", false},
+		{"Mozilla/5.0", "/docs/a.pdf?x=1", true},
+		{"Mozilla/5.0", "/robots.txt", true},
+		// 有专门的组时不使用 * 组
+		{"GoCrawler/1.0 (+https://example.com/bot)", "/private/page", true},
+		{"GoCrawler/1.0", "/search?q=go", false},
+		{"otherbot", "/search", false},
+		{"EmptyBot/2", "/private/page", true},
+	}
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.agent, tt.path); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v，期望 %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/a", "/a", true},
+		{"/a", "/ab/c", true},
+		{"/a$", "/ab", false},
+		{"/a$", "/a", true},
+		{"/*/edit", "/page/edit", true},
+		{"/*/edit", "/edit", false},
+		{"/*.php$", "/x/index.php", true},
+		{"/*.php$", "/x/index.php?a", false},
+		{"/a*b*c$", "/a-b-b-c", true},
+		{"/a*b*c$", "/a-c-b", false},
+	}
+	for _, tt := range tests {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsMatch(%q, %q) = %v，期望 %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsCheckerUnavailable(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+	}))
+	defer server.Close()
+
+	// 服务器错误时不能当作禁止抓取，而是返回 ErrRobotsUnavailable 让调用方推迟
+	checker := NewRobotsChecker(NewHTTPFetcher(5 * time.Second))
+	allowed, err := checker.Allowed(context.Background(), server.URL+"/page")
+	if !errors.Is(err, ErrRobotsUnavailable) || allowed {
+		t.Fatalf("服务器错误时 Allowed = %v, %v，期望 ErrRobotsUnavailable", allowed, err)
+	}
+
+	// 恢复后按规则检查
+	status = http.StatusOK
+	checker = NewRobotsChecker(NewHTTPFetcher(5 * time.Second))
+	if allowed, err := checker.Allowed(context.Background(), server.URL+"/private/a"); err != nil || allowed {
+		t.Errorf("/private/a 的检查结果为 %v, %v，期望禁止", allowed, err)
+	}
+	if allowed, err := checker.Allowed(context.Background(), server.URL+"/page"); err != nil || !allowed {
+		t.Errorf("/page 的检查结果为 %v, %v，期望允许", allowed, err)
+	}
+
+	// robots.txt 不存在时允许全部
+	status = http.StatusNotFound
+	checker = NewRobotsChecker(NewHTTPFetcher(5 * time.Second))
+	if allowed, err := checker.Allowed(context.Background(), server.URL+"/private/a"); err != nil || !allowed {
+		t.Errorf("robots.txt 不存在时检查结果为 %v, %v，期望允许", allowed, err)
+	}
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// MetadataRecrawl 调度器送回队列的URL的元数据键，值为之前的访问次数
+const MetadataRecrawl = "recrawl"
+
+// IsRecrawl 检查URL是否是调度器送回的重爬请求
+func IsRecrawl(url *URL) bool {
+	_, ok := url.Metadata[MetadataRecrawl]
+	return ok
+}
+
+// RecrawlOptions 自适应重爬调度配置
+type RecrawlOptions struct {
+	// 两次访问之间的最短间隔
+	MinInterval time.Duration
+
+	// 两次访问之间的最长间隔
+	MaxInterval time.Duration
+
+	// 第一次访问后，尚无历史数据时使用的间隔
+	InitialInterval time.Duration
+}
+
+// recrawlEntry 一个URL的抓取历史
+type recrawlEntry struct {
+	url *URL
+
+	// 第一次和最近一次访问时间
+	firstVisit time.Time
+	lastVisit  time.Time
+
+	// 下次访问时间
+	nextVisit time.Time
+
+	// 访问次数和观察到的变化次数
+	visits  int
+	changes int
+
+	// 上次的内容哈希和Last-Modified
+	lastHash     string
+	lastModified string
+
+	// 在堆中的位置，-1表示当前不在堆中
+	index int
+}
+
+// recrawlHeap 按下次访问时间排序的最小堆
+type recrawlHeap []*recrawlEntry
+
+func (h recrawlHeap) Len() int           { return len(h) }
+func (h recrawlHeap) Less(i, j int) bool { return h[i].nextVisit.Before(h[j].nextVisit) }
+func (h recrawlHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *recrawlHeap) Push(x interface{}) {
+	entry := x.(*recrawlEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *recrawlHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*h = old[:n-1]
+	return entry
+}
+
+// RecrawlScheduler 根据每个URL观察到的变化频率安排下次访问时间，
+// 并持续把到期的URL送回引擎的队列
+type RecrawlScheduler struct {
+	options RecrawlOptions
+
+	entries map[string]*recrawlEntry
+	due     recrawlHeap
+
+	// 有新的URL加入时唤醒调度循环
+	wake chan struct{}
+
+	mu sync.Mutex
+}
+
+// NewRecrawlScheduler 创建一个新的重爬调度器
+func NewRecrawlScheduler(options RecrawlOptions) *RecrawlScheduler {
+	if options.MinInterval <= 0 {
+		options.MinInterval = time.Minute
+	}
+	if options.MaxInterval < options.MinInterval {
+		options.MaxInterval = options.MinInterval
+	}
+	if options.InitialInterval <= 0 {
+		options.InitialInterval = options.MinInterval
+	}
+
+	return &RecrawlScheduler{
+		options: options,
+		entries: make(map[string]*recrawlEntry),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Record 记录一次抓取结果并安排下次访问。
+// contentHash 或 lastModified 与上次不同即视为页面发生了变化；
+// 页面返回304时两者都传空字符串，视为未变化
+func (s *RecrawlScheduler) Record(url *URL, contentHash, lastModified string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(url)
+	if entry.visits == 0 {
+		entry.firstVisit = now
+	} else if (contentHash != "" && entry.lastHash != "" && contentHash != entry.lastHash) ||
+		(lastModified != "" && entry.lastModified != "" && lastModified != entry.lastModified) {
+		entry.changes++
+	}
+
+	entry.visits++
+	entry.lastVisit = now
+	if contentHash != "" {
+		entry.lastHash = contentHash
+	}
+	if lastModified != "" {
+		entry.lastModified = lastModified
+	}
+	entry.nextVisit = now.Add(s.interval(entry))
+
+	if entry.index >= 0 {
+		heap.Fix(&s.due, entry.index)
+	} else {
+		heap.Push(&s.due, entry)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RecordFailure 记录一次失败的抓取，在最小间隔后重试
+func (s *RecrawlScheduler) RecordFailure(url *URL, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(url)
+	if entry.index >= 0 {
+		return
+	}
+
+	entry.nextVisit = now.Add(s.options.MinInterval)
+	heap.Push(&s.due, entry)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// interval 估计URL的变化率并计算下次访问的间隔，调用方需持有锁。
+// 变化率按 (变化次数+0.5)/观察时长 估计，间隔取其倒数，并限制在最小和最大间隔之间
+func (s *RecrawlScheduler) interval(entry *recrawlEntry) time.Duration {
+	observed := entry.lastVisit.Sub(entry.firstVisit)
+	if entry.visits < 2 || observed <= 0 {
+		return s.options.InitialInterval
+	}
+
+	interval := time.Duration(float64(observed) / (float64(entry.changes) + 0.5))
+	if interval < s.options.MinInterval {
+		interval = s.options.MinInterval
+	}
+	if interval > s.options.MaxInterval {
+		interval = s.options.MaxInterval
+	}
+	return interval
+}
+
+// entry 返回URL的调度记录，没有时创建，同一URL的不同请求（如POST分页）分别调度。
+// 调用方必须持有锁
+func (s *RecrawlScheduler) entry(url *URL) *recrawlEntry {
+	key := RequestKey(url)
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &recrawlEntry{
+			url:   &URL{Address: url.Address, Depth: url.Depth, Parent: url.Parent, Metadata: requestMetadata(url.Metadata)},
+			index: -1,
+		}
+		s.entries[key] = entry
+	}
+	return entry
+}
+
+// NextVisit 返回URL的下次访问时间，非GET请求的 url 为 RequestKey 返回的键
+func (s *RecrawlScheduler) NextVisit(url string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[url]
+	if !ok {
+		return time.Time{}, false
+	}
+	return entry.nextVisit, true
+}
+
+// Len 返回调度中的URL数量
+func (s *RecrawlScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// Run 持续把到期的URL放入队列，直到ctx被取消
+func (s *RecrawlScheduler) Run(ctx context.Context, queue Queue) {
+	for {
+		wait := s.pushDue(queue, time.Now())
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// pushDue 把所有到期的URL放入队列，返回距离下一个URL到期的时间
+func (s *RecrawlScheduler) pushDue(queue Queue, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.due.Len() > 0 {
+		entry := s.due[0]
+		if entry.nextVisit.After(now) {
+			return entry.nextVisit.Sub(now)
+		}
+
+		// 出堆后等待下一次 Record 重新安排
+		heap.Pop(&s.due)
+		metadata := map[string]interface{}{MetadataRecrawl: entry.visits}
+		for k, v := range entry.url.Metadata {
+			metadata[k] = v
+		}
+		queue.Push(&URL{
+			Address:  entry.url.Address,
+			Depth:    entry.url.Depth,
+			Parent:   entry.url.Parent,
+			Metadata: metadata,
+		})
+	}
+
+	return s.options.MaxInterval
+}
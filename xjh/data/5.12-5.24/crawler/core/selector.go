@@ -0,0 +1,507 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector 编译后的CSS选择器。支持：
+//   - 类型、通配符、#id、.class
+//   - 属性选择器 [a]、[a=v]、[a~=v]、[a|=v]、[a^=v]、[a$=v]、[a*=v]
+//   - 组合符：后代（空格）、>、+、~，以及用逗号分隔的选择器组
+//   - 伪类 :first-child、:last-child、:only-child、:first-of-type、:last-of-type、
+//     :nth-child()、:nth-last-child()、:nth-of-type()、:empty、:not()
+type Selector struct {
+	source string
+
+	// 用逗号分隔的每个选择器
+	groups []complexSelector
+}
+
+// complexSelector 由组合符连接的复合选择器，按从左到右的顺序保存
+type complexSelector []selectorStep
+
+// selectorStep 一个复合选择器及其与左侧选择器之间的组合符
+type selectorStep struct {
+	// 组合符：' '、'>'、'+'、'~'，第一个复合选择器为0
+	combinator byte
+	compound   compoundSelector
+}
+
+// compoundSelector 对同一个元素的一组条件
+type compoundSelector []func(*Node) bool
+
+// CompileSelector 编译CSS选择器
+func CompileSelector(source string) (*Selector, error) {
+	p := &selectorParser{s: source}
+	groups, err := p.parseGroup()
+	if err != nil {
+		return nil, fmt.Errorf("选择器 %q 无效: %w", source, err)
+	}
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("选择器 %q 无效: 位置 %d 有多余的字符 %q", source, p.pos, p.s[p.pos:])
+	}
+	return &Selector{source: source, groups: groups}, nil
+}
+
+// MustCompileSelector 编译CSS选择器，无效时panic
+func MustCompileSelector(source string) *Selector {
+	s, err := CompileSelector(source)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String 返回选择器的原文
+func (s *Selector) String() string {
+	return s.source
+}
+
+// Match 检查元素是否匹配选择器
+func (s *Selector) Match(n *Node) bool {
+	if n.Type != ElementNode {
+		return false
+	}
+	for _, c := range s.groups {
+		if c.match(n, len(c)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select 按文档顺序返回 root 的后代中匹配选择器的元素
+func (s *Selector) Select(root *Node) []*Node {
+	var nodes []*Node
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		c.Walk(func(n *Node) {
+			if s.Match(n) {
+				nodes = append(nodes, n)
+			}
+		})
+	}
+	return nodes
+}
+
+// SelectFirst 返回 root 的后代中第一个匹配选择器的元素，没有时返回nil
+func (s *Selector) SelectFirst(root *Node) *Node {
+	if nodes := s.Select(root); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+// match 从右向左检查第 i 个复合选择器及其左侧的选择器
+func (c complexSelector) match(n *Node, i int) bool {
+	if !c[i].compound.match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	switch c[i].combinator {
+	case ' ':
+		for p := n.Parent; p != nil && p.Type == ElementNode; p = p.Parent {
+			if c.match(p, i-1) {
+				return true
+			}
+		}
+	case '>':
+		if p := n.Parent; p != nil && p.Type == ElementNode {
+			return c.match(p, i-1)
+		}
+	case '+':
+		if p := prevElement(n); p != nil {
+			return c.match(p, i-1)
+		}
+	case '~':
+		for p := prevElement(n); p != nil; p = prevElement(p) {
+			if c.match(p, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match 检查元素是否满足全部条件
+func (c compoundSelector) match(n *Node) bool {
+	for _, cond := range c {
+		if !cond(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// prevElement 返回前一个兄弟元素
+func prevElement(n *Node) *Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+// nextElement 返回后一个兄弟元素
+func nextElement(n *Node) *Node {
+	for p := n.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+// selectorParser 选择器的递归下降解析器
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+// parseGroup 解析用逗号分隔的选择器组
+func (p *selectorParser) parseGroup() ([]complexSelector, error) {
+	var groups []complexSelector
+	for {
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, c)
+
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != ',' {
+			return groups, nil
+		}
+		p.pos++
+	}
+}
+
+// parseComplex 解析由组合符连接的复合选择器
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	p.skipSpace()
+	compound, err := p.parseCompound()
+	if err != nil {
+		return nil, err
+	}
+	c := complexSelector{{compound: compound}}
+
+	for {
+		hadSpace := p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] == ',' || p.s[p.pos] == ')' {
+			return c, nil
+		}
+
+		combinator := byte(' ')
+		if ch := p.s[p.pos]; ch == '>' || ch == '+' || ch == '~' {
+			combinator = ch
+			p.pos++
+			p.skipSpace()
+		} else if !hadSpace {
+			return nil, fmt.Errorf("位置 %d 有意外的字符 %q", p.pos, p.s[p.pos])
+		}
+
+		compound, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, selectorStep{combinator: combinator, compound: compound})
+	}
+}
+
+// parseCompound 解析复合选择器，如 div.item[data-id]:first-child
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var c compoundSelector
+
+	if p.pos < len(p.s) && p.s[p.pos] == '*' {
+		p.pos++
+		c = append(c, func(*Node) bool { return true })
+	} else if name := p.parseIdent(); name != "" {
+		name = strings.ToLower(name)
+		c = append(c, func(n *Node) bool { return n.Data == name })
+	}
+
+	for p.pos < len(p.s) {
+		var cond func(*Node) bool
+		var err error
+
+		switch p.s[p.pos] {
+		case '#':
+			p.pos++
+			id := p.parseIdent()
+			if id == "" {
+				return nil, fmt.Errorf("位置 %d 缺少id", p.pos)
+			}
+			cond = func(n *Node) bool { return n.Attr("id") == id }
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return nil, fmt.Errorf("位置 %d 缺少类名", p.pos)
+			}
+			cond = func(n *Node) bool { return containsString(strings.Fields(n.Attr("class")), class) }
+		case '[':
+			cond, err = p.parseAttr()
+		case ':':
+			cond, err = p.parsePseudo()
+		default:
+			if len(c) == 0 {
+				return nil, fmt.Errorf("位置 %d 缺少选择器", p.pos)
+			}
+			return c, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, cond)
+	}
+
+	if len(c) == 0 {
+		return nil, fmt.Errorf("位置 %d 缺少选择器", p.pos)
+	}
+	return c, nil
+}
+
+// parseAttr 解析属性选择器
+func (p *selectorParser) parseAttr() (func(*Node) bool, error) {
+	p.pos++
+	p.skipSpace()
+	key := strings.ToLower(p.parseIdent())
+	if key == "" {
+		return nil, fmt.Errorf("位置 %d 缺少属性名", p.pos)
+	}
+	p.skipSpace()
+
+	if p.pos < len(p.s) && p.s[p.pos] == ']' {
+		p.pos++
+		return func(n *Node) bool {
+			_, ok := n.LookupAttr(key)
+			return ok
+		}, nil
+	}
+
+	op := ""
+	for _, candidate := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.s[p.pos:], candidate) {
+			op = candidate
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("位置 %d 有未知的属性运算符", p.pos)
+	}
+	p.pos += len(op)
+	p.skipSpace()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != ']' {
+		return nil, fmt.Errorf("位置 %d 缺少 ]", p.pos)
+	}
+	p.pos++
+
+	return func(n *Node) bool {
+		attr, ok := n.LookupAttr(key)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "=":
+			return attr == value
+		case "~=":
+			return containsString(strings.Fields(attr), value)
+		case "|=":
+			return attr == value || strings.HasPrefix(attr, value+"-")
+		case "^=":
+			return value != "" && strings.HasPrefix(attr, value)
+		case "$=":
+			return value != "" && strings.HasSuffix(attr, value)
+		default:
+			return value != "" && strings.Contains(attr, value)
+		}
+	}, nil
+}
+
+// parsePseudo 解析伪类
+func (p *selectorParser) parsePseudo() (func(*Node) bool, error) {
+	p.pos++
+	name := strings.ToLower(p.parseIdent())
+
+	switch name {
+	case "first-child":
+		return func(n *Node) bool { return prevElement(n) == nil }, nil
+	case "last-child":
+		return func(n *Node) bool { return nextElement(n) == nil }, nil
+	case "only-child":
+		return func(n *Node) bool { return prevElement(n) == nil && nextElement(n) == nil }, nil
+	case "first-of-type":
+		return func(n *Node) bool { return siblingIndex(n, false, true) == 1 }, nil
+	case "last-of-type":
+		return func(n *Node) bool { return siblingIndex(n, true, true) == 1 }, nil
+	case "empty":
+		return func(n *Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == ElementNode || (c.Type == TextNode && c.Data != "") {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		a, b, err := parseNth(arg)
+		if err != nil {
+			return nil, err
+		}
+		fromEnd := strings.Contains(name, "last")
+		ofType := strings.HasSuffix(name, "of-type")
+		return func(n *Node) bool { return nthMatches(a, b, siblingIndex(n, fromEnd, ofType)) }, nil
+	case "not":
+		if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+			return nil, fmt.Errorf("位置 %d 缺少 (", p.pos)
+		}
+		p.pos++
+		groups, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, fmt.Errorf("位置 %d 缺少 )", p.pos)
+		}
+		p.pos++
+		inner := &Selector{groups: groups}
+		return func(n *Node) bool { return !inner.Match(n) }, nil
+	}
+
+	return nil, fmt.Errorf("不支持的伪类 :%s", name)
+}
+
+// parseArgument 读取伪类括号中的参数
+func (p *selectorParser) parseArgument() (string, error) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		return "", fmt.Errorf("位置 %d 缺少 (", p.pos)
+	}
+	end := strings.IndexByte(p.s[p.pos:], ')')
+	if end == -1 {
+		return "", fmt.Errorf("位置 %d 缺少 )", p.pos)
+	}
+	arg := p.s[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+	return strings.TrimSpace(arg), nil
+}
+
+// parseIdent 读取标识符，支持反斜杠转义
+func (p *selectorParser) parseIdent() string {
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.s):
+			b.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+		case isLetter(c) || (c >= '0' && c <= '9') || c == '-' || c == '_' || c >= 0x80:
+			b.WriteByte(c)
+			p.pos++
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+// parseValue 读取属性值：带引号的字符串或标识符
+func (p *selectorParser) parseValue() (string, error) {
+	if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		quote := p.s[p.pos]
+		end := strings.IndexByte(p.s[p.pos+1:], quote)
+		if end == -1 {
+			return "", fmt.Errorf("位置 %d 的字符串没有结束", p.pos)
+		}
+		value := p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	return p.parseIdent(), nil
+}
+
+// skipSpace 跳过空白，返回是否跳过了字符
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.s) && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// parseNth 解析 an+b 形式的参数，支持 odd 和 even
+func parseNth(arg string) (a, b int, err error) {
+	arg = strings.ToLower(strings.ReplaceAll(arg, " ", ""))
+	switch arg {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	n := strings.IndexByte(arg, 'n')
+	if n == -1 {
+		b, err = strconv.Atoi(arg)
+		if err != nil {
+			return 0, 0, fmt.Errorf("无效的参数 %q", arg)
+		}
+		return 0, b, nil
+	}
+
+	switch coef := arg[:n]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(coef); err != nil {
+			return 0, 0, fmt.Errorf("无效的参数 %q", arg)
+		}
+	}
+	if rest := arg[n+1:]; rest != "" {
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, fmt.Errorf("无效的参数 %q", arg)
+		}
+	}
+	return a, b, nil
+}
+
+// nthMatches 检查位置 index（从1开始）是否满足 an+b（n >= 0）
+func nthMatches(a, b, index int) bool {
+	if a == 0 {
+		return index == b
+	}
+	diff := index - b
+	return diff%a == 0 && diff/a >= 0
+}
+
+// siblingIndex 返回元素在兄弟元素中的位置（从1开始），
+// fromEnd 表示从后往前数，ofType 表示只数同名元素
+func siblingIndex(n *Node, fromEnd, ofType bool) int {
+	index := 1
+	step := prevElement
+	if fromEnd {
+		step = nextElement
+	}
+	for s := step(n); s != nil; s = step(s) {
+		if !ofType || s.Data == n.Data {
+			index++
+		}
+	}
+	return index
+}
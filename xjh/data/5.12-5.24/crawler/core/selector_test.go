@@ -0,0 +1,129 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectorSelect(t *testing.T) {
+	doc := parseXPathTestDocument(t)
+
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		// 类型、id、类和通配符
+		{"li", "a b c d"},
+		{"#items", "items"},
+		{".item.sale", "b"},
+		{"li.item", "a b c"},
+		{"ul > *", "a b c d"},
+
+		// 组合符
+		{"div span", "s1 s2"},
+		{"li > b", "bold"},
+		{"ul > b", ""},
+		{"li + li", "b c d"},
+		{"h1 + ul", "items"},
+		{"#a ~ li", "b c d"},
+		{"#b ~ .item", "c"},
+		{"body > * > ul", "items"},
+		{"html > body div > ul li:last-child", "d"},
+
+		// 选择器组按文档顺序返回，不重复
+		{"h1, span, #a", "title a s1 s2"},
+		{"li.item, #b", "a b c"},
+
+		// 属性选择器，属性值中的字符实体已解码
+		{"[data-price]", "a b c"},
+		{"[data-price='25']", "b"},
+		{"[data-price=\"7\"]", "c"},
+		{"[class~=sale]", "b"},
+		{"[class|=item]", "a c"},
+		{"[class^=ite]", "a b c"},
+		{"[class$=sale]", "b"},
+		{"[class*=the]", "d"},
+		{"[href*='x=1&y=2']", "link"},
+		{"[class^='']", ""},
+
+		// 伪类
+		{"li:first-child", "a"},
+		{"li:last-child", "d"},
+		{"span:last-child", "s2"},
+		{":only-child", "html title bold link"},
+		{"div:first-of-type", "main"},
+		{"div:last-of-type", "side"},
+		{"li:nth-child(3)", "c"},
+		{"li:nth-child(odd)", "a c"},
+		{"li:nth-child(even)", "b d"},
+		{"li:nth-child(2n+1)", "a c"},
+		{"li:nth-child(-n+2)", "a b"},
+		{"li:nth-last-child(1)", "d"},
+		{"span:nth-of-type(2)", "s2"},
+		{"li:not(.item)", "d"},
+		{"li:not([data-price])", "d"},
+		{"span:not(:empty)", "s1 s2"},
+		{"b:empty", ""},
+	}
+	for _, tt := range tests {
+		selector, err := CompileSelector(tt.selector)
+		if err != nil {
+			t.Errorf("编译 %s 失败: %v", tt.selector, err)
+			continue
+		}
+		if got := describeNodes(selector.Select(doc)); got != tt.want {
+			t.Errorf("%s = %q，期望 %q", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	for _, source := range []string{"", "li[", "li[data-price", "#", ".", "li:unknown", "li:nth-child(x)", "li:not(", "ul >", "li,", "li)"} {
+		if _, err := CompileSelector(source); err == nil {
+			t.Errorf("CompileSelector(%q) 没有返回错误", source)
+		}
+	}
+}
+
+func TestParseHTMLImpliedEnds(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		// 省略的结束标签
+		{"<ul><li>a<li>b</ul>", "<ul><li>a</li><li>b</li></ul>"},
+		{"<p>one<p>two", "<p>one</p><p>two</p>"},
+		{"<p>text<div>block</div>", "<p>text</p><div>block</div>"},
+		{"<table><tr><td>1<td>2<tr><td>3</table>", "<table><tr><td>1</td><td>2</td></tr><tr><td>3</td></tr></table>"},
+		{"<dl><dt>k<dd>v<dt>k2</dl>", "<dl><dt>k</dt><dd>v</dd><dt>k2</dt></dl>"},
+
+		// 嵌套列表中的 li 不会结束外层的 li
+		{"<ul><li>a<ul><li>b</ul><li>c</ul>", "<ul><li>a<ul><li>b</li></ul></li><li>c</li></ul>"},
+
+		// 多余的结束标签被忽略，空元素没有子节点
+		{"<div>a</span>b</div>", "<div>ab</div>"},
+		{"<p>a<br>b<img src=x>c</p>", `<p>a<br>b<img src="x">c</p>`},
+
+		// 字符实体在文本中解码，在 script 中保留原文
+		{"<p>a &amp; b &lt;c&gt;</p>", "<p>a &amp; b &lt;c&gt;</p>"},
+		{"<script>if (a &amp;&amp; b < c) {}</script>", "<script>if (a &amp;&amp; b < c) {}</script>"},
+	}
+	for _, tt := range tests {
+		doc, err := ParseHTML(strings.NewReader(tt.html))
+		if err != nil {
+			t.Errorf("解析 %q 失败: %v", tt.html, err)
+			continue
+		}
+		if got := doc.OuterHTML(); got != tt.want {
+			t.Errorf("解析 %q 得到 %q，期望 %q", tt.html, got, tt.want)
+		}
+	}
+
+	doc, err := ParseHTML(strings.NewReader("<p>a &amp; b<script>x &amp; y</script></p>"))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if got := doc.Text(); got != "a & bx &amp; y" {
+		t.Errorf("Text() = %q，期望 %q", got, "a & bx &amp; y")
+	}
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// NearDuplicateOptions 近似重复页面检测配置
+type NearDuplicateOptions struct {
+	// 两个指纹被视为近似重复的最大汉明距离，默认3
+	MaxDistance int
+
+	// 文本分片（shingle）的长度，按词计，默认4
+	ShingleSize int
+
+	// 是否跳过近似重复页面中的链接
+	SkipLinks bool
+}
+
+var (
+	// 不可见内容：脚本、样式和注释
+	invisibleRegex = regexp.MustCompile(`(?is)<script[^>]*>.*?</script>|<style[^>]*>.*?</style>|<!--.*?-->`)
+
+	// HTML标签
+	tagRegex = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// VisibleText 去掉脚本、样式和标签，返回页面的可见文本
+func VisibleText(content []byte) string {
+	text := invisibleRegex.ReplaceAll(content, []byte(" "))
+	text = tagRegex.ReplaceAll(text, []byte(" "))
+	return strings.Join(strings.Fields(string(text)), " ")
+}
+
+// SimHash 计算文本的64位SimHash指纹。
+// 文本先切分为词（中日韩字符各自成词），再按 shingleSize 个连续词组成分片
+func SimHash(text string, shingleSize int) uint64 {
+	if shingleSize <= 0 {
+		shingleSize = 4
+	}
+
+	words := tokenizeWords(text)
+	if len(words) == 0 {
+		return 0
+	}
+	if len(words) < shingleSize {
+		shingleSize = len(words)
+	}
+
+	var weights [64]int
+	h := fnv.New64a()
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h.Reset()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for b := 0; b < 64; b++ {
+		if weights[b] > 0 {
+			fingerprint |= 1 << b
+		}
+	}
+	return fingerprint
+}
+
+// HammingDistance 返回两个指纹的汉明距离
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// tokenizeWords 把文本切分为小写单词，中日韩字符单独成词
+func tokenizeWords(text string) []string {
+	var words []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			words = append(words, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			words = append(words, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return words
+}
+
+// simHashEntry 索引中的一个指纹
+type simHashEntry struct {
+	fingerprint uint64
+	url         string
+}
+
+// SimHashIndex 按汉明距离查找近似重复页面的指纹索引。
+// 指纹被切分为 MaxDistance+1 段，距离不超过 MaxDistance 的两个指纹至少有一段完全相同，
+// 因此只需比较共享某一段的候选项
+type SimHashIndex struct {
+	maxDistance int
+
+	// 每一段的位偏移和位宽
+	offsets []uint
+	widths  []uint
+
+	// 段序号 -> 段的值 -> 指纹
+	bands []map[uint64][]simHashEntry
+
+	// 已加入索引的URL -> 指纹，重爬的页面替换自己原有的指纹
+	urls map[string]uint64
+
+	mu sync.RWMutex
+}
+
+// NewSimHashIndex 创建一个新的指纹索引
+func NewSimHashIndex(maxDistance int) *SimHashIndex {
+	if maxDistance < 0 {
+		maxDistance = 0
+	}
+	if maxDistance > 15 {
+		maxDistance = 15
+	}
+
+	n := maxDistance + 1
+	idx := &SimHashIndex{
+		maxDistance: maxDistance,
+		bands:       make([]map[uint64][]simHashEntry, n),
+		urls:        make(map[string]uint64),
+	}
+
+	var offset uint
+	for i := 0; i < n; i++ {
+		width := uint(64 / n)
+		if i < 64%n {
+			width++
+		}
+		idx.offsets = append(idx.offsets, offset)
+		idx.widths = append(idx.widths, width)
+		idx.bands[i] = make(map[uint64][]simHashEntry)
+		offset += width
+	}
+
+	return idx
+}
+
+// band 取出指纹的第i段
+func (idx *SimHashIndex) band(fingerprint uint64, i int) uint64 {
+	return (fingerprint >> idx.offsets[i]) & (1<<idx.widths[i] - 1)
+}
+
+// FindOrAdd 查找与指纹近似重复的其他页面，找到时返回其URL和true；
+// 否则把该页面加入索引，成为一个新簇的代表。重爬的页面不会与自己之前的指纹匹配，
+// 它在索引中的指纹被替换为新的指纹
+func (idx *SimHashIndex) FindOrAdd(url string, fingerprint uint64) (string, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if match, ok := idx.find(fingerprint, url); ok {
+		return match, true
+	}
+
+	if old, ok := idx.urls[url]; ok {
+		idx.remove(url, old)
+	}
+	idx.urls[url] = fingerprint
+
+	entry := simHashEntry{fingerprint: fingerprint, url: url}
+	for i := range idx.bands {
+		key := idx.band(fingerprint, i)
+		idx.bands[i][key] = append(idx.bands[i][key], entry)
+	}
+	return "", false
+}
+
+// Find 查找与指纹近似重复的已有页面
+func (idx *SimHashIndex) Find(fingerprint uint64) (string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.find(fingerprint, "")
+}
+
+// find 在候选项中查找距离最近的指纹，跳过 exclude 自己的指纹，调用方需持有锁
+func (idx *SimHashIndex) find(fingerprint uint64, exclude string) (string, bool) {
+	best, bestDistance := "", idx.maxDistance+1
+	for i := range idx.bands {
+		for _, entry := range idx.bands[i][idx.band(fingerprint, i)] {
+			if entry.url == exclude {
+				continue
+			}
+			if d := HammingDistance(entry.fingerprint, fingerprint); d < bestDistance {
+				best, bestDistance = entry.url, d
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// remove 从各段中删除URL的指纹，调用方需持有锁
+func (idx *SimHashIndex) remove(url string, fingerprint uint64) {
+	for i := range idx.bands {
+		key := idx.band(fingerprint, i)
+		entries := idx.bands[i][key]
+		for j, entry := range entries {
+			if entry.url == url {
+				entries = append(entries[:j], entries[j+1:]...)
+				break
+			}
+		}
+		if len(entries) == 0 {
+			delete(idx.bands[i], key)
+		} else {
+			idx.bands[i][key] = entries
+		}
+	}
+}
+
+// Clear 清空索引
+func (idx *SimHashIndex) Clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i := range idx.bands {
+		idx.bands[i] = make(map[uint64][]simHashEntry)
+	}
+	idx.urls = make(map[string]uint64)
+}
@@ -0,0 +1,35 @@
+package core
+
+import "testing"
+
+func TestSimHashIndexRecrawl(t *testing.T) {
+	idx := NewSimHashIndex(3)
+	const a, b = "http://example.com/a", "http://example.com/b"
+	article := SimHash("the quick brown fox jumps over the lazy dog near the river bank", 4)
+	other := SimHash("completely different text about crawling websites with go and simhash", 4)
+
+	if _, ok := idx.FindOrAdd(a, article); ok {
+		t.Fatalf("空索引中找到了重复页面")
+	}
+
+	// 重爬同一URL不会与自己之前的指纹匹配
+	if match, ok := idx.FindOrAdd(a, article); ok {
+		t.Errorf("重爬 %s 被判为 %s 的重复", a, match)
+	}
+
+	// 其他URL的相同内容仍是重复
+	if match, ok := idx.FindOrAdd(b, article); !ok || match != a {
+		t.Errorf("FindOrAdd(%s) = %q, %v，期望 %q, true", b, match, ok, a)
+	}
+
+	// 内容变化后旧指纹被替换，不再匹配旧内容
+	if _, ok := idx.FindOrAdd(a, other); ok {
+		t.Errorf("内容变化后 %s 被判为重复", a)
+	}
+	if match, ok := idx.Find(article); ok {
+		t.Errorf("旧指纹仍在索引中，匹配到 %s", match)
+	}
+	if match, ok := idx.Find(other); !ok || match != a {
+		t.Errorf("Find(新指纹) = %q, %v，期望 %q, true", match, ok, a)
+	}
+}
@@ -10,25 +10,60 @@ import (
 // MemoryStorage 是一个内存存储实现
 type MemoryStorage struct {
 	data map[string][]Result
-	mu   sync.RWMutex
+
+	// 保存的页面内容及其编码
+	contents  map[string][]byte
+	encodings map[string]string
+
+	mu sync.RWMutex
 }
 
 // NewMemoryStorage 创建一个新的内存存储
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		data: make(map[string][]Result),
+		data:      make(map[string][]Result),
+		contents:  make(map[string][]byte),
+		encodings: make(map[string]string),
 	}
 }
 
-// Store 存储URL及其关联的结果
+// Store 存储URL及其关联的结果，results 为空时删除URL已有的结果
 func (s *MemoryStorage) Store(url string, results []Result) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if len(results) == 0 {
+		delete(s.data, url)
+		return nil
+	}
 	s.data[url] = results
 	return nil
 }
 
+// StoreContent 实现ContentStorage接口，在内存中保存页面内容（通常是压缩后的）
+func (s *MemoryStorage) StoreContent(url string, content []byte, encoding string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contents[url] = content
+	s.encodings[url] = encoding
+	return nil
+}
+
+// Content 返回保存的页面内容，已压缩的内容会被解压
+func (s *MemoryStorage) Content(url string) ([]byte, bool, error) {
+	s.mu.RLock()
+	content, ok := s.contents[url]
+	encoding := s.encodings[url]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, false, nil
+	}
+	decoded, err := DecodeContent(content, encoding)
+	return decoded, true, err
+}
+
 // Get 获取URL对应的结果
 func (s *MemoryStorage) Get(url string) ([]Result, bool) {
 	s.mu.RLock()
@@ -58,6 +93,8 @@ func (s *MemoryStorage) Clear() {
 	defer s.mu.Unlock()
 
 	s.data = make(map[string][]Result)
+	s.contents = make(map[string][]byte)
+	s.encodings = make(map[string]string)
 }
 
 // SaveToFile 将结果保存到文件
@@ -0,0 +1,344 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// TokenType HTML词法单元的类型
+type TokenType int
+
+const (
+	// TextToken 文本，较长的文本会被拆成多个连续的文本单元
+	TextToken TokenType = iota
+
+	// StartTagToken 开始标签，如 <a href="...">
+	StartTagToken
+
+	// EndTagToken 结束标签，如 </a>
+	EndTagToken
+
+	// SelfClosingTagToken 自闭合标签，如 <br/>
+	SelfClosingTagToken
+
+	// CommentToken 注释
+	CommentToken
+
+	// DoctypeToken 文档类型声明和处理指令，如 <!DOCTYPE html>
+	DoctypeToken
+)
+
+const (
+	// maxTagSize 单个标签的最大长度，超出时把 < 当作文本
+	maxTagSize = 64 * 1024
+
+	// maxTextChunk 单个文本单元的最大长度
+	maxTextChunk = 4096
+)
+
+// Attribute 标签属性
+type Attribute struct {
+	Key string
+	Val string
+}
+
+// Token HTML词法单元
+type Token struct {
+	Type TokenType
+
+	// 标签名（小写）、文本或注释内容
+	Data string
+
+	// 标签属性，属性名为小写，属性值保持原样
+	Attrs []Attribute
+}
+
+// Attr 返回属性值，属性不存在时返回空字符串
+func (t *Token) Attr(key string) string {
+	for _, a := range t.Attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// Tokenizer 流式HTML词法分析器，从 io.Reader 中逐个读取词法单元。
+// 内存占用只取决于缓冲区、单个标签和单个文本单元的大小，与页面大小无关。
+// script 和 style 的内容作为文本返回，不解析其中的标签
+type Tokenizer struct {
+	r *bufio.Reader
+	n int64
+
+	// 当前所在的原始文本元素（script、style），为空表示不在其中
+	rawTag string
+
+	err error
+}
+
+// NewTokenizer 创建流式词法分析器
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{r: bufio.NewReaderSize(r, maxTextChunk)}
+}
+
+// BytesRead 返回已读取的字节数
+func (t *Tokenizer) BytesRead() int64 {
+	return t.n
+}
+
+// Next 返回下一个词法单元，读完时返回 io.EOF
+func (t *Tokenizer) Next() (Token, error) {
+	if t.err != nil {
+		return Token{}, t.err
+	}
+
+	if t.rawTag != "" {
+		return t.readRawText()
+	}
+
+	c, err := t.peek()
+	if err != nil {
+		t.err = err
+		return Token{}, err
+	}
+	if c != '<' {
+		return t.readText(), nil
+	}
+
+	return t.readTag(), nil
+}
+
+// peek 查看下一个字节
+func (t *Tokenizer) peek() (byte, error) {
+	b, err := t.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readByte 读取一个字节
+func (t *Tokenizer) readByte() (byte, error) {
+	c, err := t.r.ReadByte()
+	if err == nil {
+		t.n++
+	}
+	return c, err
+}
+
+// readText 读取到下一个 < 之前的文本，最多 maxTextChunk 字节
+func (t *Tokenizer) readText() Token {
+	var buf bytes.Buffer
+	for buf.Len() < maxTextChunk {
+		c, err := t.peek()
+		if err != nil || (c == '<' && buf.Len() > 0) {
+			break
+		}
+		t.readByte()
+		buf.WriteByte(c)
+	}
+	return Token{Type: TextToken, Data: buf.String()}
+}
+
+// readRawText 读取 script、style 中的内容，直到对应的结束标签
+func (t *Tokenizer) readRawText() (Token, error) {
+	end := "</" + t.rawTag
+	var buf bytes.Buffer
+	for buf.Len() < maxTextChunk {
+		// 检查是否到达结束标签
+		if head, _ := t.r.Peek(len(end)); len(head) == len(end) && strings.EqualFold(string(head), end) {
+			if buf.Len() > 0 {
+				break
+			}
+			t.rawTag = ""
+			return t.readTag(), nil
+		}
+
+		c, err := t.readByte()
+		if err != nil {
+			if buf.Len() == 0 {
+				t.err = err
+				return Token{}, err
+			}
+			break
+		}
+		buf.WriteByte(c)
+	}
+	return Token{Type: TextToken, Data: buf.String()}, nil
+}
+
+// readTag 读取以 < 开头的标签、注释或声明
+func (t *Tokenizer) readTag() Token {
+	head, _ := t.r.Peek(4)
+	if string(head) == "<!--" {
+		return t.readComment()
+	}
+
+	// < 后面不是标签名、/、! 或 ? 时只是普通文本
+	if len(head) < 2 || !(isLetter(head[1]) || head[1] == '/' || head[1] == '!' || head[1] == '?') {
+		t.readByte()
+		return Token{Type: TextToken, Data: "<"}
+	}
+
+	// 读取到 >，引号中的 > 不结束标签
+	var buf bytes.Buffer
+	var quote byte
+	for {
+		if buf.Len() > maxTagSize {
+			// 不是标签，把已读取的内容当作文本
+			return Token{Type: TextToken, Data: buf.String()}
+		}
+		c, err := t.readByte()
+		if err != nil {
+			return Token{Type: TextToken, Data: buf.String()}
+		}
+		buf.WriteByte(c)
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// 只有属性值中的引号才需要配对
+			if prev := buf.Bytes(); len(prev) >= 2 && (prev[len(prev)-2] == '=' || isSpace(prev[len(prev)-2])) {
+				quote = c
+			}
+		case c == '>' && buf.Len() > 1:
+			return t.parseTag(buf.String())
+		}
+	}
+}
+
+// readComment 读取注释，注释内容最多保留 maxTextChunk 字节
+func (t *Tokenizer) readComment() Token {
+	for i := 0; i < 4; i++ {
+		t.readByte()
+	}
+
+	var buf bytes.Buffer
+	var prev1, prev2 byte
+	for {
+		c, err := t.readByte()
+		if err != nil {
+			break
+		}
+		if c == '>' && prev1 == '-' && prev2 == '-' {
+			break
+		}
+		if buf.Len() < maxTextChunk+2 {
+			buf.WriteByte(c)
+		}
+		prev2, prev1 = prev1, c
+	}
+
+	// 去掉结尾的 --
+	data := buf.Bytes()
+	if n := len(data); n >= 2 && n <= maxTextChunk+2 && prev1 == '-' && prev2 == '-' {
+		data = data[:n-2]
+	}
+	if len(data) > maxTextChunk {
+		data = data[:maxTextChunk]
+	}
+	return Token{Type: CommentToken, Data: string(data)}
+}
+
+// parseTag 解析完整的标签文本，如 <a href="x">
+func (t *Tokenizer) parseTag(raw string) Token {
+	inner := raw[1 : len(raw)-1]
+
+	if strings.HasPrefix(inner, "!") || strings.HasPrefix(inner, "?") {
+		return Token{Type: DoctypeToken, Data: inner[1:]}
+	}
+
+	tokenType := StartTagToken
+	if strings.HasPrefix(inner, "/") {
+		tokenType = EndTagToken
+		inner = inner[1:]
+	}
+
+	// 标签名必须以字母开头，否则按文本处理
+	if inner == "" || !isLetter(inner[0]) {
+		return Token{Type: TextToken, Data: raw}
+	}
+
+	if tokenType == StartTagToken && strings.HasSuffix(inner, "/") {
+		tokenType = SelfClosingTagToken
+		inner = inner[:len(inner)-1]
+	}
+
+	i := 0
+	for i < len(inner) && !isSpace(inner[i]) && inner[i] != '/' {
+		i++
+	}
+	token := Token{Type: tokenType, Data: strings.ToLower(inner[:i])}
+	if tokenType != EndTagToken {
+		token.Attrs = parseAttrs(inner[i:])
+	}
+
+	if tokenType == StartTagToken && (token.Data == "script" || token.Data == "style") {
+		t.rawTag = token.Data
+	}
+	return token
+}
+
+// parseAttrs 解析标签中的属性
+func parseAttrs(s string) []Attribute {
+	var attrs []Attribute
+	i := 0
+	for i < len(s) {
+		// 跳过空白和多余的斜杠
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '/' {
+			i++
+		}
+		if start == i {
+			i++
+			continue
+		}
+		attr := Attribute{Key: strings.ToLower(s[start:i])}
+
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				i++
+				start = i
+				for i < len(s) && s[i] != quote {
+					i++
+				}
+				attr.Val = s[start:i]
+				i++
+			} else {
+				start = i
+				for i < len(s) && !isSpace(s[i]) {
+					i++
+				}
+				attr.Val = s[start:i]
+			}
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+// isSpace 检查是否是HTML空白字符
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isLetter 检查是否是ASCII字母
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 爬虫陷阱的拦截原因
+const (
+	TrapPathDepth       = "path_depth"
+	TrapRepeatedSegment = "repeated_segment"
+	TrapTemplateBudget  = "template_budget"
+	TrapQueryExplosion  = "query_explosion"
+)
+
+// TrapOptions 爬虫陷阱检测配置，字段为0表示不启用对应规则
+type TrapOptions struct {
+	// 最大路径深度（路径段数量）
+	MaxPathDepth int
+
+	// 同一路径段允许重复出现的最大次数，如 /a/b/a/b/a/b
+	MaxRepeatedSegments int
+
+	// 每个路径模板允许入队的最大URL数量
+	MaxURLsPerTemplate int
+
+	// 单个URL允许的最大查询参数个数
+	MaxQueryParams int
+
+	// 同一路径下允许出现的不同查询参数组合数量
+	MaxQueryVariants int
+}
+
+// TrapDetector 检测日历页、分面搜索、重复路径等无限URL空间
+type TrapDetector struct {
+	options TrapOptions
+
+	// 路径模板 -> 已放行的URL数量
+	templates map[string]int
+
+	// 路径 -> 已出现的查询参数组合
+	variants map[string]map[string]bool
+
+	// 主机 -> 拦截原因 -> 拦截次数
+	suppressed map[string]map[string]int64
+
+	mu sync.Mutex
+}
+
+// NewTrapDetector 创建一个新的陷阱检测器
+func NewTrapDetector(options TrapOptions) *TrapDetector {
+	return &TrapDetector{
+		options:    options,
+		templates:  make(map[string]int),
+		variants:   make(map[string]map[string]bool),
+		suppressed: make(map[string]map[string]int64),
+	}
+}
+
+// Check 检查URL是否疑似陷阱，返回拦截原因；返回空字符串表示放行。
+// 放行的URL会占用路径模板预算，调用方应只对去重后的新URL调用
+func (d *TrapDetector) Check(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	segments := splitPath(u.Path)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reason := d.check(u, segments)
+	if reason != "" {
+		if d.suppressed[u.Host] == nil {
+			d.suppressed[u.Host] = make(map[string]int64)
+		}
+		d.suppressed[u.Host][reason]++
+	}
+	return reason
+}
+
+// check 依次应用各条规则，调用方需持有锁
+func (d *TrapDetector) check(u *url.URL, segments []string) string {
+	// 路径深度
+	if d.options.MaxPathDepth > 0 && len(segments) > d.options.MaxPathDepth {
+		return TrapPathDepth
+	}
+
+	// 重复路径段
+	if d.options.MaxRepeatedSegments > 0 {
+		counts := make(map[string]int)
+		for _, seg := range segments {
+			counts[seg]++
+			if counts[seg] > d.options.MaxRepeatedSegments {
+				return TrapRepeatedSegment
+			}
+		}
+	}
+
+	// 查询参数爆炸
+	query := u.Query()
+	if d.options.MaxQueryParams > 0 && len(query) > d.options.MaxQueryParams {
+		return TrapQueryExplosion
+	}
+	pathKey := u.Host + u.Path
+	var variant string
+	newVariant := false
+	if d.options.MaxQueryVariants > 0 && u.RawQuery != "" {
+		seen := d.variants[pathKey]
+		variant = canonicalQuery(query)
+		if !seen[variant] && len(seen) >= d.options.MaxQueryVariants {
+			return TrapQueryExplosion
+		}
+		newVariant = !seen[variant]
+	}
+
+	// 路径模板预算
+	var template string
+	if d.options.MaxURLsPerTemplate > 0 {
+		template = pathTemplate(u.Host, segments, query)
+		if d.templates[template] >= d.options.MaxURLsPerTemplate {
+			return TrapTemplateBudget
+		}
+	}
+
+	// 所有规则都放行后才记录状态，被拦截的URL不占用查询参数组合和模板预算
+	if newVariant {
+		if d.variants[pathKey] == nil {
+			d.variants[pathKey] = make(map[string]bool)
+		}
+		d.variants[pathKey][variant] = true
+	}
+	if template != "" {
+		d.templates[template]++
+	}
+
+	return ""
+}
+
+// Report 返回每个主机按原因统计的拦截数量
+func (d *TrapDetector) Report() map[string]map[string]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 创建一个副本
+	report := make(map[string]map[string]int64, len(d.suppressed))
+	for host, reasons := range d.suppressed {
+		copied := make(map[string]int64, len(reasons))
+		for reason, n := range reasons {
+			copied[reason] = n
+		}
+		report[host] = copied
+	}
+	return report
+}
+
+// Clear 清空检测器状态
+func (d *TrapDetector) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.templates = make(map[string]int)
+	d.variants = make(map[string]map[string]bool)
+	d.suppressed = make(map[string]map[string]int64)
+}
+
+// splitPath 将路径拆分为非空路径段
+func splitPath(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// canonicalQuery 返回按键排序后的查询串，用于识别相同的参数组合
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			b.WriteString(k)
+			b.WriteByte('=')
+			b.WriteString(v)
+			b.WriteByte('&')
+		}
+	}
+	return b.String()
+}
+
+// pathTemplate 将数字、日期、哈希等可变路径段替换为占位符，
+// 查询参数只保留键名，例如 /cal/2024/05/01?day=3 -> /cal/{n}/{n}/{n}?day
+func pathTemplate(host string, segments []string, query url.Values) string {
+	var b strings.Builder
+	b.WriteString(host)
+	for _, seg := range segments {
+		b.WriteByte('/')
+		if isVariableSegment(seg) {
+			b.WriteString("{n}")
+		} else {
+			b.WriteString(seg)
+		}
+	}
+
+	if len(query) > 0 {
+		keys := make([]string, 0, len(query))
+		for k := range query {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('?')
+		b.WriteString(strings.Join(keys, "&"))
+	}
+	return b.String()
+}
+
+// isVariableSegment 判断路径段是否像ID、日期或哈希
+func isVariableSegment(seg string) bool {
+	digits, hex := 0, 0
+	for _, c := range seg {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+			hex++
+		case (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+			hex++
+		case c == '-' || c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	// 纯数字或日期，如 123、2024-05-01
+	if digits+strings.Count(seg, "-")+strings.Count(seg, "_")+strings.Count(seg, ".") == len(seg) {
+		return true
+	}
+	// 较长的十六进制串，如提交哈希、UUID
+	return hex >= 8
+}
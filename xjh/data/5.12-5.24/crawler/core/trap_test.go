@@ -0,0 +1,32 @@
+package core
+
+import "testing"
+
+func TestTrapDetectorRecordsOnlyAcceptedURLs(t *testing.T) {
+	d := NewTrapDetector(TrapOptions{MaxQueryVariants: 2, MaxURLsPerTemplate: 1})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://site.test/list?x=1", ""},
+		// 同一模板的预算已用完，拦截时不记录新的参数组合
+		{"http://site.test/list?x=2", TrapTemplateBudget},
+		{"http://site.test/list?x=3", TrapTemplateBudget},
+		// 路径下只放行过一种组合，另一个模板的URL仍可放行
+		{"http://site.test/list?y=1", ""},
+		{"http://site.test/list?z=1", TrapQueryExplosion},
+		// 已放行的组合不受组合数量限制，仍受模板预算限制
+		{"http://site.test/list?x=1", TrapTemplateBudget},
+	}
+	for _, tt := range tests {
+		if got := d.Check(tt.url); got != tt.want {
+			t.Errorf("Check(%s) = %q，期望 %q", tt.url, got, tt.want)
+		}
+	}
+
+	report := d.Report()["site.test"]
+	if report[TrapTemplateBudget] != 3 || report[TrapQueryExplosion] != 1 {
+		t.Errorf("拦截统计为 %v，期望模板预算3次、查询参数1次", report)
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Validator 记录一个URL上次抓取时的缓存校验信息，用于发送条件请求
+type Validator struct {
+	// 响应头中的ETag
+	ETag string `json:"etag,omitempty"`
+
+	// 响应头中的Last-Modified
+	LastModified string `json:"last_modified,omitempty"`
+
+	// 上次下载的内容长度，返回304时计入节省的流量
+	Size int64 `json:"size"`
+
+	// 上次解析出的链接，页面未变化时无需重新解析即可继续爬取
+	Links []string `json:"links,omitempty"`
+
+	// 上次解析出的结果，页面未变化时沿用，输出中不会缺少未变化的页面
+	Results []Result `json:"results,omitempty"`
+}
+
+// ValidatorStore 保存每个URL的校验信息，可在多次运行之间持久化
+type ValidatorStore struct {
+	data map[string]*Validator
+	mu   sync.RWMutex
+}
+
+// NewValidatorStore 创建一个新的校验信息存储
+func NewValidatorStore() *ValidatorStore {
+	return &ValidatorStore{
+		data: make(map[string]*Validator),
+	}
+}
+
+// Get 获取URL的校验信息
+func (s *ValidatorStore) Get(url string) (Validator, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.data[url]
+	if !ok {
+		return Validator{}, false
+	}
+	return *v, true
+}
+
+// Update 在成功抓取后更新URL的ETag、Last-Modified和内容长度。
+// 响应既没有ETag也没有Last-Modified时删除旧记录
+func (s *ValidatorStore) Update(url, etag, lastModified string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if etag == "" && lastModified == "" {
+		delete(s.data, url)
+		return
+	}
+
+	v, ok := s.data[url]
+	if !ok {
+		v = &Validator{}
+		s.data[url] = v
+	}
+	v.ETag = etag
+	v.LastModified = lastModified
+	v.Size = size
+}
+
+// SetLinks 记录URL解析出的链接
+func (s *ValidatorStore) SetLinks(url string, links []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.data[url]; ok {
+		v.Links = links
+	}
+}
+
+// SetResults 记录URL解析出的结果
+func (s *ValidatorStore) SetResults(url string, results []Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.data[url]; ok {
+		v.Results = results
+	}
+}
+
+// Len 返回记录的URL数量
+func (s *ValidatorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.data)
+}
+
+// SaveToFile 将校验信息保存到文件
+func (s *ValidatorStore) SaveToFile(filename string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// 创建文件
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer file.Close()
+
+	// 将校验信息编码为JSON
+	if err := json.NewEncoder(file).Encode(s.data); err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFromFile 从文件加载校验信息
+func (s *ValidatorStore) LoadFromFile(filename string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 打开文件
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	// 解码JSON
+	if err := json.NewDecoder(file).Decode(&s.data); err != nil {
+		return fmt.Errorf("解码数据失败: %w", err)
+	}
+	// 文件内容为 null 时解码结果为nil
+	if s.data == nil {
+		s.data = make(map[string]*Validator)
+	}
+
+	return nil
+}
@@ -0,0 +1,1436 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// XPath 编译后的XPath 1.0表达式，在 ParseHTML 得到的节点树上求值。支持：
+//   - 全部13个轴中除 namespace 外的轴，以及缩写 @、.、..、//
+//   - 名称测试、*、node()、text()、comment()，HTML的元素名和属性名不区分大小写
+//   - 谓词、位置谓词、并集 |、算术、比较和逻辑运算
+//   - 核心函数库中的节点集、字符串、布尔和数字函数，如 contains()、normalize-space()
+//
+// 求值结果为 []*Node（按文档顺序的节点集）、string、float64 或 bool
+type XPath struct {
+	source string
+	expr   xpathExpr
+}
+
+// CompileXPath 编译XPath表达式
+func CompileXPath(source string) (*XPath, error) {
+	tokens, err := lexXPath(source)
+	if err != nil {
+		return nil, fmt.Errorf("XPath %q 无效: %w", source, err)
+	}
+
+	p := &xpathParser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err == nil && p.peek().kind != xtEOF {
+		err = fmt.Errorf("位置 %d 有多余的内容 %q", p.peek().pos, p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("XPath %q 无效: %w", source, err)
+	}
+	return &XPath{source: source, expr: expr}, nil
+}
+
+// MustCompileXPath 编译XPath表达式，无效时panic
+func MustCompileXPath(source string) *XPath {
+	x, err := CompileXPath(source)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// String 返回表达式的原文
+func (x *XPath) String() string {
+	return x.source
+}
+
+// Evaluate 以 node 为上下文节点求值，绝对路径从 node 所在文档的根节点开始
+func (x *XPath) Evaluate(node *Node) (interface{}, error) {
+	ctx := &xpathContext{node: node, position: 1, size: 1, eval: &xpathEvaluator{attrs: make(map[*Node][]*Node)}}
+	value, err := x.expr.eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("XPath %q 求值失败: %w", x.source, err)
+	}
+	return value, nil
+}
+
+// Select 返回表达式选中的节点，表达式的结果不是节点集时返回错误
+func (x *XPath) Select(node *Node) ([]*Node, error) {
+	value, err := x.Evaluate(node)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := value.([]*Node)
+	if !ok {
+		return nil, fmt.Errorf("XPath %q 的结果不是节点集", x.source)
+	}
+	return nodes, nil
+}
+
+// EvaluateString 求值并按 string() 函数的规则转换为字符串
+func (x *XPath) EvaluateString(node *Node) (string, error) {
+	value, err := x.Evaluate(node)
+	if err != nil {
+		return "", err
+	}
+	return xpathString(value), nil
+}
+
+// XPathString 按 string() 函数的规则把 Evaluate 的结果转换为字符串
+func XPathString(value interface{}) string {
+	return xpathString(value)
+}
+
+// ---- 词法分析 ----
+
+// xpathTokenKind 词法单元类型
+type xpathTokenKind int
+
+const (
+	xtEOF xpathTokenKind = iota
+	xtName
+	xtNumber
+	xtString
+	xtOperator
+	xtPunct
+)
+
+// xpathToken XPath词法单元
+type xpathToken struct {
+	kind xpathTokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// lexXPath 把表达式切分为词法单元，按XPath规范区分 * 和 and/or/div/mod 是运算符还是名称
+func lexXPath(s string) ([]xpathToken, error) {
+	var tokens []xpathToken
+	i := 0
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			tokens = append(tokens, xpathToken{kind: xtEOF, pos: i})
+			return tokens, nil
+		}
+
+		start := i
+		c := s[i]
+		token := xpathToken{pos: start}
+
+		switch {
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("位置 %d 的字符串没有结束", i)
+			}
+			token.kind, token.text = xtString, s[i+1:i+1+end]
+			i += end + 2
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+				i++
+			}
+			n, err := strconv.ParseFloat(s[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("位置 %d 的数字无效: %s", start, s[start:i])
+			}
+			token.kind, token.text, token.num = xtNumber, s[start:i], n
+		case isNameStart(c):
+			i = scanName(s, i)
+			// 带前缀的名称，如 svg:rect 或 svg:*
+			if i+1 < len(s) && s[i] == ':' && s[i+1] != ':' {
+				if s[i+1] == '*' {
+					i += 2
+				} else if isNameStart(s[i+1]) {
+					i = scanName(s, i+1)
+				}
+			}
+			token.kind, token.text = xtName, s[start:i]
+		default:
+			for _, op := range []string{"//", "..", "::", "!=", "<=", ">="} {
+				if strings.HasPrefix(s[i:], op) {
+					token.text = op
+					break
+				}
+			}
+			if token.text == "" {
+				if !strings.ContainsRune("/.()[]@,|+-=<>*$", rune(c)) {
+					return nil, fmt.Errorf("位置 %d 有无效的字符 %q", i, c)
+				}
+				token.text = string(c)
+			}
+			token.kind = xtPunct
+			i += len(token.text)
+		}
+
+		// 前面有可以作为操作数结尾的单元时，* 是乘号，名称是运算符名
+		if len(tokens) > 0 && !precedesOperand(tokens[len(tokens)-1]) {
+			switch {
+			case token.kind == xtPunct && token.text == "*":
+				token.kind = xtOperator
+			case token.kind == xtName && (token.text == "and" || token.text == "or" || token.text == "div" || token.text == "mod"):
+				token.kind = xtOperator
+			}
+		}
+		if token.kind == xtPunct && xpathOperators[token.text] {
+			token.kind = xtOperator
+		}
+
+		tokens = append(tokens, token)
+	}
+}
+
+// xpathOperators 总是作为运算符的符号
+var xpathOperators = map[string]bool{
+	"/": true, "//": true, "|": true, "+": true, "-": true,
+	"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+}
+
+// precedesOperand 检查词法单元之后是否应出现操作数（而不是运算符）
+func precedesOperand(t xpathToken) bool {
+	if t.kind == xtOperator {
+		return true
+	}
+	return t.kind == xtPunct && (t.text == "@" || t.text == "::" || t.text == "(" || t.text == "[" || t.text == ",")
+}
+
+// scanName 从 i 开始读取一个不含冒号的名称，返回名称之后的位置
+func scanName(s string, i int) int {
+	for i < len(s) {
+		c := s[i]
+		if isNameStart(c) || isDigit(c) || c == '-' || c == '.' {
+			i++
+			continue
+		}
+		break
+	}
+	return i
+}
+
+// isNameStart 检查字节是否可以作为名称的开头
+func isNameStart(c byte) bool {
+	return isLetter(c) || c == '_' || c >= 0x80
+}
+
+// isDigit 检查是否是ASCII数字
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ---- 语法分析 ----
+
+// xpathParser XPath表达式的递归下降解析器
+type xpathParser struct {
+	tokens []xpathToken
+	pos    int
+}
+
+// peek 返回当前词法单元
+func (p *xpathParser) peek() xpathToken {
+	return p.tokens[p.pos]
+}
+
+// peekAt 返回之后第 n 个词法单元
+func (p *xpathParser) peekAt(n int) xpathToken {
+	if p.pos+n < len(p.tokens) {
+		return p.tokens[p.pos+n]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+// next 读取当前词法单元
+func (p *xpathParser) next() xpathToken {
+	t := p.tokens[p.pos]
+	if t.kind != xtEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is 检查当前词法单元是否是指定的运算符或标点
+func (p *xpathParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == xtOperator || t.kind == xtPunct) && t.text == text
+}
+
+// expect 读取指定的标点，不匹配时返回错误
+func (p *xpathParser) expect(text string) error {
+	if !p.is(text) {
+		t := p.peek()
+		if t.kind == xtEOF {
+			return fmt.Errorf("表达式意外结束，缺少 %q", text)
+		}
+		return fmt.Errorf("位置 %d 缺少 %q", t.pos, text)
+	}
+	p.next()
+	return nil
+}
+
+// parseExpr 解析表达式
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	return p.parseBinary(0)
+}
+
+// xpathPrecedence 二元运算符的优先级，从低到高
+var xpathPrecedence = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+// parseBinary 按优先级解析二元运算
+func (p *xpathParser) parseBinary(level int) (xpathExpr, error) {
+	if level == len(xpathPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != xtOperator || !containsString(xpathPrecedence[level], t.text) {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right}
+	}
+}
+
+// parseUnary 解析一元负号
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.is("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{operand: operand}, nil
+	}
+	return p.parseUnion()
+}
+
+// parseUnion 解析并集
+func (p *xpathParser) parseUnion() (xpathExpr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("|") {
+		p.next()
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parsePath 解析路径表达式：位置路径，或过滤表达式后接相对路径
+func (p *xpathParser) parsePath() (xpathExpr, error) {
+	switch {
+	case p.is("/"):
+		p.next()
+		path := &pathExpr{absolute: true}
+		if p.startsStep() {
+			steps, err := p.parseRelativePath()
+			if err != nil {
+				return nil, err
+			}
+			path.steps = steps
+		}
+		return path, nil
+	case p.is("//"):
+		p.next()
+		steps, err := p.parseRelativePath()
+		if err != nil {
+			return nil, err
+		}
+		return &pathExpr{absolute: true, steps: append([]*xpathStep{descendantOrSelf()}, steps...)}, nil
+	case p.startsStep():
+		steps, err := p.parseRelativePath()
+		if err != nil {
+			return nil, err
+		}
+		return &pathExpr{steps: steps}, nil
+	}
+
+	filter, err := p.parseFilter()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("/") && !p.is("//") {
+		return filter, nil
+	}
+
+	var steps []*xpathStep
+	if p.next().text == "//" {
+		steps = append(steps, descendantOrSelf())
+	}
+	rest, err := p.parseRelativePath()
+	if err != nil {
+		return nil, err
+	}
+	return &pathExpr{start: filter, steps: append(steps, rest...)}, nil
+}
+
+// startsStep 检查当前位置是否是一个定位步的开始
+func (p *xpathParser) startsStep() bool {
+	t := p.peek()
+	switch t.kind {
+	case xtName:
+		// 函数调用不是定位步，但节点类型测试是
+		if next := p.peekAt(1); next.kind == xtPunct && next.text == "(" {
+			return isNodeType(t.text)
+		}
+		return true
+	case xtPunct:
+		return t.text == "*" || t.text == "@" || t.text == "." || t.text == ".."
+	}
+	return false
+}
+
+// parseRelativePath 解析由 / 和 // 连接的定位步
+func (p *xpathParser) parseRelativePath() ([]*xpathStep, error) {
+	var steps []*xpathStep
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+
+		switch {
+		case p.is("/"):
+			p.next()
+		case p.is("//"):
+			p.next()
+			steps = append(steps, descendantOrSelf())
+		default:
+			return steps, nil
+		}
+	}
+}
+
+// xpathAxes 支持的轴
+var xpathAxes = map[string]bool{
+	"ancestor": true, "ancestor-or-self": true, "attribute": true, "child": true, "descendant": true,
+	"descendant-or-self": true, "following": true, "following-sibling": true, "parent": true,
+	"preceding": true, "preceding-sibling": true, "self": true,
+}
+
+// parseStep 解析一个定位步
+func (p *xpathParser) parseStep() (*xpathStep, error) {
+	if p.is(".") {
+		p.next()
+		return &xpathStep{axis: "self", test: nodeTest{kind: "node"}}, nil
+	}
+	if p.is("..") {
+		p.next()
+		return &xpathStep{axis: "parent", test: nodeTest{kind: "node"}}, nil
+	}
+
+	step := &xpathStep{axis: "child"}
+	if p.is("@") {
+		p.next()
+		step.axis = "attribute"
+	} else if t := p.peek(); t.kind == xtName && p.peekAt(1).text == "::" {
+		if t.text == "namespace" {
+			return nil, fmt.Errorf("不支持 namespace 轴")
+		}
+		if !xpathAxes[t.text] {
+			return nil, fmt.Errorf("位置 %d 有未知的轴 %s", t.pos, t.text)
+		}
+		p.next()
+		p.next()
+		step.axis = t.text
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == xtPunct && t.text == "*":
+		step.test = nodeTest{kind: "*"}
+	case t.kind == xtName && isNodeType(t.text) && p.is("("):
+		p.next()
+		// processing-instruction() 可以带一个字符串参数
+		if t.text == "processing-instruction" && p.peek().kind == xtString {
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		step.test = nodeTest{kind: t.text}
+	case t.kind == xtName:
+		name := strings.ToLower(t.text)
+		if strings.HasSuffix(name, ":*") {
+			step.test = nodeTest{kind: "prefix", name: strings.TrimSuffix(name, "*")}
+		} else {
+			step.test = nodeTest{kind: "name", name: name}
+		}
+	case t.kind == xtEOF:
+		return nil, fmt.Errorf("表达式意外结束，缺少节点测试")
+	default:
+		return nil, fmt.Errorf("位置 %d 缺少节点测试", t.pos)
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	step.predicates = predicates
+	return step, nil
+}
+
+// parsePredicates 解析谓词列表
+func (p *xpathParser) parsePredicates() ([]xpathExpr, error) {
+	var predicates []xpathExpr
+	for p.is("[") {
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, expr)
+	}
+	return predicates, nil
+}
+
+// parseFilter 解析基本表达式及其谓词
+func (p *xpathParser) parseFilter() (xpathExpr, error) {
+	var primary xpathExpr
+	t := p.peek()
+
+	switch {
+	case t.kind == xtString:
+		p.next()
+		primary = literalExpr{value: t.text}
+	case t.kind == xtNumber:
+		p.next()
+		primary = literalExpr{value: t.num}
+	case t.kind == xtPunct && t.text == "(":
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		primary = expr
+	case t.kind == xtPunct && t.text == "$":
+		return nil, fmt.Errorf("位置 %d: 不支持变量", t.pos)
+	case t.kind == xtName && p.peekAt(1).text == "(":
+		call, err := p.parseFunctionCall()
+		if err != nil {
+			return nil, err
+		}
+		primary = call
+	case t.kind == xtEOF:
+		return nil, fmt.Errorf("表达式意外结束")
+	default:
+		return nil, fmt.Errorf("位置 %d 有意外的 %q", t.pos, t.text)
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	if len(predicates) == 0 {
+		return primary, nil
+	}
+	return &filterExpr{primary: primary, predicates: predicates}, nil
+}
+
+// parseFunctionCall 解析函数调用并检查参数个数
+func (p *xpathParser) parseFunctionCall() (xpathExpr, error) {
+	name := p.next()
+	p.next()
+
+	fn, ok := xpathFunctions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("位置 %d 有未知的函数 %s()", name.pos, name.text)
+	}
+
+	var args []xpathExpr
+	if !p.is(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("函数 %s() 的参数个数错误: %d", name.text, len(args))
+	}
+	return &functionExpr{name: name.text, fn: fn, args: args}, nil
+}
+
+// isNodeType 检查名称是否是节点类型测试
+func isNodeType(name string) bool {
+	return name == "node" || name == "text" || name == "comment" || name == "processing-instruction"
+}
+
+// descendantOrSelf 返回 // 缩写对应的定位步
+func descendantOrSelf() *xpathStep {
+	return &xpathStep{axis: "descendant-or-self", test: nodeTest{kind: "node"}}
+}
+
+// ---- 求值 ----
+
+// xpathEvaluator 一次求值过程中共享的状态
+type xpathEvaluator struct {
+	// 元素 -> 属性节点，同一属性在一次求值中只创建一个节点
+	attrs map[*Node][]*Node
+
+	// 节点 -> 文档顺序，需要排序时才遍历文档建立
+	order map[*Node]int
+	next  int
+}
+
+// attributes 返回元素的属性节点
+func (e *xpathEvaluator) attributes(n *Node) []*Node {
+	if n.Type != ElementNode {
+		return nil
+	}
+	if nodes, ok := e.attrs[n]; ok {
+		return nodes
+	}
+	nodes := make([]*Node, len(n.Attrs))
+	for i, a := range n.Attrs {
+		nodes[i] = &Node{Type: AttributeNode, Data: a.Key, Attrs: []Attribute{a}, Parent: n}
+	}
+	e.attrs[n] = nodes
+	return nodes
+}
+
+// xpathContext 求值上下文：上下文节点、位置和大小
+type xpathContext struct {
+	node     *Node
+	position int
+	size     int
+	eval     *xpathEvaluator
+}
+
+// with 返回以另一个节点为上下文的副本
+func (c *xpathContext) with(node *Node, position, size int) *xpathContext {
+	return &xpathContext{node: node, position: position, size: size, eval: c.eval}
+}
+
+// xpathExpr 可以求值的表达式
+type xpathExpr interface {
+	eval(ctx *xpathContext) (interface{}, error)
+}
+
+// literalExpr 字符串或数字字面量
+type literalExpr struct {
+	value interface{}
+}
+
+func (e literalExpr) eval(*xpathContext) (interface{}, error) {
+	return e.value, nil
+}
+
+// negateExpr 一元负号
+type negateExpr struct {
+	operand xpathExpr
+}
+
+func (e *negateExpr) eval(ctx *xpathContext) (interface{}, error) {
+	v, err := e.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return -xpathNumber(v), nil
+}
+
+// unionExpr 节点集并集
+type unionExpr struct {
+	left, right xpathExpr
+}
+
+func (e *unionExpr) eval(ctx *xpathContext) (interface{}, error) {
+	left, err := evalNodes(e.left, ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := evalNodes(e.right, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.eval.sortNodes(append(append([]*Node(nil), left...), right...)), nil
+}
+
+// binaryExpr 二元运算
+type binaryExpr struct {
+	op          string
+	left, right xpathExpr
+}
+
+func (e *binaryExpr) eval(ctx *xpathContext) (interface{}, error) {
+	left, err := e.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// and 和 or 短路求值
+	switch e.op {
+	case "and", "or":
+		if xpathBoolean(left) == (e.op == "or") {
+			return e.op == "or", nil
+		}
+		right, err := e.right.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return xpathBoolean(right), nil
+	}
+
+	right, err := e.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return xpathCompare(e.op, left, right), nil
+	}
+
+	a, b := xpathNumber(left), xpathNumber(right)
+	switch e.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "div":
+		return a / b, nil
+	default:
+		return math.Mod(a, b), nil
+	}
+}
+
+// filterExpr 带谓词的基本表达式
+type filterExpr struct {
+	primary    xpathExpr
+	predicates []xpathExpr
+}
+
+func (e *filterExpr) eval(ctx *xpathContext) (interface{}, error) {
+	nodes, err := evalNodes(e.primary, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, predicate := range e.predicates {
+		if nodes, err = filterNodes(ctx, nodes, predicate); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// pathExpr 位置路径，start 不为nil时从过滤表达式的结果开始
+type pathExpr struct {
+	absolute bool
+	start    xpathExpr
+	steps    []*xpathStep
+}
+
+func (e *pathExpr) eval(ctx *xpathContext) (interface{}, error) {
+	var nodes []*Node
+	switch {
+	case e.absolute:
+		root := ctx.node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		nodes = []*Node{root}
+	case e.start != nil:
+		var err error
+		if nodes, err = evalNodes(e.start, ctx); err != nil {
+			return nil, err
+		}
+	default:
+		nodes = []*Node{ctx.node}
+	}
+
+	for _, step := range e.steps {
+		var next []*Node
+		for _, n := range nodes {
+			selected, err := step.apply(ctx, n)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		if len(nodes) > 1 {
+			next = ctx.eval.sortNodes(next)
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// nodeTest 节点测试
+type nodeTest struct {
+	// name、prefix、*、node、text、comment、processing-instruction
+	kind string
+	name string
+}
+
+// matches 检查节点是否通过测试，principal 为轴的主节点类型
+func (t nodeTest) matches(n *Node, principal NodeType) bool {
+	switch t.kind {
+	case "node":
+		return true
+	case "text":
+		return n.Type == TextNode
+	case "comment":
+		return n.Type == CommentNode
+	case "processing-instruction":
+		return false
+	case "*":
+		return n.Type == principal
+	case "prefix":
+		return n.Type == principal && strings.HasPrefix(n.Data, t.name)
+	default:
+		return n.Type == principal && strings.ToLower(n.Data) == t.name
+	}
+}
+
+// xpathStep 定位步：轴、节点测试和谓词
+type xpathStep struct {
+	axis       string
+	test       nodeTest
+	predicates []xpathExpr
+}
+
+// apply 从一个上下文节点应用定位步，按文档顺序返回选中的节点
+func (s *xpathStep) apply(ctx *xpathContext, n *Node) ([]*Node, error) {
+	principal := ElementNode
+	if s.axis == "attribute" {
+		principal = AttributeNode
+	}
+
+	var nodes []*Node
+	for _, candidate := range axisNodes(ctx.eval, s.axis, n) {
+		if s.test.matches(candidate, principal) {
+			nodes = append(nodes, candidate)
+		}
+	}
+
+	// 谓词中的位置按轴的方向计算，反向轴的节点按文档逆序排列
+	for _, predicate := range s.predicates {
+		var err error
+		if nodes, err = filterNodes(ctx, nodes, predicate); err != nil {
+			return nil, err
+		}
+	}
+
+	if isReverseAxis(s.axis) {
+		for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		}
+	}
+	return nodes, nil
+}
+
+// isReverseAxis 检查是否是反向轴
+func isReverseAxis(axis string) bool {
+	return axis == "ancestor" || axis == "ancestor-or-self" || axis == "preceding" || axis == "preceding-sibling"
+}
+
+// axisNodes 按轴的方向返回轴上的节点
+func axisNodes(e *xpathEvaluator, axis string, n *Node) []*Node {
+	var nodes []*Node
+	switch axis {
+	case "self":
+		nodes = append(nodes, n)
+	case "child":
+		if n.Type != AttributeNode {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				nodes = append(nodes, c)
+			}
+		}
+	case "descendant", "descendant-or-self":
+		if axis == "descendant-or-self" {
+			nodes = append(nodes, n)
+		}
+		if n.Type != AttributeNode {
+			nodes = appendDescendants(nodes, n)
+		}
+	case "parent":
+		if n.Parent != nil {
+			nodes = append(nodes, n.Parent)
+		}
+	case "ancestor", "ancestor-or-self":
+		if axis == "ancestor-or-self" {
+			nodes = append(nodes, n)
+		}
+		for p := n.Parent; p != nil; p = p.Parent {
+			nodes = append(nodes, p)
+		}
+	case "attribute":
+		nodes = e.attributes(n)
+	case "following-sibling":
+		if n.Type != AttributeNode {
+			for s := n.NextSibling; s != nil; s = s.NextSibling {
+				nodes = append(nodes, s)
+			}
+		}
+	case "preceding-sibling":
+		if n.Type != AttributeNode {
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				nodes = append(nodes, s)
+			}
+		}
+	case "following":
+		// 属性之后的节点包括所属元素的后代
+		if n.Type == AttributeNode {
+			n = n.Parent
+			nodes = appendDescendants(nodes, n)
+		}
+		for a := n; a != nil; a = a.Parent {
+			for s := a.NextSibling; s != nil; s = s.NextSibling {
+				nodes = append(nodes, s)
+				nodes = appendDescendants(nodes, s)
+			}
+		}
+	case "preceding":
+		if n.Type == AttributeNode {
+			n = n.Parent
+		}
+		// 按文档逆序：从最近的前一个兄弟开始，兄弟的后代在兄弟之前
+		for a := n; a != nil; a = a.Parent {
+			for s := a.PrevSibling; s != nil; s = s.PrevSibling {
+				var subtree []*Node
+				subtree = appendDescendants(append(subtree, s), s)
+				for i := len(subtree) - 1; i >= 0; i-- {
+					nodes = append(nodes, subtree[i])
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// appendDescendants 按文档顺序追加节点的所有后代
+func appendDescendants(nodes []*Node, n *Node) []*Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+		nodes = appendDescendants(nodes, c)
+	}
+	return nodes
+}
+
+// filterNodes 用谓词过滤节点，数字结果表示位置
+func filterNodes(ctx *xpathContext, nodes []*Node, predicate xpathExpr) ([]*Node, error) {
+	var kept []*Node
+	for i, n := range nodes {
+		v, err := predicate.eval(ctx.with(n, i+1, len(nodes)))
+		if err != nil {
+			return nil, err
+		}
+		if num, ok := v.(float64); ok {
+			if num == float64(i+1) {
+				kept = append(kept, n)
+			}
+		} else if xpathBoolean(v) {
+			kept = append(kept, n)
+		}
+	}
+	return kept, nil
+}
+
+// evalNodes 求值并要求结果是节点集
+func evalNodes(expr xpathExpr, ctx *xpathContext) ([]*Node, error) {
+	v, err := expr.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]*Node)
+	if !ok {
+		return nil, fmt.Errorf("表达式的结果不是节点集")
+	}
+	return nodes, nil
+}
+
+// sortNodes 按文档顺序排序并去重，已经按顺序排列时不排序
+func (e *xpathEvaluator) sortNodes(nodes []*Node) []*Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+
+	seen := make(map[*Node]bool, len(nodes))
+	unique := nodes[:0]
+	sorted := true
+	last := -1
+	for _, n := range nodes {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		unique = append(unique, n)
+		if position := e.position(n); position < last {
+			sorted = false
+		} else {
+			last = position
+		}
+	}
+
+	if !sorted {
+		sort.SliceStable(unique, func(i, j int) bool {
+			return e.position(unique[i]) < e.position(unique[j])
+		})
+	}
+	return unique
+}
+
+// position 返回节点在文档中的序号。第一次遇到某个文档时遍历一次整棵树为所有节点编号，
+// 元素之后为它的属性预留序号，属性排在所属元素之后、子节点之前
+func (e *xpathEvaluator) position(n *Node) int {
+	if n.Type == AttributeNode && n.Parent != nil {
+		index := 0
+		for _, a := range n.Parent.Attrs {
+			if a.Key == n.Data {
+				break
+			}
+			index++
+		}
+		return e.position(n.Parent) + 1 + index
+	}
+
+	if position, ok := e.order[n]; ok {
+		return position
+	}
+
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	if e.order == nil {
+		e.order = make(map[*Node]int)
+	}
+	var walk func(*Node)
+	walk = func(node *Node) {
+		e.order[node] = e.next
+		e.next += 1 + len(node.Attrs)
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return e.order[n]
+}
+
+// ---- 类型转换和比较 ----
+
+// xpathString 按 string() 函数的规则转换
+func xpathString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return formatXPathNumber(v)
+	case []*Node:
+		if len(v) == 0 {
+			return ""
+		}
+		return nodeString(v[0])
+	}
+	return ""
+}
+
+// nodeString 返回节点的字符串值，注释的字符串值是注释内容
+func nodeString(n *Node) string {
+	if n.Type == CommentNode {
+		return n.Data
+	}
+	return n.Text()
+}
+
+// formatXPathNumber 把数字转换为字符串，整数不带小数点
+func formatXPathNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// xpathNumber 按 number() 函数的规则转换
+func xpathNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		return parseXPathNumber(v)
+	case []*Node:
+		return parseXPathNumber(xpathString(v))
+	}
+	return math.NaN()
+}
+
+// parseXPathNumber 解析XPath数字：可选的负号、数字和小数点，前后可以有空白
+func parseXPathNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." || strings.Count(digits, ".") > 1 {
+		return math.NaN()
+	}
+	for i := 0; i < len(digits); i++ {
+		if !isDigit(digits[i]) && digits[i] != '.' {
+			return math.NaN()
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+// xpathBoolean 按 boolean() 函数的规则转换
+func xpathBoolean(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []*Node:
+		return len(v) > 0
+	}
+	return false
+}
+
+// xpathCompare 按XPath规则比较两个值，节点集与其他值比较时只要有一个节点满足即可
+func xpathCompare(op string, left, right interface{}) bool {
+	leftNodes, leftIsNodes := left.([]*Node)
+	rightNodes, rightIsNodes := right.([]*Node)
+
+	switch {
+	case leftIsNodes && rightIsNodes:
+		for _, a := range leftNodes {
+			for _, b := range rightNodes {
+				if compareAtoms(op, nodeString(a), nodeString(b)) {
+					return true
+				}
+			}
+		}
+		return false
+	case leftIsNodes || rightIsNodes:
+		nodes, other, flipped := leftNodes, right, false
+		if rightIsNodes {
+			nodes, other, flipped = rightNodes, left, true
+		}
+		if b, ok := other.(bool); ok {
+			if flipped {
+				return compareAtoms(op, b, len(nodes) > 0)
+			}
+			return compareAtoms(op, len(nodes) > 0, b)
+		}
+		for _, n := range nodes {
+			var value interface{} = nodeString(n)
+			if _, ok := other.(float64); ok {
+				value = parseXPathNumber(nodeString(n))
+			}
+			if flipped && compareAtoms(op, other, value) || !flipped && compareAtoms(op, value, other) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, left, right)
+}
+
+// compareAtoms 比较两个非节点集的值
+func compareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, leftBool := left.(bool)
+		_, rightBool := right.(bool)
+		_, leftNum := left.(float64)
+		_, rightNum := right.(float64)
+		switch {
+		case leftBool || rightBool:
+			equal = xpathBoolean(left) == xpathBoolean(right)
+		case leftNum || rightNum:
+			equal = xpathNumber(left) == xpathNumber(right)
+		default:
+			equal = xpathString(left) == xpathString(right)
+		}
+		return equal == (op == "=")
+	}
+
+	a, b := xpathNumber(left), xpathNumber(right)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// ---- 函数库 ----
+
+// xpathFunction 函数的参数个数和实现，maxArgs 为-1表示不限制
+type xpathFunction struct {
+	minArgs, maxArgs int
+	call             func(ctx *xpathContext, args []interface{}) (interface{}, error)
+}
+
+// functionExpr 函数调用
+type functionExpr struct {
+	name string
+	fn   xpathFunction
+	args []xpathExpr
+}
+
+func (e *functionExpr) eval(ctx *xpathContext) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return e.fn.call(ctx, args)
+}
+
+// xpathFunctions XPath 1.0核心函数库
+var xpathFunctions = map[string]xpathFunction{
+	"last": {0, 0, func(ctx *xpathContext, _ []interface{}) (interface{}, error) {
+		return float64(ctx.size), nil
+	}},
+	"position": {0, 0, func(ctx *xpathContext, _ []interface{}) (interface{}, error) {
+		return float64(ctx.position), nil
+	}},
+	"count": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		nodes, err := nodeArg("count", args[0])
+		return float64(len(nodes)), err
+	}},
+	"local-name": {0, 1, nameFunction("local-name", true)},
+	"name":       {0, 1, nameFunction("name", false)},
+	"namespace-uri": {0, 1, func(*xpathContext, []interface{}) (interface{}, error) {
+		return "", nil
+	}},
+	"string": {0, 1, func(ctx *xpathContext, args []interface{}) (interface{}, error) {
+		return xpathString(contextArg(ctx, args)), nil
+	}},
+	"concat": {2, -1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(xpathString(arg))
+		}
+		return b.String(), nil
+	}},
+	"starts-with": {2, 2, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return strings.HasPrefix(xpathString(args[0]), xpathString(args[1])), nil
+	}},
+	"contains": {2, 2, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return strings.Contains(xpathString(args[0]), xpathString(args[1])), nil
+	}},
+	"substring-before": {2, 2, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		before, _, found := strings.Cut(xpathString(args[0]), xpathString(args[1]))
+		if !found {
+			return "", nil
+		}
+		return before, nil
+	}},
+	"substring-after": {2, 2, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		_, after, _ := strings.Cut(xpathString(args[0]), xpathString(args[1]))
+		return after, nil
+	}},
+	"substring": {2, 3, xpathSubstring},
+	"string-length": {0, 1, func(ctx *xpathContext, args []interface{}) (interface{}, error) {
+		return float64(utf8.RuneCountInString(xpathString(contextArg(ctx, args)))), nil
+	}},
+	"normalize-space": {0, 1, func(ctx *xpathContext, args []interface{}) (interface{}, error) {
+		return strings.Join(strings.Fields(xpathString(contextArg(ctx, args))), " "), nil
+	}},
+	"translate": {3, 3, xpathTranslate},
+	"boolean": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return xpathBoolean(args[0]), nil
+	}},
+	"not": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return !xpathBoolean(args[0]), nil
+	}},
+	"true": {0, 0, func(*xpathContext, []interface{}) (interface{}, error) {
+		return true, nil
+	}},
+	"false": {0, 0, func(*xpathContext, []interface{}) (interface{}, error) {
+		return false, nil
+	}},
+	"lang": {1, 1, xpathLang},
+	"number": {0, 1, func(ctx *xpathContext, args []interface{}) (interface{}, error) {
+		return xpathNumber(contextArg(ctx, args)), nil
+	}},
+	"sum": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		nodes, err := nodeArg("sum", args[0])
+		total := 0.0
+		for _, n := range nodes {
+			total += parseXPathNumber(nodeString(n))
+		}
+		return total, err
+	}},
+	"floor": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return math.Floor(xpathNumber(args[0])), nil
+	}},
+	"ceiling": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return math.Ceil(xpathNumber(args[0])), nil
+	}},
+	"round": {1, 1, func(_ *xpathContext, args []interface{}) (interface{}, error) {
+		return xpathRound(xpathNumber(args[0])), nil
+	}},
+}
+
+// contextArg 返回可选的参数，省略时为只包含上下文节点的节点集
+func contextArg(ctx *xpathContext, args []interface{}) interface{} {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return []*Node{ctx.node}
+}
+
+// nodeArg 要求参数是节点集
+func nodeArg(function string, arg interface{}) ([]*Node, error) {
+	nodes, ok := arg.([]*Node)
+	if !ok {
+		return nil, fmt.Errorf("函数 %s() 的参数必须是节点集", function)
+	}
+	return nodes, nil
+}
+
+// nameFunction 返回 name() 或 local-name() 的实现
+func nameFunction(function string, local bool) func(*xpathContext, []interface{}) (interface{}, error) {
+	return func(ctx *xpathContext, args []interface{}) (interface{}, error) {
+		nodes, err := nodeArg(function, contextArg(ctx, args))
+		if err != nil || len(nodes) == 0 {
+			return "", err
+		}
+		n := nodes[0]
+		if n.Type != ElementNode && n.Type != AttributeNode {
+			return "", nil
+		}
+		if i := strings.IndexByte(n.Data, ':'); local && i != -1 {
+			return n.Data[i+1:], nil
+		}
+		return n.Data, nil
+	}
+}
+
+// xpathSubstring 实现 substring()，位置从1开始，按XPath规则对位置取整
+func xpathSubstring(_ *xpathContext, args []interface{}) (interface{}, error) {
+	runes := []rune(xpathString(args[0]))
+	start := xpathRound(xpathNumber(args[1]))
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + xpathRound(xpathNumber(args[2]))
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if pos := float64(i + 1); pos >= start && pos < end {
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+// xpathTranslate 实现 translate()，按字符替换，替换串较短时删除多出的字符
+func xpathTranslate(_ *xpathContext, args []interface{}) (interface{}, error) {
+	from, to := []rune(xpathString(args[1])), []rune(xpathString(args[2]))
+	mapping := make(map[rune]rune)
+	for i, r := range from {
+		if _, ok := mapping[r]; ok {
+			continue
+		}
+		if i < len(to) {
+			mapping[r] = to[i]
+		} else {
+			mapping[r] = -1
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if m, ok := mapping[r]; ok {
+			return m
+		}
+		return r
+	}, xpathString(args[0])), nil
+}
+
+// xpathLang 实现 lang()，按最近的 lang 属性判断
+func xpathLang(ctx *xpathContext, args []interface{}) (interface{}, error) {
+	want := strings.ToLower(xpathString(args[0]))
+	for n := ctx.node; n != nil; n = n.Parent {
+		if lang, ok := n.LookupAttr("lang"); ok && n.Type == ElementNode {
+			lang = strings.ToLower(lang)
+			return lang == want || strings.HasPrefix(lang, want+"-"), nil
+		}
+	}
+	return false, nil
+}
+
+// xpathRound 实现 round()，.5 向正无穷取整
+func xpathRound(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return math.Floor(f + 0.5)
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// xpathTestDocument 测试用的页面，元素用 id 区分
+const xpathTestDocument = `<html><head><title>商品列表</title></head><body>
+<div id="main" class="content wide">
+<h1 id="title">  Product
+   List  </h1>
+<ul id="items">
+<li id="a" class="item" data-price="10">Apple</li>
+<li id="b" class="item sale" data-price="25">Banana <b id="bold">fresh</b></li>
+<li id="c" class="item" data-price="7">Cherry</li>
+<li id="d" class="other">Date</li>
+</ul>
+<!-- footer note -->
+<p id="note">See <a id="link" href="/more?x=1&amp;y=2" title="More">more</a> here</p>
+</div>
+<div id="side"><span id="s1">one</span><span id="s2">two</span></div>
+</body></html>`
+
+// parseXPathTestDocument 解析测试页面
+func parseXPathTestDocument(t *testing.T) *Node {
+	t.Helper()
+	doc, err := ParseHTML(strings.NewReader(xpathTestDocument))
+	if err != nil {
+		t.Fatalf("解析页面失败: %v", err)
+	}
+	return doc
+}
+
+// describeNodes 把节点集转换为便于比较的描述：元素为 id（没有时为标签名），
+// 属性为 @名称=值，文本为 "文本"，注释为 <!--内容-->
+func describeNodes(nodes []*Node) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		switch n.Type {
+		case ElementNode:
+			if id, ok := n.LookupAttr("id"); ok {
+				parts[i] = id
+			} else {
+				parts[i] = n.Data
+			}
+		case AttributeNode:
+			parts[i] = "@" + n.Data + "=" + n.Attrs[0].Val
+		case TextNode:
+			parts[i] = `"` + n.Data + `"`
+		case CommentNode:
+			parts[i] = "<!--" + n.Data + "-->"
+		default:
+			parts[i] = "/"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// findByID 返回 id 对应的元素
+func findByID(t *testing.T, doc *Node, id string) *Node {
+	t.Helper()
+	var found *Node
+	doc.Walk(func(n *Node) {
+		if found == nil && n.Type == ElementNode && n.Attr("id") == id {
+			found = n
+		}
+	})
+	if found == nil {
+		t.Fatalf("页面中没有 id=%s 的元素", id)
+	}
+	return found
+}
+
+func TestXPathSelect(t *testing.T) {
+	doc := parseXPathTestDocument(t)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// 路径和缩写
+		{"/html/body/div", "main side"},
+		{"//li", "a b c d"},
+		{"//ul/li/b", "bold"},
+		{"//div//span", "s1 s2"},
+		{"//*[@id='items']/*", "a b c d"},
+		{"/", "/"},
+		{"//LI[@ID='a']", "a"},
+
+		// 轴
+		{"//b/parent::li", "b"},
+		{"//b/..", "b"},
+		{"//b/ancestor::*", "html body main items b"},
+		{"//b/ancestor-or-self::*[@id]", "main items b bold"},
+		{"//li[@id='b']/following-sibling::li", "c d"},
+		{"//li[@id='c']/preceding-sibling::li", "a b"},
+		{"//li[@id='c']/preceding-sibling::li[1]", "b"},
+		{"//b/ancestor::*[1]", "b"},
+		{"//h1/following::*[@id][position() <= 3]", "items a b"},
+		{"//p/preceding::li[1]", "d"},
+		{"//ul/descendant::*", "a b bold c d"},
+		{"//ul/descendant-or-self::*[@id='items' or @id='bold']", "items bold"},
+		{"//li[@id='a']/self::li", "a"},
+		{"//li[@id='a']/self::div", ""},
+		{"//li[@id='a']/attribute::data-price", "@data-price=10"},
+		{"//span/child::text()", `"one" "two"`},
+
+		// 谓词
+		{"//li[1]", "a"},
+		{"//li[last()]", "d"},
+		{"//li[position() > 2]", "c d"},
+		{"(//li)[2]", "b"},
+		{"//li[@class='item'][2]", "c"},
+		{"//li[2][@class='item']", ""},
+		{"//li[contains(@class, 'item')][last()]", "c"},
+		{"//li[@data-price > 8]", "a b"},
+		{"//li[@data-price]", "a b c"},
+		{"//li[not(@data-price)]", "d"},
+		{"//li[b]", "b"},
+		{"//li[count(*) = 0 and @class='item']", "a c"},
+		{"//li[@data-price * 2 = 20]", "a"},
+		{"//span | //h1", "title s1 s2"},
+
+		// text() 和 comment()
+		{"//li[@id='b']/text()", `"Banana "`},
+		{"//li[text()='Cherry']", "c"},
+		{"//div[@id='main']/comment()", "<!-- footer note -->"},
+
+		// @属性
+		{"//a/@href", "@href=/more?x=1&y=2"},
+		{"//li[@id='b']/@*", "@id=b @class=item sale @data-price=25"},
+		{"//*[@title='More']", "link"},
+
+		// contains() 和 normalize-space()
+		{"//li[contains(@class, 'sale')]", "b"},
+		{"//li[contains(., 'fresh')]", "b"},
+		{"//*[contains(text(), 'an')]", "b"},
+		{"//div[contains(concat(' ', normalize-space(@class), ' '), ' wide ')]", "main"},
+		{"//h1[normalize-space() = 'Product List']", "title"},
+		{"//h1[normalize-space(text()) = 'Product List']", "title"},
+		{"//p[normalize-space(.) = 'See more here']", "note"},
+	}
+	for _, tt := range tests {
+		x, err := CompileXPath(tt.expr)
+		if err != nil {
+			t.Errorf("编译 %s 失败: %v", tt.expr, err)
+			continue
+		}
+		nodes, err := x.Select(doc)
+		if err != nil {
+			t.Errorf("%s 求值失败: %v", tt.expr, err)
+			continue
+		}
+		if got := describeNodes(nodes); got != tt.want {
+			t.Errorf("%s 选中 %s，期望 %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestXPathEvaluateString(t *testing.T) {
+	doc := parseXPathTestDocument(t)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"//title", "商品列表"},
+		{"string(//title)", "商品列表"},
+		{"//title/text()", "商品列表"},
+		{"//li", "Apple"},
+		{"//li[@id='b']", "Banana fresh"},
+		{"//a/@href", "/more?x=1&y=2"},
+		{"//li[@id='b']/@data-price", "25"},
+		{"//missing", ""},
+		{"normalize-space(//h1)", "Product List"},
+		{"normalize-space('  a \n\t b  ')", "a b"},
+		{"contains(//p, 'more')", "true"},
+		{"contains(//p, 'less')", "false"},
+		{"count(//li)", "4"},
+		{"count(//li/@data-price)", "3"},
+		{"sum(//li/@data-price)", "42"},
+		{"sum(//li/@data-price) div count(//li[@data-price])", "14"},
+		{"//li[@id='c']/@data-price div 2", "3.5"},
+		{"round(2.5)", "3"},
+		{"floor(-1.5)", "-2"},
+		{"1 div 0", "Infinity"},
+		{"number('x')", "NaN"},
+		{"concat(//li[1], '-', //li[2]/text())", "Apple-Banana "},
+		{"substring-before(//a/@href, '?')", "/more"},
+		{"substring-after(//a/@href, '?')", "x=1&y=2"},
+		{"substring('12345', 2, 3)", "234"},
+		{"translate('bar', 'abc', 'ABC')", "BAr"},
+		{"starts-with(//h1, '  Prod')", "true"},
+		{"string-length(//title)", "4"},
+		{"name(//li[1]/@*[2])", "class"},
+		{"local-name(//*[@id='bold'])", "b"},
+		{"//li[@id='a']/following-sibling::li[1]", "Banana fresh"},
+		{"boolean(//li[@id='z'])", "false"},
+		{"//li[1]/@data-price < //li[2]/@data-price", "true"},
+		{"//li/@data-price = 7", "true"},
+		{"//li/@data-price != 10", "true"},
+	}
+	for _, tt := range tests {
+		got, err := MustCompileXPath(tt.expr).EvaluateString(doc)
+		if err != nil {
+			t.Errorf("%s 求值失败: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q，期望 %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestXPathRelativeContext(t *testing.T) {
+	doc := parseXPathTestDocument(t)
+	item := findByID(t, doc, "b")
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"b", "bold"},
+		{".", "b"},
+		{"..", "items"},
+		{"../li[@class='item']", "a c"},
+		{".//b", "bold"},
+		// 绝对路径从文档根开始，而不是上下文节点
+		{"//span", "s1 s2"},
+		{"/html/head/title", "title"},
+		{"following-sibling::*[1]", "c"},
+		{"preceding-sibling::*", "a"},
+		{"ancestor::div", "main"},
+	}
+	for _, tt := range tests {
+		nodes, err := MustCompileXPath(tt.expr).Select(item)
+		if err != nil {
+			t.Errorf("%s 求值失败: %v", tt.expr, err)
+			continue
+		}
+		if got := describeNodes(nodes); got != tt.want {
+			t.Errorf("以 li#b 为上下文 %s 选中 %s，期望 %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	invalid := []string{
+		"",
+		"//li[",
+		"//li]",
+		"//li[@id='a'",
+		"unknown-function()",
+		"contains('a')",
+		"//li/bogus::x",
+		"'unterminated",
+		"//li @id",
+	}
+	for _, expr := range invalid {
+		if _, err := CompileXPath(expr); err == nil {
+			t.Errorf("CompileXPath(%q) 没有返回错误", expr)
+		}
+	}
+
+	doc := parseXPathTestDocument(t)
+	if _, err := MustCompileXPath("count(//li)").Select(doc); err == nil {
+		t.Error("结果不是节点集时 Select 没有返回错误")
+	}
+}
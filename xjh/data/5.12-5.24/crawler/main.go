@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+	"example.com/m/xjh/data/5.12-5.24/crawler/plugins"
+	"example.com/m/xjh/data/5.12-5.24/crawler/utils"
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	// 命令行参数
 	startURL    = flag.String("url", "https://go.dev", "起始URL")
+	startMethod = flag.String("method", "GET", "起始URL的请求方法，如 POST")
+	startBody   = flag.String("body", "", "起始URL的请求体，是JSON时以 application/json 发送，否则以表单发送")
 	depth       = flag.Int("depth", 2, "最大爬取深度")
 	concurrency = flag.Int("concurrency", 5, "并发数")
 	timeout     = flag.Int("timeout", 30, "总超时时间(秒)")
@@ -18,8 +30,96 @@ var (
 	reqDelay    = flag.Int("delay", 100, "请求间隔(毫秒)")
 	outputFile  = flag.String("output", "results.json", "输出文件")
 	robotsTxt   = flag.Bool("robots", true, "是否遵守robots.txt")
+	reportFile  = flag.String("report", "", "compare模式下保存JSON比较报告的文件")
+	validators  = flag.String("validators", "", "ETag/Last-Modified记录文件，用于增量重爬的条件请求")
+
+	// 持续重爬参数
+	recrawl    = flag.Bool("recrawl", false, "持续运行，按页面变化频率定期重新访问URL（-timeout=0表示一直运行）")
+	recrawlMin = flag.Duration("recrawl-min", 10*time.Minute, "重新访问同一URL的最短间隔")
+	recrawlMax = flag.Duration("recrawl-max", 7*24*time.Hour, "重新访问同一URL的最长间隔")
+
+	// 爬虫陷阱检测参数
+	trapPathDepth   = flag.Int("trap-path-depth", 0, "最大路径深度，0表示不限制")
+	trapRepeatSeg   = flag.Int("trap-repeat-segments", 0, "同一路径段最大重复次数，0表示不限制")
+	trapTemplateCap = flag.Int("trap-template-budget", 0, "每个路径模板最多入队的URL数，0表示不限制")
+	trapQueryParams = flag.Int("trap-query-params", 0, "单个URL最大查询参数个数，0表示不限制")
+	trapQueryVars   = flag.Int("trap-query-variants", 0, "同一路径最多的查询参数组合数，0表示不限制")
+
+	// 爬取预算参数
+	maxPages     = flag.Int("max-pages", 0, "最大抓取页面数，0表示不限制")
+	maxBytes     = flag.Int64("max-bytes", 0, "最大下载字节数，0表示不限制")
+	maxHostPages = flag.Int("max-host-pages", 0, "每个主机的最大抓取页面数，0表示不限制")
+	prefixCaps   = flag.String("prefix-caps", "", "路径前缀页面上限，格式: example.com/docs=100,example.com/blog=20")
+
+	// 去重参数
+	dedup         = flag.String("dedup", "memory", "去重器类型: memory、bloom 或 disk")
+	bloomCapacity = flag.Uint64("bloom-capacity", 1<<20, "布隆过滤器初始容量")
+	bloomFPRate   = flag.Float64("bloom-fp", 0.001, "布隆过滤器误判率")
+	bloomFile     = flag.String("bloom-file", "", "布隆过滤器持久化文件，启动时加载、结束时保存")
+	dedupFile     = flag.String("dedup-file", "visited.log", "磁盘去重器的日志文件")
+	dedupTTL      = flag.Duration("dedup-ttl", 0, "磁盘去重器中URL的过期时间，如 168h，0表示永不过期")
+	frontierFile  = flag.String("frontier-file", "", "未抓取URL的保存文件，启动时加载、结束时保存，默认为持久化去重文件名加 .frontier")
+
+	// 近似重复检测参数
+	nearDup         = flag.Bool("near-dup", false, "是否检测内容近似重复的页面")
+	nearDupDist     = flag.Int("near-dup-distance", 3, "视为近似重复的最大SimHash汉明距离")
+	nearDupNoFollow = flag.Bool("near-dup-skip-links", false, "是否跳过近似重复页面中的链接")
+
+	// Cookie参数
+	enableCookies = flag.Bool("cookies", true, "是否启用Cookie")
+	jobName       = flag.String("job", "default", "爬取任务名称，Cookie按任务隔离")
+	cookieFile    = flag.String("cookie-file", "", "Cookie文件（.json为JSON格式，否则为Netscape cookies.txt），启动时加载、结束时保存")
+	pslFile       = flag.String("psl-file", "", "publicsuffix.org格式的公共后缀列表文件，默认使用内置列表")
+	seedCookies   stringList
+
+	// 认证参数
+	authSpecs stringList
+
+	// 代理参数
+	proxyList        = flag.String("proxies", "", "代理列表，逗号分隔，支持 http://、https://、socks5://")
+	proxyStrategy    = flag.String("proxy-strategy", "round-robin", "代理选择策略: round-robin、random 或 sticky（按主机固定）")
+	proxyMaxFailures = flag.Int("proxy-max-failures", 3, "代理连续失败多少次后暂停使用")
+	proxyCooldown    = flag.Duration("proxy-cooldown", time.Minute, "代理暂停使用后的冷却时间")
+
+	// 请求头参数
+	headerProfiles = flag.String("header-profiles", "", "按主机选择请求头的JSON配置文件")
+	userAgentsFile = flag.String("user-agents", "", "UA列表文件，每行一个，按主机轮换")
+
+	// 响应缓存参数
+	cacheDir     = flag.String("cache-dir", "", "HTTP响应缓存目录，为空时不使用缓存")
+	cacheMaxSize = flag.Int64("cache-max-size", 0, "缓存总大小上限（MB），0表示不限制")
+	cacheTTL     = flag.Duration("cache-ttl", 0, "响应没有新鲜度信息时的默认缓存时间")
+	offline      = flag.Bool("offline", false, "离线模式，只从缓存读取页面")
+
+	// WARC归档参数
+	warcDir      = flag.String("warc-dir", "", "WARC归档目录，为空时不写WARC文件")
+	warcPrefix   = flag.String("warc-prefix", "crawl", "WARC文件名前缀")
+	warcMaxSize  = flag.Int64("warc-max-size", 1024, "单个WARC文件的大小上限（MB），超出后切换到新文件，0表示不切换")
+	pagesDir     = flag.String("pages", "", "原始页面存储目录，爬取时保存页面，reparse模式下从中读取")
+	mirrorDir    = flag.String("mirror", "", "镜像目录，把页面和资源按URL路径保存并改写链接")
+	mirrorHosts  = flag.String("mirror-hosts", "", "镜像范围内的主机，逗号分隔，默认为起始URL的主机")
+	assetDir     = flag.String("assets", "", "资源下载目录，为空时不下载图片、PDF等资源")
+	assetWorkers = flag.Int("asset-workers", 2, "资源下载并发数")
+	assetMaxSize = flag.Int64("asset-max-size", 100, "单个资源的大小上限（MB），0表示不限制")
+	assetTypes   = flag.String("asset-types", "", "下载的资源后缀，逗号分隔，如 .pdf,.zip，默认为常见的图片、文档、压缩包和音视频")
+	replay       = flag.String("replay", "", "从WARC文件回放页面（文件、目录或通配符，逗号分隔），不访问网络")
+	rulesFile    = flag.String("rules", "", "CSS选择器/XPath提取规则的JSON文件，爬取和reparse时按规则提取结果")
+	jsonRules    = flag.String("json-rules", "", "JSON接口的JSONPath提取规则文件，从JSON响应中提取结果和下一页等请求")
+	streaming    = flag.Bool("stream", false, "边下载边解析页面，不在内存中保留完整页面（与 -pages、-near-dup、-assets 同时使用时不生效）")
 )
 
+// stringList 是可重复指定的字符串命令行参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 // CrawlerResults 存储爬虫结果的结构体
 type CrawlerResults struct {
 	StartTime    time.Time
@@ -30,8 +130,32 @@ type CrawlerResults struct {
 
 func main() {
 	// 解析命令行参数
+	flag.Var(&authSpecs, "auth", "认证配置，如 basic:host=intranet.local,user=alice,password=env:PASS，可重复指定")
+	flag.Var(&seedCookies, "cookie", "预置会话Cookie，格式: example.com:name=value，域名以.开头时对子域名生效，可重复指定")
 	flag.Parse()
 
+	// 比较两次爬取结果
+	if flag.Arg(0) == "compare" {
+		if flag.NArg() != 3 {
+			log.Fatalf("用法: go run main.go [-report=diff.json] compare old.json new.json")
+		}
+		if err := compareCrawls(flag.Arg(1), flag.Arg(2), *reportFile); err != nil {
+			log.Fatalf("比较爬取结果出错: %v", err)
+		}
+		return
+	}
+
+	// 重新解析存储的原始页面
+	if flag.Arg(0) == "reparse" {
+		if *pagesDir == "" {
+			log.Fatalf("用法: go run main.go -pages=pages [-output=results.json] reparse")
+		}
+		if err := reparsePages(*pagesDir, *outputFile); err != nil {
+			log.Fatalf("重新解析出错: %v", err)
+		}
+		return
+	}
+
 	// 创建爬虫选项
 	options := &core.Options{
 		MaxDepth:         *depth,
@@ -40,16 +164,322 @@ func main() {
 		RequestTimeout:   time.Duration(*reqTimeout) * time.Second,
 		RequestDelay:     time.Duration(*reqDelay) * time.Millisecond,
 		RespectRobotsTxt: *robotsTxt,
+		EnableCookies:    *enableCookies,
 		Headers: map[string]string{
 			"User-Agent": "GoCrawler/1.0 (https://example.com/bot)",
 		},
 	}
 
+	options.Streaming = *streaming
+
+	// 离线和回放模式不访问网络，也不获取robots.txt
+	if *offline || *replay != "" {
+		options.RespectRobotsTxt = false
+	}
+
+	// 配置爬取预算
+	options.MaxPages = *maxPages
+	options.MaxBytes = *maxBytes
+	options.MaxPagesPerHost = *maxHostPages
+	if *prefixCaps != "" {
+		caps, err := parsePrefixCaps(*prefixCaps)
+		if err != nil {
+			log.Fatalf("解析路径前缀上限出错: %v", err)
+		}
+		options.PathPrefixCaps = caps
+	}
+
+	// 配置近似重复检测
+	if *nearDup {
+		options.NearDuplicate = &core.NearDuplicateOptions{
+			MaxDistance: *nearDupDist,
+			SkipLinks:   *nearDupNoFollow,
+		}
+	}
+
+	// 配置爬虫陷阱检测
+	if *trapPathDepth > 0 || *trapRepeatSeg > 0 || *trapTemplateCap > 0 ||
+		*trapQueryParams > 0 || *trapQueryVars > 0 {
+		options.Trap = &core.TrapOptions{
+			MaxPathDepth:        *trapPathDepth,
+			MaxRepeatedSegments: *trapRepeatSeg,
+			MaxURLsPerTemplate:  *trapTemplateCap,
+			MaxQueryParams:      *trapQueryParams,
+			MaxQueryVariants:    *trapQueryVars,
+		}
+	}
+
 	// 创建爬虫引擎
 	crawler := core.NewEngine(options)
 
-	// 添加起始URL
-	crawler.AddURL(*startURL)
+	// 配置去重器
+	var bloomChecker *utils.BloomChecker
+	var diskChecker *utils.DiskChecker
+	switch *dedup {
+	case "memory":
+	case "bloom":
+		bloomChecker = utils.NewBloomChecker(*bloomCapacity, *bloomFPRate)
+		if *bloomFile != "" {
+			if _, err := os.Stat(*bloomFile); err == nil {
+				if err := bloomChecker.LoadFromFile(*bloomFile); err != nil {
+					log.Fatalf("加载布隆过滤器出错: %v", err)
+				}
+				fmt.Printf("已从 %s 加载 %d 个已爬取URL\n", *bloomFile, bloomChecker.Count())
+			}
+		}
+		crawler.SetDuplicateChecker(bloomChecker)
+	case "disk":
+		var err error
+		diskChecker, err = utils.NewDiskChecker(*dedupFile, *dedupTTL)
+		if err != nil {
+			log.Fatalf("打开磁盘去重器出错: %v", err)
+		}
+		defer func() {
+			if err := diskChecker.Close(); err != nil {
+				log.Printf("关闭去重日志出错: %v", err)
+			}
+		}()
+		fmt.Printf("已从 %s 加载 %d 个已爬取URL\n", *dedupFile, diskChecker.Len())
+		crawler.SetDuplicateChecker(diskChecker)
+	default:
+		log.Fatalf("未知的去重器类型: %s", *dedup)
+	}
+
+	// 去重器只记录抓取完成的URL，爬取中途停止时队列中的URL保存到单独的文件，下次运行时继续抓取
+	frontier := *frontierFile
+	if frontier == "" {
+		switch {
+		case bloomChecker != nil && *bloomFile != "":
+			frontier = *bloomFile + ".frontier"
+		case diskChecker != nil:
+			frontier = *dedupFile + ".frontier"
+		}
+	}
+	if frontier != "" {
+		if _, err := os.Stat(frontier); err == nil {
+			n, err := crawler.LoadFrontier(frontier)
+			if err != nil {
+				log.Fatalf("加载未抓取URL出错: %v", err)
+			}
+			fmt.Printf("已从 %s 恢复 %d 个未抓取URL\n", frontier, n)
+		}
+	}
+
+	// 配置Cookie管理器
+	var cookieJar *plugins.CookieJar
+	if *enableCookies {
+		var psl *plugins.PublicSuffixList
+		if *pslFile != "" {
+			var err error
+			if psl, err = plugins.LoadPublicSuffixFile(*pslFile); err != nil {
+				log.Fatalf("加载公共后缀列表出错: %v", err)
+			}
+		}
+		cookieJar = plugins.NewCookieJar(*jobName, psl)
+
+		if *cookieFile != "" {
+			if _, err := os.Stat(*cookieFile); err == nil {
+				if err := cookieJar.LoadFromFile(*cookieFile); err != nil {
+					log.Fatalf("加载Cookie出错: %v", err)
+				}
+			}
+		}
+		for _, seed := range seedCookies {
+			domain, pair, ok1 := strings.Cut(seed, ":")
+			name, value, ok2 := strings.Cut(pair, "=")
+			if !ok1 || !ok2 {
+				log.Fatalf("无效的Cookie %q，格式应为 example.com:name=value", seed)
+			}
+			cookieJar.AddCookie(domain, name, value)
+		}
+		crawler.SetCookieJar(cookieJar)
+	}
+
+	// 配置按主机选择的请求头
+	if *headerProfiles != "" || *userAgentsFile != "" {
+		profiles := plugins.NewHeaderProfiles(nil)
+		if *headerProfiles != "" {
+			var err error
+			if profiles, err = plugins.LoadHeaderProfiles(*headerProfiles); err != nil {
+				log.Fatalf("加载请求头配置出错: %v", err)
+			}
+		}
+		if *userAgentsFile != "" {
+			data, err := os.ReadFile(*userAgentsFile)
+			if err != nil {
+				log.Fatalf("读取UA列表出错: %v", err)
+			}
+			var agents []string
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+					agents = append(agents, line)
+				}
+			}
+			profiles.AddProfile(&plugins.HeaderProfile{Hosts: []string{"*"}, UserAgents: agents})
+		}
+		crawler.SetHeaderProvider(profiles)
+	}
+
+	// 配置代理池
+	var proxyPool *plugins.ProxyPool
+	if *proxyList != "" {
+		var err error
+		proxyPool, err = plugins.NewProxyPool(strings.Split(*proxyList, ","), *proxyStrategy,
+			*proxyMaxFailures, *proxyCooldown)
+		if err != nil {
+			log.Fatalf("创建代理池出错: %v", err)
+		}
+		httpFetcher, ok := crawler.GetFetcher().(*core.HTTPFetcher)
+		if !ok {
+			log.Fatalf("代理只支持HTTP抓取器")
+		}
+		crawler.SetFetcher(plugins.NewProxyFetcher(httpFetcher, proxyPool))
+	}
+
+	// 配置原始页面存储
+	if *pagesDir != "" {
+		pageStore, err := core.NewPageStore(*pagesDir)
+		if err != nil {
+			log.Fatalf("创建原始页面存储出错: %v", err)
+		}
+		crawler.SetPageStore(pageStore)
+	}
+
+	// 配置解析器
+	parser, err := newParser()
+	if err != nil {
+		log.Fatalf("加载提取规则出错: %v", err)
+	}
+	crawler.SetParser(parser)
+
+	// 配置镜像模式，只跟随镜像范围内的链接
+	var mirror *plugins.Mirror
+	if *mirrorDir != "" {
+		hosts := strings.Split(*mirrorHosts, ",")
+		if *mirrorHosts == "" {
+			u, err := url.Parse(*startURL)
+			if err != nil {
+				log.Fatalf("无效的起始URL: %v", err)
+			}
+			hosts = []string{u.Host}
+		}
+		var err error
+		if mirror, err = plugins.NewMirror(*mirrorDir, hosts); err != nil {
+			log.Fatalf("创建镜像出错: %v", err)
+		}
+		crawler.SetParser(plugins.NewMirrorParser(parser, mirror))
+	}
+
+	// 配置资源下载
+	if *assetDir != "" {
+		httpFetcher := crawler.GetHTTPFetcher()
+		if httpFetcher == nil {
+			log.Fatalf("资源下载只支持HTTP抓取器")
+		}
+		var suffixes []string
+		if *assetTypes != "" {
+			suffixes = strings.Split(*assetTypes, ",")
+		}
+		assetPool, err := plugins.NewAssetPool(httpFetcher, plugins.AssetOptions{
+			Dir:      *assetDir,
+			Workers:  *assetWorkers,
+			MaxSize:  *assetMaxSize * 1024 * 1024,
+			Suffixes: suffixes,
+		})
+		if err != nil {
+			log.Fatalf("创建资源下载池出错: %v", err)
+		}
+		crawler.SetAssetDownloader(assetPool)
+	}
+
+	// 配置WARC回放，替换HTTP抓取器
+	if *replay != "" {
+		warcFetcher, err := plugins.NewWARCFetcher(strings.Split(*replay, ",")...)
+		if err != nil {
+			log.Fatalf("打开WARC归档出错: %v", err)
+		}
+		fmt.Printf("已从WARC归档索引 %d 个URL\n", warcFetcher.Len())
+		crawler.SetFetcher(warcFetcher)
+	}
+
+	// 配置WARC归档，记录每个从网络收到的响应
+	var warcWriter *plugins.WARCWriter
+	if *warcDir != "" {
+		var err error
+		warcWriter, err = plugins.NewWARCWriter(plugins.WARCOptions{
+			Dir:     *warcDir,
+			Prefix:  *warcPrefix,
+			MaxSize: *warcMaxSize * 1024 * 1024,
+		})
+		if err != nil {
+			log.Fatalf("创建WARC写入器出错: %v", err)
+		}
+		crawler.SetResponseRecorder(warcWriter)
+	}
+
+	// 配置响应缓存，放在最外层，命中缓存时不经过代理
+	var cacheFetcher *plugins.CacheFetcher
+	if *cacheDir != "" {
+		var err error
+		cacheFetcher, err = plugins.NewCacheFetcher(crawler.GetFetcher(), plugins.CacheOptions{
+			Dir:        *cacheDir,
+			MaxSize:    *cacheMaxSize * 1024 * 1024,
+			Offline:    *offline,
+			DefaultTTL: *cacheTTL,
+		})
+		if err != nil {
+			log.Fatalf("创建响应缓存出错: %v", err)
+		}
+		crawler.SetFetcher(cacheFetcher)
+	} else if *offline {
+		log.Fatalf("离线模式需要指定 -cache-dir")
+	}
+
+	// 配置认证，凭据只从环境变量或文件读取
+	for _, spec := range authSpecs {
+		auth, err := core.ParseAuthenticator(spec)
+		if err != nil {
+			log.Fatalf("解析认证配置出错: %v", err)
+		}
+		crawler.AddAuthenticator(auth)
+	}
+
+	// 配置条件请求
+	var validatorStore *core.ValidatorStore
+	if *validators != "" {
+		validatorStore = core.NewValidatorStore()
+		if _, err := os.Stat(*validators); err == nil {
+			if err := validatorStore.LoadFromFile(*validators); err != nil {
+				log.Fatalf("加载校验信息出错: %v", err)
+			}
+			fmt.Printf("已从 %s 加载 %d 个URL的校验信息\n", *validators, validatorStore.Len())
+		}
+		crawler.SetValidatorStore(validatorStore)
+	}
+
+	// 配置持续重爬
+	if *recrawl {
+		crawler.SetRecrawlScheduler(core.NewRecrawlScheduler(core.RecrawlOptions{
+			MinInterval: *recrawlMin,
+			MaxInterval: *recrawlMax,
+		}))
+
+		// 收到中断信号时停止爬虫，保存已有结果
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		go func() {
+			<-signals
+			fmt.Println("\n收到中断信号，正在停止...")
+			crawler.Stop()
+		}()
+	}
+
+	// 添加起始URL，指定了请求方法或请求体时按指定的方式请求
+	if strings.EqualFold(*startMethod, "GET") && *startBody == "" {
+		crawler.AddURL(*startURL)
+	} else {
+		crawler.AddRequest(startRequest())
+	}
 
 	// 打印爬虫配置信息
 	fmt.Println("=== Go爬虫启动 ===")
@@ -65,8 +495,12 @@ func main() {
 	fmt.Println("爬虫开始运行...")
 	startTime := time.Now()
 
-	err := crawler.Start()
-	if err != nil {
+	err = crawler.Start()
+	if errors.Is(err, core.ErrMaxPagesReached) || errors.Is(err, core.ErrMaxBytesReached) {
+		fmt.Printf("爬取因预算耗尽而结束: %v\n", err)
+	} else if *recrawl && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		fmt.Println("持续重爬已停止")
+	} else if err != nil {
 		log.Fatalf("爬虫运行出错: %v", err)
 	}
 
@@ -81,6 +515,96 @@ func main() {
 	fmt.Printf("成功页面数: %d\n", stats.PagesSucceeded)
 	fmt.Printf("失败页面数: %d\n", stats.PagesFailed)
 	fmt.Printf("发现的URL数: %d\n", stats.URLsFound)
+	fmt.Printf("下载字节数: %d\n", stats.BytesFetched)
+	if validatorStore != nil {
+		fmt.Printf("未变化页面数: %d，节省字节数: %d\n", stats.PagesNotModified, stats.BytesSaved)
+	}
+	if *assetDir != "" {
+		fmt.Printf("下载资源数: %d，失败: %d，资源字节数: %d\n",
+			stats.AssetsDownloaded, stats.AssetsFailed, stats.AssetBytes)
+	}
+	if *nearDup {
+		fmt.Printf("近似重复页面数: %d\n", stats.NearDuplicates)
+	}
+	if proxyPool != nil {
+		fmt.Println("代理统计:")
+		for _, ps := range proxyPool.Stats() {
+			fmt.Printf("  %s 请求: %d 成功: %d 失败: %d 剔除: %d 可用: %v\n",
+				ps.Proxy, ps.Requests, ps.Successes, ps.Failures, ps.Ejections, ps.Available)
+		}
+	}
+	if cacheFetcher != nil {
+		cs := cacheFetcher.Stats()
+		fmt.Printf("缓存命中: %d 未命中: %d 过期: %d 淘汰: %d 条目: %d 大小: %d字节\n",
+			cs.Hits, cs.Misses, cs.Stale, cs.Evictions, cs.Entries, cs.Size)
+	}
+	if stats.URLsDisallowed > 0 {
+		fmt.Printf("robots.txt禁止抓取的URL数: %d\n", stats.URLsDisallowed)
+	}
+	if stats.URLsOverBudget > 0 {
+		fmt.Printf("因预算跳过的URL数: %d\n", stats.URLsOverBudget)
+	}
+
+	// 打印陷阱拦截统计
+	if report := crawler.GetTrapReport(); len(report) > 0 {
+		fmt.Printf("陷阱拦截URL数: %d\n", stats.URLsSuppressed)
+		for host, reasons := range report {
+			for reason, n := range reasons {
+				fmt.Printf("  %s [%s]: %d\n", host, reason, n)
+			}
+		}
+	}
+
+	// 改写镜像中的链接
+	if mirror != nil {
+		n, err := mirror.ConvertLinks()
+		if err != nil {
+			log.Printf("改写镜像链接出错: %v", err)
+		}
+		fmt.Printf("镜像已保存到 %s，改写了 %d 个文件的链接\n", *mirrorDir, n)
+	}
+
+	// 关闭WARC文件
+	if warcWriter != nil {
+		if err := warcWriter.Close(); err != nil {
+			log.Printf("关闭WARC文件出错: %v", err)
+		}
+	}
+
+	// 保存未抓取的URL
+	if frontier != "" {
+		if err := crawler.SaveFrontier(frontier); err != nil {
+			log.Printf("保存未抓取URL出错: %v", err)
+		}
+	}
+
+	// 保存布隆过滤器
+	if bloomChecker != nil && *bloomFile != "" {
+		if err := bloomChecker.SaveToFile(*bloomFile); err != nil {
+			log.Printf("保存布隆过滤器出错: %v", err)
+		}
+	}
+
+	// 保存Cookie
+	if cookieJar != nil && *cookieFile != "" {
+		if err := cookieJar.SaveToFile(*cookieFile); err != nil {
+			log.Printf("保存Cookie出错: %v", err)
+		}
+	}
+
+	// 保存校验信息
+	if validatorStore != nil {
+		if err := validatorStore.SaveToFile(*validators); err != nil {
+			log.Printf("保存校验信息出错: %v", err)
+		}
+	}
+
+	// 压缩磁盘去重日志，去掉重复和过期的记录
+	if diskChecker != nil {
+		if err := diskChecker.Compact(); err != nil {
+			log.Printf("压缩去重日志出错: %v", err)
+		}
+	}
 
 	// 保存结果
 	storage := crawler.GetStorage()
@@ -93,3 +617,139 @@ func main() {
 		}
 	}
 }
+
+// parsePrefixCaps 解析形如 "example.com/docs=100,example.com/blog=20" 的路径前缀上限
+func parsePrefixCaps(value string) (map[string]int, error) {
+	caps := make(map[string]int)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, limit, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的前缀上限 %q", item)
+		}
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, fmt.Errorf("无效的前缀上限 %q: %w", item, err)
+		}
+		caps[prefix] = n
+	}
+	return caps, nil
+}
+
+// newParser 创建解析器，指定了提取规则时在默认解析器之上按规则提取，指定了JSON规则时再由JSON解析器处理JSON响应
+func newParser() (core.Parser, error) {
+	var parser core.Parser = core.NewDefaultParser()
+	if *rulesFile != "" {
+		rules, err := plugins.LoadRulesParser(parser, *rulesFile)
+		if err != nil {
+			return nil, err
+		}
+		parser = rules
+	}
+	if *jsonRules != "" {
+		return plugins.LoadJSONParser(parser, *jsonRules)
+	}
+	return parser, nil
+}
+
+// startRequest 按 -method 和 -body 创建起始请求
+func startRequest() *core.URL {
+	metadata := map[string]interface{}{core.MetadataMethod: strings.ToUpper(*startMethod)}
+	if *startBody != "" {
+		contentType := "application/x-www-form-urlencoded"
+		if json.Valid([]byte(*startBody)) {
+			contentType = "application/json"
+		}
+		metadata[core.MetadataBody] = *startBody
+		metadata[core.MetadataHeaders] = map[string]string{"Content-Type": contentType}
+	}
+	return &core.URL{Address: *startURL, Metadata: metadata}
+}
+
+// reparsePages 用当前的解析器重新解析存储的原始页面，更新输出文件中对应URL的结果
+func reparsePages(pagesDir, outputFile string) error {
+	store, err := core.NewPageStore(pagesDir)
+	if err != nil {
+		return err
+	}
+
+	storage := core.NewMemoryStorage()
+	if _, err := os.Stat(outputFile); err == nil {
+		if err := storage.LoadFromFile(outputFile); err != nil {
+			return err
+		}
+	}
+
+	parser, err := newParser()
+	if err != nil {
+		return err
+	}
+	count, err := core.Reparse(store, parser, storage)
+	if err != nil {
+		return err
+	}
+	if err := storage.SaveToFile(outputFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("已用解析器 %s 重新解析 %d 个页面，结果已保存到 %s\n",
+		core.ParserVersion(parser), count, outputFile)
+	return nil
+}
+
+// compareCrawls 比较两次爬取的输出文件，打印新增、删除、变化和未变化的URL
+func compareCrawls(oldFile, newFile, reportFile string) error {
+	oldStorage := core.NewMemoryStorage()
+	if err := oldStorage.LoadFromFile(oldFile); err != nil {
+		return err
+	}
+	newStorage := core.NewMemoryStorage()
+	if err := newStorage.LoadFromFile(newFile); err != nil {
+		return err
+	}
+
+	diff := core.CompareCrawls(oldStorage.GetAll(), newStorage.GetAll())
+
+	fmt.Println("=== 爬取结果比较 ===")
+	fmt.Printf("新增: %d\n", len(diff.New))
+	fmt.Printf("删除: %d\n", len(diff.Removed))
+	fmt.Printf("变化: %d\n", len(diff.Changed))
+	fmt.Printf("未变化: %d\n", len(diff.Unchanged))
+
+	for _, url := range diff.New {
+		fmt.Printf("+ %s\n", url)
+	}
+	for _, url := range diff.Removed {
+		fmt.Printf("- %s\n", url)
+	}
+	for _, change := range diff.Changed {
+		fmt.Printf("~ %s\n", change.URL)
+		for _, field := range change.Fields {
+			fmt.Printf("  [%s#%d] %s:\n", field.Type, field.Index, field.Field)
+			for _, line := range field.Lines {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+
+	if reportFile == "" {
+		return nil
+	}
+
+	file, err := os.Create(reportFile)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(diff); err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+	fmt.Printf("比较报告已保存到 %s\n", reportFile)
+	return nil
+}
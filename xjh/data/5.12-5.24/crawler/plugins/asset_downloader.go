@@ -0,0 +1,297 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// DefaultAssetSuffixes 默认下载的资源后缀
+var DefaultAssetSuffixes = []string{
+	".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".pdf", ".zip", ".tar.gz",
+	".gz", ".mp3", ".mp4", ".avi", ".mov",
+}
+
+// ErrAssetTooLarge 资源超过大小上限
+var ErrAssetTooLarge = errors.New("资源超过大小上限")
+
+// AssetOptions 资源下载配置
+type AssetOptions struct {
+	// 下载目录
+	Dir string
+
+	// 下载并发数，默认2
+	Workers int
+
+	// 单个资源的大小上限（字节），0表示不限制
+	MaxSize int64
+
+	// 下载的URL后缀，为空时使用 DefaultAssetSuffixes
+	Suffixes []string
+}
+
+// assetJob 一个下载任务
+type assetJob struct {
+	ctx    context.Context
+	url    string
+	parent string
+	done   func(*core.Asset, error)
+}
+
+// AssetPool 资源下载池，实现 core.AssetDownloader。
+// 资源以流的方式写入磁盘，未完成的下载保留在 partial 目录，下次用Range请求续传；
+// 下载完成的文件按内容的SHA-256命名，内容相同的资源只保存一份
+type AssetPool struct {
+	fetcher *core.HTTPFetcher
+	options AssetOptions
+
+	jobs    chan *assetJob
+	pending sync.WaitGroup
+
+	// 串行化文件的最终命名，保证按内容去重
+	mu sync.Mutex
+}
+
+// NewAssetPool 创建资源下载池，请求使用 fetcher 的请求头、认证、代理和Cookie
+func NewAssetPool(fetcher *core.HTTPFetcher, options AssetOptions) (*AssetPool, error) {
+	if options.Dir == "" {
+		return nil, fmt.Errorf("下载目录不能为空")
+	}
+	if options.Workers <= 0 {
+		options.Workers = 2
+	}
+	if len(options.Suffixes) == 0 {
+		options.Suffixes = DefaultAssetSuffixes
+	}
+	if err := os.MkdirAll(filepath.Join(options.Dir, "partial"), 0755); err != nil {
+		return nil, fmt.Errorf("创建下载目录失败: %w", err)
+	}
+
+	p := &AssetPool{
+		fetcher: fetcher,
+		options: options,
+		jobs:    make(chan *assetJob, 100),
+	}
+	for i := 0; i < options.Workers; i++ {
+		go p.worker()
+	}
+	return p, nil
+}
+
+// Matches 实现 core.AssetDownloader 接口，按URL路径的后缀匹配
+func (p *AssetPool) Matches(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	urlPath := strings.ToLower(u.Path)
+	for _, suffix := range p.options.Suffixes {
+		if strings.HasSuffix(urlPath, strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Download 实现 core.AssetDownloader 接口，下载池繁忙时会阻塞
+func (p *AssetPool) Download(ctx context.Context, rawURL, parent string, done func(*core.Asset, error)) {
+	p.pending.Add(1)
+	p.jobs <- &assetJob{ctx: ctx, url: rawURL, parent: parent, done: done}
+}
+
+// Wait 实现 core.AssetDownloader 接口
+func (p *AssetPool) Wait() {
+	p.pending.Wait()
+}
+
+// worker 处理下载任务
+func (p *AssetPool) worker() {
+	for job := range p.jobs {
+		asset, err := p.download(job.ctx, job.url)
+		if asset != nil {
+			asset.Parent = job.parent
+		}
+		job.done(asset, err)
+		p.pending.Done()
+	}
+}
+
+// download 下载一个资源，存在未完成的下载时续传
+func (p *AssetPool) download(ctx context.Context, rawURL string) (*core.Asset, error) {
+	sum := sha256.Sum256([]byte(rawURL))
+	part := filepath.Join(p.options.Dir, "partial", hex.EncodeToString(sum[:])+".part")
+	validatorFile := part + ".validator"
+
+	// 不接受压缩编码，保证Range的偏移与文件内容一致
+	header := http.Header{"Accept-Encoding": {"identity"}}
+	var offset int64
+	if info, err := os.Stat(part); err == nil && info.Size() > 0 {
+		offset = info.Size()
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// 资源已变化时服务器会返回完整内容
+		if validator, err := os.ReadFile(validatorFile); err == nil && len(validator) > 0 {
+			header.Set("If-Range", string(validator))
+		}
+	}
+
+	resp, err := p.fetcher.Open(ctx, rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp) == offset:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// 之前已经下载完整
+		return p.finish(rawURL, part, resp.Header.Get("Content-Type"))
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+		validator := resp.Header.Get("ETag")
+		if validator == "" {
+			validator = resp.Header.Get("Last-Modified")
+		}
+		// 校验信息只用于续传时确认资源未变化，写入失败不影响本次下载
+		if err := os.WriteFile(validatorFile, []byte(validator), 0644); err != nil {
+			fmt.Printf("保存资源校验信息失败 %s: %v\n", rawURL, err)
+		}
+	case resp.StatusCode == http.StatusPartialContent:
+		// 返回的范围与已下载的部分不衔接，下次重新下载
+		p.discard(part)
+		return nil, fmt.Errorf("续传范围不匹配: %s", resp.Header.Get("Content-Range"))
+	default:
+		return nil, fmt.Errorf("HTTP状态码异常: %d", resp.StatusCode)
+	}
+
+	limit := p.options.MaxSize
+	if limit > 0 && resp.ContentLength >= 0 && offset+resp.ContentLength > limit {
+		p.discard(part)
+		return nil, fmt.Errorf("%w: %d 字节", ErrAssetTooLarge, offset+resp.ContentLength)
+	}
+
+	file, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %w", err)
+	}
+
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit-offset+1)
+	}
+	n, err := io.Copy(file, body)
+	file.Close()
+	if limit > 0 && offset+n > limit {
+		p.discard(part)
+		return nil, fmt.Errorf("%w: 超过 %d 字节", ErrAssetTooLarge, limit)
+	}
+	if err != nil {
+		// 保留已下载的部分，下次续传
+		return nil, fmt.Errorf("下载中断，已下载 %d 字节: %w", offset+n, err)
+	}
+
+	return p.finish(rawURL, part, resp.Header.Get("Content-Type"))
+}
+
+// finish 计算下载完成的文件的校验和，按内容哈希移动到最终位置
+func (p *AssetPool) finish(rawURL, part, contentType string) (*core.Asset, error) {
+	file, err := os.Open(part)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	var head [512]byte
+	headLen, _ := io.ReadFull(file, head[:])
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	ext := assetExt(rawURL)
+	if contentType == "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(head[:headLen])
+	}
+
+	final := filepath.Join(p.options.Dir, checksum[:2], checksum+ext)
+	asset := &core.Asset{
+		URL:    rawURL,
+		Path:   final,
+		MIME:   contentType,
+		Size:   size,
+		SHA256: checksum,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := os.Stat(final); err == nil {
+		asset.Duplicate = true
+		p.discard(part)
+		return asset, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(final), 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.Rename(part, final); err != nil {
+		return nil, fmt.Errorf("移动文件失败: %w", err)
+	}
+	os.Remove(part + ".validator")
+	return asset, nil
+}
+
+// discard 删除未完成的下载
+func (p *AssetPool) discard(part string) {
+	os.Remove(part)
+	os.Remove(part + ".validator")
+}
+
+// contentRangeStart 返回 Content-Range 头中的起始偏移，无法解析时返回-1
+func contentRangeStart(resp *http.Response) int64 {
+	value := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	start, _, ok := strings.Cut(value, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// assetExt 返回URL路径的扩展名，过长或含有异常字符时返回空
+func assetExt(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if len(ext) > 10 || strings.ContainsAny(ext, `/\:*?"<>| `) {
+		return ""
+	}
+	return ext
+}
@@ -0,0 +1,454 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// ErrNotCached 离线模式下请求的URL不在缓存中
+var ErrNotCached = errors.New("离线模式: 缓存中没有该URL")
+
+// CacheOptions 响应缓存配置
+type CacheOptions struct {
+	// 缓存目录
+	Dir string
+
+	// 缓存总大小上限（字节），超出时淘汰最久未访问的条目，0表示不限制
+	MaxSize int64
+
+	// 离线模式：只从缓存读取，忽略新鲜度，缓存未命中时返回 ErrNotCached
+	Offline bool
+
+	// 响应没有任何新鲜度信息时的默认有效期，0表示这类响应总是过期
+	DefaultTTL time.Duration
+}
+
+// CacheStats 缓存统计信息
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Stale     int64
+	Evictions int64
+	Entries   int
+	Size      int64
+}
+
+// cacheEntry 一个缓存的响应
+type cacheEntry struct {
+	URL        string
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+	Title      string
+	Charset    string
+	StoredAt   time.Time
+}
+
+// cacheIndexEntry 内存中的缓存索引项
+type cacheIndexEntry struct {
+	size       int64
+	lastAccess time.Time
+}
+
+// CacheFetcher 是带有磁盘响应缓存的Fetcher装饰器。
+// 响应按规范化URL的SHA-256存放在缓存目录中，按 RFC 7234 的规则判断是否新鲜，
+// 新鲜的响应直接返回，不再访问网站
+type CacheFetcher struct {
+	fetcher core.Fetcher
+	options CacheOptions
+
+	index map[string]*cacheIndexEntry
+	size  int64
+	stats CacheStats
+	mu    sync.Mutex
+}
+
+// NewCacheFetcher 创建缓存抓取器，并扫描缓存目录重建索引
+func NewCacheFetcher(fetcher core.Fetcher, options CacheOptions) (*CacheFetcher, error) {
+	if options.Dir == "" {
+		return nil, fmt.Errorf("缓存目录不能为空")
+	}
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	f := &CacheFetcher{
+		fetcher: fetcher,
+		options: options,
+		index:   make(map[string]*cacheIndexEntry),
+	}
+
+	err := filepath.WalkDir(options.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".cache" {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSuffix(filepath.Base(path), ".cache")
+		f.index[key] = &cacheIndexEntry{size: info.Size(), lastAccess: info.ModTime()}
+		f.size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("扫描缓存目录失败: %w", err)
+	}
+
+	return f, nil
+}
+
+// Fetch 实现Fetcher接口，优先返回新鲜的缓存响应。POST等非GET请求不使用缓存，
+// 离线模式下不会发出这类请求，直接返回 ErrNotCached
+func (f *CacheFetcher) Fetch(ctx context.Context, rawURL string) (*core.Page, error) {
+	if !core.RequestFromContext(ctx).IsGet() {
+		if f.options.Offline {
+			return nil, fmt.Errorf("%w: %s %s", ErrNotCached, core.RequestFromContext(ctx).Method, rawURL)
+		}
+		return f.fetcher.Fetch(ctx, rawURL)
+	}
+
+	key := cacheKey(rawURL, f.variant(rawURL))
+
+	entry, err := f.load(key)
+	if err == nil && (f.options.Offline || f.fresh(entry, time.Now())) {
+		f.mu.Lock()
+		f.stats.Hits++
+		f.mu.Unlock()
+		return entry.page(rawURL), nil
+	}
+
+	f.mu.Lock()
+	if entry != nil {
+		f.stats.Stale++
+	} else {
+		f.stats.Misses++
+	}
+	f.mu.Unlock()
+
+	if f.options.Offline {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, rawURL)
+	}
+
+	page, err := f.fetcher.Fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// 304响应没有内容，不能替换已缓存的响应
+	if page.StatusCode != http.StatusOK || !storable(page.Headers) {
+		return page, nil
+	}
+
+	if err := f.store(key, &cacheEntry{
+		URL:        rawURL,
+		StatusCode: page.StatusCode,
+		Headers:    cacheableHeaders(page.Headers),
+		Body:       page.Content,
+		Title:      page.Title,
+		Charset:    page.Charset,
+		StoredAt:   time.Now(),
+	}); err != nil {
+		fmt.Printf("写入缓存失败 %s: %v\n", rawURL, err)
+	}
+
+	return page, nil
+}
+
+// variant 返回被包装的 HTTPFetcher 发往该URL的请求头和认证身份，没有 HTTPFetcher 时为空。
+// 每次抓取时查找，启用缓存之后添加的认证方式和请求头配置同样生效
+func (f *CacheFetcher) variant(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	for fetcher := f.fetcher; fetcher != nil; {
+		if httpFetcher, ok := fetcher.(*core.HTTPFetcher); ok {
+			return httpFetcher.RequestVariant(u.Host)
+		}
+		wrapper, ok := fetcher.(core.FetcherWrapper)
+		if !ok {
+			break
+		}
+		fetcher = wrapper.Unwrap()
+	}
+	return ""
+}
+
+// Unwrap 返回被包装的抓取器
+func (f *CacheFetcher) Unwrap() core.Fetcher {
+	return f.fetcher
+}
+
+// Stats 返回缓存统计信息
+func (f *CacheFetcher) Stats() CacheStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := f.stats
+	stats.Entries = len(f.index)
+	stats.Size = f.size
+	return stats
+}
+
+// path 返回缓存条目的文件路径，按哈希前两位分目录
+func (f *CacheFetcher) path(key string) string {
+	return filepath.Join(f.options.Dir, key[:2], key+".cache")
+}
+
+// load 读取缓存条目，并更新访问时间
+func (f *CacheFetcher) load(key string) (*cacheEntry, error) {
+	path := f.path(key)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(file).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("解码缓存失败: %w", err)
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	f.mu.Lock()
+	if idx, ok := f.index[key]; ok {
+		idx.lastAccess = now
+	}
+	f.mu.Unlock()
+
+	return &entry, nil
+}
+
+// store 写入缓存条目，必要时淘汰旧条目
+func (f *CacheFetcher) store(key string, entry *cacheEntry) error {
+	path := f.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免并发读到不完整的条目
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	if err := gob.NewEncoder(tmp).Encode(entry); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("编码缓存失败: %w", err)
+	}
+	info, err := tmp.Stat()
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if old, ok := f.index[key]; ok {
+		f.size -= old.size
+	}
+	f.index[key] = &cacheIndexEntry{size: info.Size(), lastAccess: time.Now()}
+	f.size += info.Size()
+	f.evict(key)
+
+	return nil
+}
+
+// evict 淘汰最久未访问的条目直到总大小不超过上限，keep 为刚写入的条目，调用方需持有锁
+func (f *CacheFetcher) evict(keep string) {
+	if f.options.MaxSize <= 0 || f.size <= f.options.MaxSize {
+		return
+	}
+
+	keys := make([]string, 0, len(f.index))
+	for k := range f.index {
+		if k != keep {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return f.index[keys[i]].lastAccess.Before(f.index[keys[j]].lastAccess)
+	})
+
+	for _, k := range keys {
+		if f.size <= f.options.MaxSize {
+			return
+		}
+		if err := os.Remove(f.path(k)); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		f.size -= f.index[k].size
+		delete(f.index, k)
+		f.stats.Evictions++
+	}
+}
+
+// fresh 按 RFC 7234 判断缓存的响应是否仍然新鲜
+func (f *CacheFetcher) fresh(entry *cacheEntry, now time.Time) bool {
+	directives := cacheControl(entry.Headers["Cache-Control"])
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+
+	// 当前年龄 = 存储后经过的时间 + 响应中的Age
+	age := now.Sub(entry.StoredAt)
+	if v, err := strconv.Atoi(entry.Headers["Age"]); err == nil && v > 0 {
+		age += time.Duration(v) * time.Second
+	}
+
+	return age < f.lifetime(entry, directives)
+}
+
+// lifetime 计算响应的新鲜期：s-maxage、max-age、Expires，
+// 最后按 Last-Modified 启发式取距离上次修改时间的10%
+func (f *CacheFetcher) lifetime(entry *cacheEntry, directives map[string]string) time.Duration {
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[name]; ok {
+			if seconds, err := strconv.Atoi(v); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	date := entry.StoredAt
+	if d, err := http.ParseTime(entry.Headers["Date"]); err == nil {
+		date = d
+	}
+
+	if expires, ok := entry.Headers["Expires"]; ok {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			// 无效的Expires表示已过期
+			return 0
+		}
+		return t.Sub(date)
+	}
+
+	if lm, err := http.ParseTime(entry.Headers["Last-Modified"]); err == nil && lm.Before(date) {
+		return date.Sub(lm) / 10
+	}
+
+	return f.options.DefaultTTL
+}
+
+// page 把缓存条目还原为页面
+func (e *cacheEntry) page(rawURL string) *core.Page {
+	return &core.Page{
+		URL:        rawURL,
+		Title:      e.Title,
+		Content:    e.Body,
+		StatusCode: e.StatusCode,
+		Headers:    cacheableHeaders(e.Headers),
+		Charset:    e.Charset,
+		Timestamp:  e.StoredAt.Unix(),
+	}
+}
+
+// hopByHopHeaders 只对单个连接有效、不应随响应缓存的头，以及会重放旧会话的 Set-Cookie
+var hopByHopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Set-Cookie", "Set-Cookie2",
+}
+
+// cacheableHeaders 返回去掉逐跳头、Connection 中列出的头和 Set-Cookie 的响应头副本
+func cacheableHeaders(headers map[string]string) map[string]string {
+	drop := make(map[string]bool, len(hopByHopHeaders))
+	for _, name := range hopByHopHeaders {
+		drop[name] = true
+	}
+	for k, v := range headers {
+		if http.CanonicalHeaderKey(k) != "Connection" {
+			continue
+		}
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				drop[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+
+	kept := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if !drop[http.CanonicalHeaderKey(k)] {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// storable 检查响应是否允许缓存
+func storable(headers map[string]string) bool {
+	directives := cacheControl(headers["Cache-Control"])
+	_, noStore := directives["no-store"]
+	return !noStore
+}
+
+// cacheControl 解析 Cache-Control 头
+func cacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+	}
+	return directives
+}
+
+// cacheKey 返回规范化URL和请求变体的SHA-256，同一URL在不同请求头或登录身份下分别缓存
+func cacheKey(rawURL, variant string) string {
+	sum := sha256.Sum256([]byte(CanonicalURL(rawURL) + "\n" + variant))
+	return hex.EncodeToString(sum[:])
+}
+
+// CanonicalURL 规范化URL：协议和主机转为小写，去掉默认端口和片段，查询参数按键排序
+func CanonicalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(host, ":80")) ||
+		(u.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	u.Host = host
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		// Values.Encode 会按键排序
+		u.RawQuery = u.Query().Encode()
+	}
+
+	return u.String()
+}
@@ -0,0 +1,110 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+func TestCacheFetcherVariesByHeadersAndIdentity(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		user, _, _ := r.BasicAuth()
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Set-Cookie", "session="+user)
+		w.Write([]byte(r.Header.Get("Accept-Language") + " " + user))
+	}))
+	defer server.Close()
+
+	fetcher := core.NewHTTPFetcher(5 * time.Second)
+	cache, err := NewCacheFetcher(fetcher, CacheOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("创建缓存失败: %v", err)
+	}
+
+	fetch := func(want string, wantRequests int64) {
+		t.Helper()
+		page, err := cache.Fetch(context.Background(), server.URL+"/page")
+		if err != nil {
+			t.Fatalf("抓取失败: %v", err)
+		}
+		if string(page.Content) != want {
+			t.Errorf("内容为 %q，期望 %q", page.Content, want)
+		}
+		if got := atomic.LoadInt64(&requests); got != wantRequests {
+			t.Errorf("服务器收到 %d 个请求，期望 %d", got, wantRequests)
+		}
+	}
+
+	fetcher.SetHeaders(map[string]string{"Accept-Language": "en"})
+	fetch("en ", 1)
+	fetch("en ", 1)
+
+	// 请求头变化后不使用其他请求头得到的响应，换回来后仍然命中
+	fetcher.SetHeaders(map[string]string{"Accept-Language": "de"})
+	fetch("de ", 2)
+	fetcher.SetHeaders(map[string]string{"Accept-Language": "en"})
+	fetch("en ", 2)
+
+	// 登录身份不同的响应分别缓存
+	u, _ := url.Parse(server.URL)
+	fetcher.AddAuthenticator(core.NewBasicAuth([]string{u.Host}, "alice", "secret"))
+	fetch("en alice", 3)
+	fetch("en alice", 3)
+
+	// 缓存的响应不带 Set-Cookie
+	page, err := cache.Fetch(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("抓取失败: %v", err)
+	}
+	if cookie, ok := page.Headers["Set-Cookie"]; ok {
+		t.Errorf("缓存的响应带有 Set-Cookie: %s", cookie)
+	}
+	if page.Headers["Cache-Control"] != "max-age=3600" {
+		t.Errorf("缓存的响应头为 %v，期望保留 Cache-Control", page.Headers)
+	}
+}
+
+func TestCacheFetcherOfflineRejectsNonGet(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+	}))
+	defer server.Close()
+
+	cache, err := NewCacheFetcher(core.NewHTTPFetcher(5*time.Second), CacheOptions{Dir: t.TempDir(), Offline: true})
+	if err != nil {
+		t.Fatalf("创建缓存失败: %v", err)
+	}
+
+	if _, err := cache.Fetch(postContext(`{"page":2}`), server.URL+"/api"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("离线模式下的POST请求返回 %v，期望 ErrNotCached", err)
+	}
+	if got := atomic.LoadInt64(&requests); got != 0 {
+		t.Errorf("离线模式下服务器收到 %d 个请求", got)
+	}
+}
+
+func TestCacheableHeaders(t *testing.T) {
+	headers := cacheableHeaders(map[string]string{
+		"Content-Type":      "text/html",
+		"Connection":        "keep-alive, X-Hop",
+		"Keep-Alive":        "timeout=5",
+		"Transfer-Encoding": "chunked",
+		"X-Hop":             "1",
+		"Set-Cookie":        "session=abc",
+		"Etag":              `"v1"`,
+	})
+	if len(headers) != 2 || headers["Content-Type"] != "text/html" || headers["Etag"] != `"v1"` {
+		t.Errorf("保留的响应头为 %v，期望只有 Content-Type 和 Etag", headers)
+	}
+}
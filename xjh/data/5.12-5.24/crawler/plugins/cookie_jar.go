@@ -0,0 +1,338 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieRecord 是一个可持久化的Cookie
+type CookieRecord struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only"`
+	Secure   bool      `json:"secure"`
+	HTTPOnly bool      `json:"http_only"`
+	Expires  time.Time `json:"expires,omitempty"`
+}
+
+// key 返回Cookie的唯一标识
+func (r *CookieRecord) key() string {
+	return r.Domain + ";" + r.Path + ";" + r.Name
+}
+
+// expired 检查Cookie是否已过期，会话Cookie（没有过期时间）永不过期
+func (r *CookieRecord) expired(now time.Time) bool {
+	return !r.Expires.IsZero() && !r.Expires.After(now)
+}
+
+// CookieJar 是一个按爬取任务隔离的Cookie管理器。
+// Cookie的匹配由标准库 cookiejar 按公共后缀规则完成，
+// 同时在内存中保留一份记录，以便保存为 Netscape cookies.txt 或 JSON 文件
+type CookieJar struct {
+	// 爬取任务名称，不同任务的Cookie互不共享
+	job string
+
+	psl     *PublicSuffixList
+	jar     *cookiejar.Jar
+	records map[string]*CookieRecord
+	mu      sync.Mutex
+}
+
+// NewCookieJar 为指定的爬取任务创建Cookie管理器，psl为nil时使用内置的公共后缀列表
+func NewCookieJar(job string, psl *PublicSuffixList) *CookieJar {
+	if psl == nil {
+		psl = NewPublicSuffixList()
+	}
+
+	c := &CookieJar{job: job, psl: psl}
+	c.reset()
+	return c
+}
+
+// reset 重建底层的 cookiejar，调用方需持有锁或处于初始化阶段
+func (c *CookieJar) reset() {
+	// cookiejar.New 只在选项无效时返回错误，这里不会发生
+	c.jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: c.psl})
+	c.records = make(map[string]*CookieRecord)
+}
+
+// Job 返回Cookie管理器所属的爬取任务
+func (c *CookieJar) Job() string {
+	return c.job
+}
+
+// SetCookies 实现 http.CookieJar 接口
+func (c *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		record := newCookieRecord(u, cookie, now)
+
+		// 只保留 cookiejar 实际接受的Cookie，例如为公共后缀设置的Cookie会被拒绝
+		if record.expired(now) || !c.accepted(record) {
+			delete(c.records, record.key())
+			continue
+		}
+		c.records[record.key()] = record
+	}
+}
+
+// Cookies 实现 http.CookieJar 接口
+func (c *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.jar.Cookies(u)
+}
+
+// AddCookie 预置一个Cookie，例如从命令行传入的会话Cookie。
+// domain 以 "." 开头时对所有子域名生效，否则只对该主机生效
+func (c *CookieJar) AddCookie(domain, name, value string) {
+	record := &CookieRecord{
+		Name:     name,
+		Value:    value,
+		Domain:   strings.TrimPrefix(domain, "."),
+		Path:     "/",
+		HostOnly: !strings.HasPrefix(domain, "."),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.restore(record)
+}
+
+// All 返回所有未过期的Cookie
+func (c *CookieJar) All() []CookieRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	records := make([]CookieRecord, 0, len(c.records))
+	for key, record := range c.records {
+		if record.expired(now) {
+			delete(c.records, key)
+			continue
+		}
+		records = append(records, *record)
+	}
+	return records
+}
+
+// Clear 清空所有Cookie
+func (c *CookieJar) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reset()
+}
+
+// SaveToFile 将Cookie保存到文件，扩展名为 .json 时使用JSON格式，否则使用 Netscape cookies.txt 格式
+func (c *CookieJar) SaveToFile(filename string) error {
+	records := c.All()
+
+	// 创建文件
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(strings.ToLower(filename), ".json") {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(records); err != nil {
+			return fmt.Errorf("编码数据失败: %w", err)
+		}
+		return nil
+	}
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "# Netscape HTTP Cookie File\n# 爬取任务: %s\n\n", c.job)
+	for _, r := range records {
+		domain, includeSubdomains := r.Domain, "FALSE"
+		if !r.HostOnly {
+			domain, includeSubdomains = "."+r.Domain, "TRUE"
+		}
+		if r.HTTPOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !r.Expires.IsZero() {
+			expires = r.Expires.Unix()
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, r.Path, strings.ToUpper(strconv.FormatBool(r.Secure)),
+			expires, r.Name, r.Value)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFromFile 从 Netscape cookies.txt 或 JSON 文件加载Cookie，已过期的Cookie会被忽略
+func (c *CookieJar) LoadFromFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	var records []*CookieRecord
+	if strings.HasSuffix(strings.ToLower(filename), ".json") {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("解码数据失败: %w", err)
+		}
+	} else {
+		records, err = parseNetscapeCookies(string(data))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, record := range records {
+		if !record.expired(now) {
+			c.restore(record)
+		}
+	}
+
+	return nil
+}
+
+// restore 把记录写回底层 cookiejar，调用方需持有锁
+func (c *CookieJar) restore(record *CookieRecord) {
+	scheme := "http"
+	if record.Secure {
+		scheme = "https"
+	}
+	u := &url.URL{Scheme: scheme, Host: record.Domain, Path: record.Path}
+
+	cookie := &http.Cookie{
+		Name:     record.Name,
+		Value:    record.Value,
+		Path:     record.Path,
+		Secure:   record.Secure,
+		HttpOnly: record.HTTPOnly,
+		Expires:  record.Expires,
+	}
+	if !record.HostOnly {
+		cookie.Domain = record.Domain
+	}
+
+	c.jar.SetCookies(u, []*http.Cookie{cookie})
+	if c.accepted(record) {
+		c.records[record.key()] = record
+	}
+}
+
+// accepted 检查记录是否被底层 cookiejar 接受
+func (c *CookieJar) accepted(record *CookieRecord) bool {
+	u := &url.URL{Scheme: "https", Host: record.Domain, Path: record.Path}
+	for _, cookie := range c.jar.Cookies(u) {
+		if cookie.Name == record.Name && cookie.Value == record.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// newCookieRecord 根据响应中的Cookie计算其作用域和过期时间
+func newCookieRecord(u *url.URL, cookie *http.Cookie, now time.Time) *CookieRecord {
+	record := &CookieRecord{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   strings.ToLower(u.Hostname()),
+		Path:     cookie.Path,
+		HostOnly: true,
+		Secure:   cookie.Secure,
+		HTTPOnly: cookie.HttpOnly,
+	}
+
+	if cookie.Domain != "" {
+		record.Domain = strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+		record.HostOnly = false
+	}
+
+	// 没有Path属性时使用请求路径所在的目录
+	if record.Path == "" || !strings.HasPrefix(record.Path, "/") {
+		record.Path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			record.Path = u.Path[:i]
+		}
+	}
+
+	switch {
+	case cookie.MaxAge < 0:
+		record.Expires = now.Add(-time.Second)
+	case cookie.MaxAge > 0:
+		record.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		record.Expires = cookie.Expires
+	}
+
+	return record
+}
+
+// parseNetscapeCookies 解析 Netscape cookies.txt 格式
+func parseNetscapeCookies(data string) ([]*CookieRecord, error) {
+	var records []*CookieRecord
+
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("第%d行格式错误: 需要7个字段，实际%d个", i+1, len(fields))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行过期时间无效: %w", i+1, err)
+		}
+
+		record := &CookieRecord{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.TrimPrefix(fields[0], "."),
+			Path:     fields[2],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+		}
+		if expires > 0 {
+			record.Expires = time.Unix(expires, 0)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
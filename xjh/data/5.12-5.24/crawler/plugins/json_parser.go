@@ -0,0 +1,382 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// JSONRule 一条JSON接口的提取规则：对URL匹配的JSON响应，用JSONPath提取结果并发现下一步要请求的URL
+type JSONRule struct {
+	// 结果类型，为空时只发现链接，不生成结果
+	Name string `json:"name"`
+
+	// URL正则表达式，为空时匹配所有JSON响应
+	URL string `json:"url"`
+
+	// 每个选中的值生成一个结果，为空时整个响应生成一个结果
+	Path string `json:"path"`
+
+	// 字段名到JSONPath的映射，$ 为结果对应的值。只选中一个值的路径（如 $.id）得到该值，
+	// 其他路径（含通配符、过滤器等）得到列表。为空时结果对应的对象的所有成员都作为字段
+	Fields map[string]string `json:"fields"`
+
+	// 发现下一步请求的方式
+	Links []*JSONLinkRule `json:"links"`
+
+	urlRegex *regexp.Regexp
+	path     *core.JSONPath
+	fields   map[string]*core.JSONPath
+}
+
+// JSONLinkRule 从响应中的值生成请求，如下一页链接、分页游标、按ID拼接的详情接口
+type JSONLinkRule struct {
+	// 选取值的JSONPath，每个非空的字符串、数字或布尔值生成一个请求
+	Path string `json:"path"`
+
+	// URL模板，{value} 替换为转义后的值，如 https://api.example.com/items/{value}；
+	// 为空时值本身就是链接，设置了 param 或 body 时为当前响应的URL。相对链接按响应的URL解析
+	URL string `json:"url"`
+
+	// 把值设置为URL的查询参数，用于游标分页，如 cursor
+	Param string `json:"param"`
+
+	// 请求方法，设置了 body 时默认为POST，否则为GET
+	Method string `json:"method"`
+
+	// 请求体模板，{value} 替换为值的JSON编码，如 {"cursor": {value}}
+	Body string `json:"body"`
+
+	// 附加的请求头，设置了 body 时 Content-Type 默认为 application/json
+	Headers map[string]string `json:"headers"`
+
+	path *core.JSONPath
+}
+
+// JSONParser 用JSONPath从JSON接口的响应中提取结果和下一步请求的解析器。
+// 非JSON的页面交给被包装的解析器处理
+type JSONParser struct {
+	parser core.Parser
+	rules  []*JSONRule
+
+	// 规则内容的哈希，用于解析器版本
+	digest string
+}
+
+// NewJSONParser 编译规则并创建解析器，parser 处理非JSON的页面
+func NewJSONParser(parser core.Parser, rules []*JSONRule) (*JSONParser, error) {
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("第 %d 条规则 %s: %w", i+1, rule.Name, err)
+		}
+	}
+
+	encoded, _ := json.Marshal(rules)
+	sum := sha256.Sum256(encoded)
+
+	return &JSONParser{
+		parser: parser,
+		rules:  rules,
+		digest: hex.EncodeToString(sum[:4]),
+	}, nil
+}
+
+// LoadJSONParser 从JSON文件加载规则并创建解析器，文件内容是 JSONRule 数组
+func LoadJSONParser(parser core.Parser, filename string) (*JSONParser, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	var rules []*JSONRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解码数据失败: %w", err)
+	}
+
+	return NewJSONParser(parser, rules)
+}
+
+// compile 编译规则中的正则表达式和JSONPath
+func (r *JSONRule) compile() error {
+	var err error
+	if r.URL != "" {
+		if r.urlRegex, err = regexp.Compile(r.URL); err != nil {
+			return fmt.Errorf("URL正则表达式无效: %w", err)
+		}
+	}
+	if r.Path != "" {
+		if r.path, err = core.CompileJSONPath(r.Path); err != nil {
+			return err
+		}
+	}
+
+	r.fields = make(map[string]*core.JSONPath, len(r.Fields))
+	for name, source := range r.Fields {
+		path, err := core.CompileJSONPath(source)
+		if err != nil {
+			return fmt.Errorf("字段 %s: %w", name, err)
+		}
+		r.fields[name] = path
+	}
+
+	for _, link := range r.Links {
+		if err := link.compile(); err != nil {
+			return fmt.Errorf("链接 %s: %w", link.Path, err)
+		}
+	}
+	return nil
+}
+
+// compile 检查链接配置并编译JSONPath
+func (l *JSONLinkRule) compile() error {
+	if l.Path == "" {
+		return fmt.Errorf("链接缺少 path")
+	}
+	if l.URL != "" && l.Param == "" && l.Body == "" && !strings.Contains(l.URL, "{value}") {
+		return fmt.Errorf("URL模板中缺少 {value}")
+	}
+
+	var err error
+	l.path, err = core.CompileJSONPath(l.Path)
+	return err
+}
+
+// Version 实现 core.VersionedParser 接口，规则变化时版本随之变化
+func (p *JSONParser) Version() string {
+	return core.ParserVersion(p.parser) + "+json/" + p.digest
+}
+
+// Assets 实现 core.AssetParser 接口，非JSON的页面使用被包装解析器提取的资源链接
+func (p *JSONParser) Assets(page *core.Page) []string {
+	if parser, ok := p.parser.(core.AssetParser); ok && !isJSONPage(page) {
+		return parser.Assets(page)
+	}
+	return nil
+}
+
+// Parse 实现Parser接口，只返回GET请求的链接，需要请求体或请求头的请求被忽略
+func (p *JSONParser) Parse(page *core.Page) ([]core.Result, []string) {
+	results, links, _ := p.ParseRequests(page)
+	return results, links
+}
+
+// ParseRequests 实现 core.RequestParser 接口，需要请求体、非GET方法或请求头的请求作为带元数据的URL返回
+func (p *JSONParser) ParseRequests(page *core.Page) ([]core.Result, []string, []*core.URL) {
+	if !isJSONPage(page) {
+		if parser, ok := p.parser.(core.RequestParser); ok {
+			return parser.ParseRequests(page)
+		}
+		results, links := p.parser.Parse(page)
+		return results, links, nil
+	}
+
+	// 保留数字的原文，避免大整数ID转换为浮点数后失去精度
+	decoder := json.NewDecoder(bytes.NewReader(page.Content))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		fmt.Printf("解析JSON失败 %s: %v\n", page.URL, err)
+		return nil, nil, nil
+	}
+
+	base, err := url.Parse(page.URL)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	var results []core.Result
+	var links []string
+	var requests []*core.URL
+	for _, rule := range p.rules {
+		if rule.urlRegex != nil && !rule.urlRegex.MatchString(page.URL) {
+			continue
+		}
+		if rule.Name != "" {
+			results = append(results, rule.extract(page, doc)...)
+		}
+
+		for _, link := range rule.Links {
+			for _, value := range link.path.Find(doc) {
+				request, err := link.request(base, value)
+				if err != nil {
+					fmt.Printf("生成链接 %s 失败 %s: %v\n", link.Path, page.URL, err)
+					continue
+				}
+				switch {
+				case request == nil:
+				case request.Metadata == nil:
+					links = append(links, request.Address)
+				default:
+					requests = append(requests, request)
+				}
+			}
+		}
+	}
+	return results, links, requests
+}
+
+// extract 对响应应用规则，生成结果
+func (r *JSONRule) extract(page *core.Page, doc interface{}) []core.Result {
+	items := []interface{}{doc}
+	if r.path != nil {
+		items = r.path.Find(doc)
+	}
+
+	results := make([]core.Result, 0, len(items))
+	for _, item := range items {
+		data := make(map[string]interface{})
+		switch {
+		case len(r.fields) > 0:
+			for name, path := range r.fields {
+				values := path.Find(item)
+				switch {
+				case len(values) == 0:
+				case path.Definite():
+					data[name] = values[0]
+				default:
+					data[name] = values
+				}
+			}
+		default:
+			if obj, ok := item.(map[string]interface{}); ok {
+				for k, v := range obj {
+					data[k] = v
+				}
+			} else {
+				data["value"] = item
+			}
+		}
+		// 接口数据自带的url字段保留，页面地址改存为source_url
+		if _, ok := data["url"]; ok {
+			data["source_url"] = page.URL
+		} else {
+			data["url"] = page.URL
+		}
+		results = append(results, core.Result{Type: r.Name, Data: data})
+	}
+	return results
+}
+
+// request 由选取的值生成请求，值为空或不能作为链接时返回nil。
+// 只是普通GET请求时返回的URL没有元数据
+func (l *JSONLinkRule) request(base *url.URL, value interface{}) (*core.URL, error) {
+	text, ok := jsonScalar(value)
+	if !ok || text == "" {
+		return nil, nil
+	}
+
+	// 确定请求的URL
+	var target string
+	switch {
+	case l.URL != "":
+		target = expandURLTemplate(l.URL, text)
+	case l.Param != "" || l.Body != "":
+		target = base.String()
+	default:
+		target = text
+	}
+	ref, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return nil, fmt.Errorf("链接无效: %w", err)
+	}
+	u := base.ResolveReference(ref)
+	u.Fragment = ""
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, nil
+	}
+
+	if l.Param != "" {
+		query := u.Query()
+		query.Set(l.Param, text)
+		u.RawQuery = query.Encode()
+	}
+
+	request := &core.URL{Address: u.String()}
+
+	// 确定请求方式
+	method := strings.ToUpper(l.Method)
+	if method == "" {
+		method = http.MethodGet
+		if l.Body != "" {
+			method = http.MethodPost
+		}
+	}
+	if method == http.MethodGet && l.Body == "" && len(l.Headers) == 0 {
+		return request, nil
+	}
+
+	headers := make(map[string]string, len(l.Headers)+1)
+	for k, v := range l.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	request.Metadata = map[string]interface{}{
+		core.MetadataMethod:  method,
+		core.MetadataHeaders: headers,
+	}
+	if l.Body != "" {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("编码请求体失败: %w", err)
+		}
+		request.Metadata[core.MetadataBody] = strings.ReplaceAll(l.Body, "{value}", string(encoded))
+		if _, ok := headers["Content-Type"]; !ok {
+			headers["Content-Type"] = "application/json"
+		}
+	}
+	return request, nil
+}
+
+// expandURLTemplate 把模板中的 {value} 替换为值，查询字符串中按查询参数转义，路径中按路径段转义
+func expandURLTemplate(template, value string) string {
+	query := strings.IndexByte(template, '?')
+	var b strings.Builder
+	rest := template
+	for {
+		i := strings.Index(rest, "{value}")
+		if i == -1 {
+			b.WriteString(rest)
+			return b.String()
+		}
+		b.WriteString(rest[:i])
+		if query != -1 && len(template)-len(rest)+i > query {
+			b.WriteString(url.QueryEscape(value))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+		rest = rest[i+len("{value}"):]
+	}
+}
+
+// jsonScalar 把字符串、数字和布尔值转换为文本，其他值（null、数组、对象）不能作为链接
+func jsonScalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return fmt.Sprint(v), true
+	case bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// isJSONPage 检查响应是否是JSON，包括 application/json、text/json 和 +json 后缀的媒体类型
+func isJSONPage(page *core.Page) bool {
+	mediaType, _, err := mime.ParseMediaType(page.Headers["Content-Type"])
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,36 @@
+package plugins
+
+import (
+	"testing"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+func TestJSONParserKeepsItemURL(t *testing.T) {
+	parser, err := NewJSONParser(nil, []*JSONRule{{Name: "item", Path: "$.data[*]"}})
+	if err != nil {
+		t.Fatalf("创建JSON解析器失败: %v", err)
+	}
+
+	const api = "http://site.test/api/items"
+	page := &core.Page{
+		URL:     api,
+		Content: []byte(`{"data": [{"id": 1, "url": "http://site.test/items/1"}, {"id": 2}]}`),
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+	results, _ := parser.Parse(page)
+	if len(results) != 2 {
+		t.Fatalf("得到 %d 个结果，期望 2", len(results))
+	}
+
+	// 条目自带的url保留，响应的URL改存为source_url
+	if got := results[0].Data["url"]; got != "http://site.test/items/1" {
+		t.Errorf("第1个结果的url为 %v，期望条目自身的链接", got)
+	}
+	if got := results[0].Data["source_url"]; got != api {
+		t.Errorf("第1个结果的source_url为 %v，期望 %s", got, api)
+	}
+	if got := results[1].Data["url"]; got != api {
+		t.Errorf("第2个结果的url为 %v，期望 %s", got, api)
+	}
+}
@@ -0,0 +1,369 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// maxFilenameLength 文件名的最大长度，超出时截断并加上哈希
+const maxFilenameLength = 200
+
+var (
+	// HTML中的链接属性
+	linkAttrRegex = regexp.MustCompile(`(?i)(\s(?:href|src)\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+
+	// 页面资源：图片、脚本、样式表、图标、媒体
+	requisiteRegex = regexp.MustCompile(`(?i)<(?:img|script|link|source|video|audio|embed)\b[^>]*?\s(?:src|href)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	// CSS中的 url() 引用
+	cssURLRegex = regexp.MustCompile(`(?i)(url\(\s*)(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))(\s*\))`)
+)
+
+// Mirror 把抓取到的页面和资源按URL路径保存到本地目录，类似 wget --mirror。
+// 爬取结束后调用 ConvertLinks，把已保存页面之间的链接改写为本地相对路径
+type Mirror struct {
+	dir string
+
+	// 允许镜像的主机，为空时不限制
+	hosts map[string]bool
+
+	// URL键 -> 本地文件（相对于 dir）
+	saved map[string]string
+
+	// 需要改写链接的文件 -> 页面URL
+	documents map[string]string
+
+	mu sync.Mutex
+}
+
+// NewMirror 创建镜像，hosts 为允许镜像的主机
+func NewMirror(dir string, hosts []string) (*Mirror, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建镜像目录失败: %w", err)
+	}
+
+	m := &Mirror{
+		dir:       dir,
+		hosts:     make(map[string]bool),
+		saved:     make(map[string]string),
+		documents: make(map[string]string),
+	}
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			m.hosts[h] = true
+		}
+	}
+	return m, nil
+}
+
+// InScope 检查URL是否在镜像范围内
+func (m *Mirror) InScope(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return len(m.hosts) == 0 || m.hosts[strings.ToLower(u.Host)]
+}
+
+// Save 把页面保存到镜像目录，返回本地文件路径
+func (m *Mirror) Save(page *core.Page) (string, error) {
+	kind := documentKind(page)
+	rel := MirrorPath(page.URL, kind == "html")
+	file := filepath.Join(m.dir, rel)
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.WriteFile(file, page.Content, 0644); err != nil {
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saved[mirrorKey(page.URL)] = rel
+	if kind != "" {
+		m.documents[rel] = page.URL
+	}
+	return file, nil
+}
+
+// ConvertLinks 改写已保存的HTML和CSS文件中的链接：
+// 指向已镜像URL的链接改为本地相对路径，其他链接改为绝对URL，返回改写的文件数
+func (m *Mirror) ConvertLinks() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for rel, pageURL := range m.documents {
+		file := filepath.Join(m.dir, rel)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return count, fmt.Errorf("读取文件失败: %w", err)
+		}
+
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			continue
+		}
+
+		var converted []byte
+		if strings.HasSuffix(rel, ".css") {
+			converted = cssURLRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+				parts := cssURLRegex.FindSubmatch(match)
+				link := string(parts[2]) + string(parts[3]) + string(parts[4])
+				return []byte(string(parts[1]) + `"` + m.localLink(base, rel, link) + `"` + string(parts[5]))
+			})
+		} else {
+			converted = linkAttrRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+				parts := linkAttrRegex.FindSubmatch(match)
+				link := string(parts[2]) + string(parts[3])
+				return []byte(string(parts[1]) + `"` + m.localLink(base, rel, link) + `"`)
+			})
+		}
+
+		if err := os.WriteFile(file, converted, 0644); err != nil {
+			return count, fmt.Errorf("写入文件失败: %w", err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// localLink 把文件中的链接改写为本地相对路径或绝对URL，调用方需持有锁
+func (m *Mirror) localLink(base *url.URL, fromRel, link string) string {
+	trimmed := strings.TrimSpace(link)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "data:") {
+		return link
+	}
+
+	ref, err := url.Parse(trimmed)
+	if err != nil {
+		return link
+	}
+	target := base.ResolveReference(ref)
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return link
+	}
+
+	fragment := ""
+	if target.Fragment != "" {
+		fragment = "#" + target.EscapedFragment()
+	}
+
+	local, ok := m.saved[mirrorKey(target.String())]
+	if !ok {
+		return target.String()
+	}
+
+	relPath, err := filepath.Rel(filepath.Dir(filepath.FromSlash(fromRel)), filepath.FromSlash(local))
+	if err != nil {
+		return target.String()
+	}
+
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/") + fragment
+}
+
+// MirrorParser 包装一个解析器，把解析的页面保存到镜像，
+// 并在发现的链接中加入页面资源（图片、脚本、样式表等），只保留镜像范围内的链接
+type MirrorParser struct {
+	parser core.Parser
+	mirror *Mirror
+}
+
+// NewMirrorParser 创建镜像解析器
+func NewMirrorParser(parser core.Parser, mirror *Mirror) *MirrorParser {
+	return &MirrorParser{parser: parser, mirror: mirror}
+}
+
+// Version 实现 core.VersionedParser 接口，沿用被包装解析器的版本
+func (p *MirrorParser) Version() string {
+	return core.ParserVersion(p.parser)
+}
+
+// Assets 实现 core.AssetParser 接口，使用被包装解析器提取的资源链接
+func (p *MirrorParser) Assets(page *core.Page) []string {
+	if parser, ok := p.parser.(core.AssetParser); ok {
+		return parser.Assets(page)
+	}
+	return nil
+}
+
+// Parse 实现Parser接口
+func (p *MirrorParser) Parse(page *core.Page) ([]core.Result, []string) {
+	results, links, _ := p.ParseRequests(page)
+	return results, links
+}
+
+// ParseRequests 实现 core.RequestParser 接口，被包装解析器实现了该接口时返回它发现的请求，
+// 与链接一样只保留镜像范围内的请求
+func (p *MirrorParser) ParseRequests(page *core.Page) ([]core.Result, []string, []*core.URL) {
+	if _, err := p.mirror.Save(page); err != nil {
+		fmt.Printf("保存镜像失败 %s: %v\n", page.URL, err)
+	}
+
+	var results []core.Result
+	var links []string
+	var requests []*core.URL
+	if parser, ok := p.parser.(core.RequestParser); ok {
+		results, links, requests = parser.ParseRequests(page)
+	} else {
+		results, links = p.parser.Parse(page)
+	}
+
+	base, err := url.Parse(page.URL)
+	if err != nil {
+		return results, nil, nil
+	}
+
+	// 解析器忽略了资源链接，这里从页面中补充
+	switch documentKind(page) {
+	case "html":
+		for _, match := range requisiteRegex.FindAllSubmatch(page.Content, -1) {
+			links = append(links, resolveLink(base, string(match[1])+string(match[2])))
+		}
+	case "css":
+		for _, match := range cssURLRegex.FindAllSubmatch(page.Content, -1) {
+			links = append(links, resolveLink(base, string(match[2])+string(match[3])+string(match[4])))
+		}
+	}
+
+	seen := make(map[string]bool)
+	scoped := links[:0]
+	for _, link := range links {
+		if link != "" && !seen[link] && p.mirror.InScope(link) {
+			seen[link] = true
+			scoped = append(scoped, link)
+		}
+	}
+
+	scopedRequests := requests[:0]
+	for _, request := range requests {
+		if p.mirror.InScope(request.Address) {
+			scopedRequests = append(scopedRequests, request)
+		}
+	}
+	return results, scoped, scopedRequests
+}
+
+// resolveLink 把链接解析为去掉片段的绝对URL
+func resolveLink(base *url.URL, link string) string {
+	ref, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || strings.HasPrefix(link, "data:") {
+		return ""
+	}
+	u := base.ResolveReference(ref)
+	u.Fragment = ""
+	return u.String()
+}
+
+// documentKind 返回需要改写链接的文档类型："html"、"css"，其他类型返回空
+func documentKind(page *core.Page) string {
+	contentType := strings.ToLower(page.Headers["Content-Type"])
+	switch {
+	case strings.Contains(contentType, "html"):
+		return "html"
+	case strings.Contains(contentType, "css"):
+		return "css"
+	case contentType == "" && page.Title != "":
+		return "html"
+	}
+	return ""
+}
+
+// mirrorKey 返回用于匹配已镜像URL的键：规范化URL，并去掉路径末尾的斜杠
+func mirrorKey(rawURL string) string {
+	key := CanonicalURL(rawURL)
+	if u, err := url.Parse(key); err == nil && len(u.Path) > 1 && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+		key = u.String()
+	}
+	return key
+}
+
+// MirrorPath 把URL映射为镜像目录中的相对路径：主机/路径。
+// 以斜杠结尾的路径和没有扩展名的HTML页面保存为目录下的 index.html；
+// 查询参数以 @ 连接到文件名后，文件名中不安全的字符替换为 _，过长的文件名截断并加上哈希
+func MirrorPath(rawURL string, html bool) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return safeName(rawURL)
+	}
+
+	host := safeName(strings.ToLower(u.Host))
+	dir, name := path.Split(path.Clean("/" + u.Path))
+	if strings.HasSuffix(u.Path, "/") || u.Path == "" {
+		dir, name = path.Clean("/"+u.Path)+"/", ""
+	}
+
+	if name == "" || (html && path.Ext(name) == "" && u.RawQuery == "") {
+		dir, name = path.Join(dir, name)+"/", "index.html"
+	}
+	if u.RawQuery != "" {
+		query, _ := url.QueryUnescape(u.RawQuery)
+		name += "@" + query
+		if html && !strings.HasSuffix(name, ".html") && !strings.HasSuffix(name, ".htm") {
+			name += ".html"
+		}
+	}
+
+	segments := []string{host}
+	for _, s := range strings.Split(dir, "/") {
+		if s != "" {
+			segments = append(segments, safeName(s))
+		}
+	}
+	segments = append(segments, safeName(name))
+
+	return filepath.Join(segments...)
+}
+
+// safeName 替换文件名中不安全的字符，过长时截断并加上哈希
+func safeName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			b.WriteRune('_')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	safe := b.String()
+	if safe == "." || safe == ".." {
+		safe = "_"
+	}
+
+	if len(safe) > maxFilenameLength {
+		sum := sha256.Sum256([]byte(name))
+		ext := path.Ext(safe)
+		if len(ext) > 10 {
+			ext = ""
+		}
+		cut := maxFilenameLength - len(ext) - 17
+		for cut > 0 && !isRuneStart(safe[cut]) {
+			cut--
+		}
+		safe = safe[:cut] + "-" + hex.EncodeToString(sum[:8]) + ext
+	}
+	return safe
+}
+
+// isRuneStart 检查字节是否是UTF-8字符的起始字节
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
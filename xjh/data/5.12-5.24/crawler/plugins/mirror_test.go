@@ -0,0 +1,74 @@
+package plugins
+
+import (
+	"testing"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// requestAssetParser 返回固定的链接、资源和带元数据的请求
+type requestAssetParser struct{}
+
+func (requestAssetParser) Parse(page *core.Page) ([]core.Result, []string) {
+	results, links, _ := requestAssetParser{}.ParseRequests(page)
+	return results, links
+}
+
+func (requestAssetParser) ParseRequests(page *core.Page) ([]core.Result, []string, []*core.URL) {
+	post := map[string]interface{}{core.MetadataMethod: "POST", core.MetadataBody: `{"page":2}`}
+	return []core.Result{{Type: "page", Data: map[string]interface{}{"url": page.URL}}},
+		[]string{"http://site.test/next", "http://other.test/away"},
+		[]*core.URL{
+			{Address: "http://site.test/api", Metadata: post},
+			{Address: "http://other.test/api", Metadata: post},
+		}
+}
+
+func (requestAssetParser) Assets(page *core.Page) []string {
+	return []string{"http://cdn.test/logo.png"}
+}
+
+func TestMirrorParserForwardsRequestsAndAssets(t *testing.T) {
+	mirror, err := NewMirror(t.TempDir(), []string{"site.test"})
+	if err != nil {
+		t.Fatalf("创建镜像失败: %v", err)
+	}
+	var parser core.Parser = NewMirrorParser(requestAssetParser{}, mirror)
+
+	page := &core.Page{
+		URL:     "http://site.test/",
+		Content: []byte(`<html><body><img src="/img/a.png"></body></html>`),
+		Headers: map[string]string{"Content-Type": "text/html"},
+	}
+
+	assetParser, ok := parser.(core.AssetParser)
+	if !ok {
+		t.Fatal("MirrorParser 没有实现 core.AssetParser")
+	}
+	if assets := assetParser.Assets(page); len(assets) != 1 || assets[0] != "http://cdn.test/logo.png" {
+		t.Errorf("Assets() = %v，期望被包装解析器的资源链接", assets)
+	}
+
+	requestParser, ok := parser.(core.RequestParser)
+	if !ok {
+		t.Fatal("MirrorParser 没有实现 core.RequestParser")
+	}
+	results, links, requests := requestParser.ParseRequests(page)
+	if len(results) != 1 {
+		t.Errorf("结果为 %v，期望被包装解析器的1个结果", results)
+	}
+
+	// 链接和请求都只保留镜像范围内的，页面资源被补充到链接中
+	wantLinks := map[string]bool{"http://site.test/next": true, "http://site.test/img/a.png": true}
+	if len(links) != len(wantLinks) {
+		t.Errorf("链接为 %v，期望 %v", links, wantLinks)
+	}
+	for _, link := range links {
+		if !wantLinks[link] {
+			t.Errorf("链接 %s 不应出现", link)
+		}
+	}
+	if len(requests) != 1 || requests[0].Address != "http://site.test/api" || core.RequestOf(requests[0]).Method != "POST" {
+		t.Errorf("请求为 %v，期望只保留 site.test 的POST请求", requests)
+	}
+}
@@ -0,0 +1,324 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// 代理选择策略
+const (
+	ProxyRoundRobin = "round-robin"
+	ProxyRandom     = "random"
+	ProxySticky     = "sticky"
+)
+
+// ErrNoProxyAvailable 所有代理都被剔除时返回
+var ErrNoProxyAvailable = errors.New("没有可用的代理")
+
+// ProxyStats 单个代理的统计信息
+type ProxyStats struct {
+	// 代理地址（不含用户名和密码）
+	Proxy string
+
+	// 请求数、成功数和失败数
+	Requests  int64
+	Successes int64
+	Failures  int64
+
+	// 当前连续失败次数
+	ConsecutiveFailures int
+
+	// 被剔除的次数
+	Ejections int64
+
+	// 是否可用，以及被剔除后恢复的时间
+	Available    bool
+	EjectedUntil time.Time
+}
+
+// proxy 代理池中的一个代理
+type proxy struct {
+	url   *url.URL
+	stats ProxyStats
+}
+
+// ProxyPool 代理池，支持轮询、随机和按主机固定三种选择策略。
+// 代理连续失败达到阈值后被剔除，冷却时间过后重新参与选择
+type ProxyPool struct {
+	proxies  []*proxy
+	strategy string
+
+	// 连续失败多少次后剔除
+	maxFailures int
+
+	// 剔除后的冷却时间
+	cooldown time.Duration
+
+	// 轮询位置
+	next int
+
+	// 主机 -> 固定使用的代理
+	sticky map[string]*proxy
+
+	rand *rand.Rand
+	mu   sync.Mutex
+}
+
+// NewProxyPool 创建代理池，代理地址支持 http://、https:// 和 socks5:// 三种协议
+func NewProxyPool(proxyURLs []string, strategy string, maxFailures int, cooldown time.Duration) (*ProxyPool, error) {
+	switch strategy {
+	case "":
+		strategy = ProxyRoundRobin
+	case ProxyRoundRobin, ProxyRandom, ProxySticky:
+	default:
+		return nil, fmt.Errorf("未知的代理选择策略 %q", strategy)
+	}
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+
+	pool := &ProxyPool{
+		strategy:    strategy,
+		maxFailures: maxFailures,
+		cooldown:    cooldown,
+		sticky:      make(map[string]*proxy),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, raw := range proxyURLs {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("无效的代理地址 %q: %w", raw, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("不支持的代理协议 %q", u.Scheme)
+		}
+		pool.proxies = append(pool.proxies, &proxy{
+			url:   u,
+			stats: ProxyStats{Proxy: u.Redacted(), Available: true},
+		})
+	}
+	if len(pool.proxies) == 0 {
+		return nil, fmt.Errorf("代理池为空")
+	}
+
+	return pool, nil
+}
+
+// Select 为目标主机选择一个可用的代理
+func (p *ProxyPool) Select(host string) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var available []*proxy
+	for _, px := range p.proxies {
+		if p.available(px, now) {
+			available = append(available, px)
+		}
+	}
+	if len(available) == 0 {
+		return nil, ErrNoProxyAvailable
+	}
+
+	var chosen *proxy
+	switch p.strategy {
+	case ProxyRandom:
+		chosen = available[p.rand.Intn(len(available))]
+	case ProxySticky:
+		if px, ok := p.sticky[host]; ok && p.available(px, now) {
+			chosen = px
+		} else {
+			// 原来的代理不可用时，按轮询重新分配
+			chosen = available[p.next%len(available)]
+			p.next++
+			p.sticky[host] = chosen
+		}
+	default:
+		chosen = available[p.next%len(available)]
+		p.next++
+	}
+
+	chosen.stats.Requests++
+	return chosen.url, nil
+}
+
+// available 检查代理是否可用，冷却结束的代理会被恢复，调用方需持有锁
+func (p *ProxyPool) available(px *proxy, now time.Time) bool {
+	if px.stats.Available {
+		return true
+	}
+	if now.Before(px.stats.EjectedUntil) {
+		return false
+	}
+
+	// 冷却结束，重新参与选择；再失败一次会立即被剔除
+	px.stats.Available = true
+	px.stats.ConsecutiveFailures = p.maxFailures - 1
+	return true
+}
+
+// Report 报告一次请求的结果，连续失败达到阈值的代理会被剔除
+func (p *ProxyPool) Report(proxyURL *url.URL, success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, px := range p.proxies {
+		if px.url != proxyURL {
+			continue
+		}
+
+		if success {
+			px.stats.Successes++
+			px.stats.ConsecutiveFailures = 0
+			return
+		}
+
+		px.stats.Failures++
+		px.stats.ConsecutiveFailures++
+		if px.stats.Available && px.stats.ConsecutiveFailures >= p.maxFailures {
+			px.stats.Available = false
+			px.stats.EjectedUntil = time.Now().Add(p.cooldown)
+			px.stats.Ejections++
+			fmt.Printf("代理 %s 连续失败 %d 次，暂停使用 %v\n",
+				px.stats.Proxy, px.stats.ConsecutiveFailures, p.cooldown)
+		}
+		return
+	}
+}
+
+// Stats 返回所有代理的统计信息
+func (p *ProxyPool) Stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]ProxyStats, 0, len(p.proxies))
+	for _, px := range p.proxies {
+		stats = append(stats, px.stats)
+	}
+	return stats
+}
+
+// proxyContextKey 在请求上下文中传递选中的代理
+type proxyContextKey struct{}
+
+// proxyTransport 为每个请求从代理池中选择代理，并在响应体读完后报告代理的健康状态。
+// 页面、robots.txt、登录和资源下载等经过抓取器的请求都从这里选择和报告，
+// 请求数与成功、失败数保持一致
+type proxyTransport struct {
+	transport *http.Transport
+	pool      *ProxyPool
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxyURL, err := t.pool.Select(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	resp, err := t.transport.RoundTrip(req.WithContext(context.WithValue(ctx, proxyContextKey{}, proxyURL)))
+	if err != nil {
+		// 网络层错误算代理失败；取消或超时是调用方造成的，不算失败
+		t.pool.Report(proxyURL, ctx.Err() != nil)
+		return nil, err
+	}
+
+	// HTTP状态码异常说明代理本身可用，读取响应体时的网络错误仍算代理失败
+	resp.Body = &proxyBody{ReadCloser: resp.Body, ctx: ctx, pool: t.pool, proxy: proxyURL}
+	return resp, nil
+}
+
+// proxyBody 包装响应体，读完、出错或关闭时向代理池报告一次结果
+type proxyBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	pool  *ProxyPool
+	proxy *url.URL
+	once  sync.Once
+}
+
+func (b *proxyBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == nil:
+	case err == io.EOF:
+		b.report(true)
+	default:
+		b.report(b.ctx.Err() != nil)
+	}
+	return n, err
+}
+
+// Close 关闭响应体，没读完就关闭（如超过大小限制）不算代理失败
+func (b *proxyBody) Close() error {
+	b.report(true)
+	return b.ReadCloser.Close()
+}
+
+// report 报告代理的结果，只有第一次生效
+func (b *proxyBody) report(success bool) {
+	b.once.Do(func() {
+		b.pool.Report(b.proxy, success)
+	})
+}
+
+// ProxyFetcher 通过代理池抓取页面的Fetcher。
+// 它包装一个 HTTPFetcher，为每个请求选择代理，并根据结果更新代理的健康状态
+type ProxyFetcher struct {
+	fetcher *core.HTTPFetcher
+	pool    *ProxyPool
+}
+
+// NewProxyFetcher 创建代理抓取器，会替换 fetcher 的 Transport
+func NewProxyFetcher(fetcher *core.HTTPFetcher, pool *ProxyPool) *ProxyFetcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL, _ := req.Context().Value(proxyContextKey{}).(*url.URL)
+		return proxyURL, nil
+	}
+	fetcher.SetTransport(&proxyTransport{transport: transport, pool: pool})
+
+	return &ProxyFetcher{fetcher: fetcher, pool: pool}
+}
+
+// Fetch 实现Fetcher接口，通过代理池中的代理抓取页面
+func (f *ProxyFetcher) Fetch(ctx context.Context, rawURL string) (*core.Page, error) {
+	return f.fetcher.Fetch(ctx, rawURL)
+}
+
+// FetchStream 实现 core.StreamFetcher 接口，通过代理池中的代理抓取页面。
+// 代理的结果在调用方读完或关闭响应体时报告
+func (f *ProxyFetcher) FetchStream(ctx context.Context, rawURL string) (*core.Page, io.ReadCloser, error) {
+	return f.fetcher.FetchStream(ctx, rawURL)
+}
+
+// Unwrap 返回被包装的抓取器
+func (f *ProxyFetcher) Unwrap() core.Fetcher {
+	return f.fetcher
+}
+
+// Pool 返回代理池
+func (f *ProxyFetcher) Pool() *ProxyPool {
+	return f.pool
+}
@@ -0,0 +1,245 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// newStandInProxy 启动一个本地HTTP代理替身，直接用自己的名字响应经过它的请求
+func newStandInProxy(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 经过代理的请求使用完整的URL
+		if !r.URL.IsAbs() {
+			http.Error(w, "不是代理请求", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestProxyFetcher 用给定的代理地址创建代理抓取器
+func newTestProxyFetcher(t *testing.T, strategy string, maxFailures int, cooldown time.Duration, proxies ...string) *ProxyFetcher {
+	t.Helper()
+	pool, err := NewProxyPool(proxies, strategy, maxFailures, cooldown)
+	if err != nil {
+		t.Fatalf("创建代理池失败: %v", err)
+	}
+	return NewProxyFetcher(core.NewHTTPFetcher(5*time.Second), pool)
+}
+
+// statsOf 返回指定代理的统计信息
+func statsOf(t *testing.T, pool *ProxyPool, proxyURL string) ProxyStats {
+	t.Helper()
+	for _, stats := range pool.Stats() {
+		if stats.Proxy == proxyURL {
+			return stats
+		}
+	}
+	t.Fatalf("代理池中没有 %s", proxyURL)
+	return ProxyStats{}
+}
+
+func TestProxyFetcherRoundRobin(t *testing.T) {
+	a := newStandInProxy(t, "a")
+	b := newStandInProxy(t, "b")
+	fetcher := newTestProxyFetcher(t, ProxyRoundRobin, 3, time.Minute, a.URL, b.URL)
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		page, err := fetcher.Fetch(context.Background(), "http://crawl.test/page")
+		if err != nil {
+			t.Fatalf("第 %d 次抓取失败: %v", i+1, err)
+		}
+		got = append(got, string(page.Content))
+	}
+
+	want := []string{"a", "b", "a", "b"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("轮询顺序为 %v，期望 %v", got, want)
+		}
+	}
+	for _, proxyURL := range []string{a.URL, b.URL} {
+		stats := statsOf(t, fetcher.Pool(), proxyURL)
+		if stats.Requests != 2 || stats.Successes != 2 || stats.Failures != 0 {
+			t.Errorf("%s 的统计为 %+v，期望2次请求全部成功", proxyURL, stats)
+		}
+	}
+}
+
+func TestProxyFetcherStickyHost(t *testing.T) {
+	a := newStandInProxy(t, "a")
+	b := newStandInProxy(t, "b")
+	fetcher := newTestProxyFetcher(t, ProxySticky, 3, time.Minute, a.URL, b.URL)
+
+	first := map[string]string{}
+	for i := 0; i < 3; i++ {
+		for _, host := range []string{"one.test", "two.test"} {
+			page, err := fetcher.Fetch(context.Background(), "http://"+host+"/")
+			if err != nil {
+				t.Fatalf("抓取 %s 失败: %v", host, err)
+			}
+			if first[host] == "" {
+				first[host] = string(page.Content)
+			} else if string(page.Content) != first[host] {
+				t.Fatalf("%s 换了代理: %s -> %s", host, first[host], page.Content)
+			}
+		}
+	}
+	if first["one.test"] == first["two.test"] {
+		t.Errorf("两个主机固定到了同一个代理 %s", first["one.test"])
+	}
+}
+
+func TestProxyFetcherEjectsFailingProxy(t *testing.T) {
+	live := newStandInProxy(t, "live")
+
+	// 关闭后的地址拒绝连接，模拟失效的代理
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := dead.URL
+	dead.Close()
+
+	fetcher := newTestProxyFetcher(t, ProxyRoundRobin, 2, time.Hour, deadURL, live.URL)
+
+	failures := 0
+	for i := 0; i < 8; i++ {
+		page, err := fetcher.Fetch(context.Background(), "http://crawl.test/page")
+		if err != nil {
+			failures++
+			continue
+		}
+		if string(page.Content) != "live" {
+			t.Fatalf("响应来自 %q，期望 live", page.Content)
+		}
+	}
+
+	if failures != 2 {
+		t.Errorf("失败 %d 次，期望失效的代理失败2次后被剔除", failures)
+	}
+	stats := statsOf(t, fetcher.Pool(), deadURL)
+	if stats.Available || stats.Ejections != 1 || stats.Failures != 2 {
+		t.Errorf("失效代理的统计为 %+v，期望被剔除一次", stats)
+	}
+	if stats := statsOf(t, fetcher.Pool(), live.URL); stats.Failures != 0 || stats.Successes != 6 {
+		t.Errorf("可用代理的统计为 %+v，期望6次成功", stats)
+	}
+}
+
+func TestProxyPoolReportCooldown(t *testing.T) {
+	pool, err := NewProxyPool([]string{"http://127.0.0.1:1"}, ProxyRoundRobin, 2, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("创建代理池失败: %v", err)
+	}
+
+	proxyURL, err := pool.Select("crawl.test")
+	if err != nil {
+		t.Fatalf("选择代理失败: %v", err)
+	}
+
+	// 成功会清零连续失败次数，只有连续失败达到阈值才剔除
+	pool.Report(proxyURL, false)
+	pool.Report(proxyURL, true)
+	pool.Report(proxyURL, false)
+	if _, err := pool.Select("crawl.test"); err != nil {
+		t.Fatalf("未连续失败的代理被剔除: %v", err)
+	}
+	pool.Report(proxyURL, false)
+	if _, err := pool.Select("crawl.test"); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("连续失败2次后 Select 返回 %v，期望 ErrNoProxyAvailable", err)
+	}
+
+	// 冷却结束后恢复，再失败一次立即剔除
+	time.Sleep(60 * time.Millisecond)
+	if _, err := pool.Select("crawl.test"); err != nil {
+		t.Fatalf("冷却结束后选择代理失败: %v", err)
+	}
+	pool.Report(proxyURL, false)
+	if _, err := pool.Select("crawl.test"); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("恢复后再次失败 Select 返回 %v，期望 ErrNoProxyAvailable", err)
+	}
+	if stats := pool.Stats()[0]; stats.Ejections != 2 {
+		t.Errorf("剔除次数为 %d，期望2", stats.Ejections)
+	}
+}
+
+func TestProxyFetcherCanceledRequestKeepsProxy(t *testing.T) {
+	// 代理一直不响应，直到请求被取消
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+
+	fetcher := newTestProxyFetcher(t, ProxyRoundRobin, 1, time.Hour, slow.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetcher.Fetch(ctx, "http://crawl.test/page"); err == nil {
+		t.Fatal("请求被取消时抓取没有返回错误")
+	}
+
+	// 取消或超时是调用方造成的，不能算作代理失败
+	stats := statsOf(t, fetcher.Pool(), slow.URL)
+	if !stats.Available || stats.Failures != 0 || stats.Ejections != 0 {
+		t.Errorf("请求被取消后代理的统计为 %+v，期望仍然可用且没有失败", stats)
+	}
+	if _, err := fetcher.Pool().Select("crawl.test"); err != nil {
+		t.Errorf("请求被取消后代理不可用: %v", err)
+	}
+}
+
+func TestProxyFetcherBodyErrorCountsAsFailure(t *testing.T) {
+	// 代理声明了响应长度，却在发送一部分后断开连接
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(broken.Close)
+
+	fetcher := newTestProxyFetcher(t, ProxyRoundRobin, 3, time.Hour, broken.URL)
+	if _, err := fetcher.Fetch(context.Background(), "http://crawl.test/page"); err == nil {
+		t.Fatal("响应体不完整时抓取没有返回错误")
+	}
+
+	stats := statsOf(t, fetcher.Pool(), broken.URL)
+	if stats.Requests != 1 || stats.Failures != 1 || stats.Successes != 0 {
+		t.Errorf("读取响应体失败后代理的统计为 %+v，期望1次失败", stats)
+	}
+}
+
+func TestProxyFetcherReportsRequestsOutsideFetch(t *testing.T) {
+	a := newStandInProxy(t, "a")
+	fetcher := newTestProxyFetcher(t, ProxyRoundRobin, 3, time.Hour, a.URL)
+
+	// robots.txt、登录等请求直接使用被包装的抓取器，同样选择代理并报告结果
+	inner := fetcher.Unwrap().(*core.HTTPFetcher)
+	if _, err := inner.Fetch(context.Background(), "http://crawl.test/robots.txt"); err != nil {
+		t.Fatalf("抓取失败: %v", err)
+	}
+	page, body, err := fetcher.FetchStream(context.Background(), "http://crawl.test/page")
+	if err != nil {
+		t.Fatalf("流式抓取失败: %v", err)
+	}
+	if page == nil || body == nil {
+		t.Fatal("流式抓取没有返回响应体")
+	}
+	body.Close()
+
+	stats := statsOf(t, fetcher.Pool(), a.URL)
+	if stats.Requests != 2 || stats.Successes != 2 || stats.Failures != 0 {
+		t.Errorf("代理的统计为 %+v，期望2次请求都报告成功", stats)
+	}
+}
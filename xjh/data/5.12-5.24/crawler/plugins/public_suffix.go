@@ -0,0 +1,114 @@
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultSuffixRules 内置的常用公共后缀规则，格式与 publicsuffix.org 的列表相同。
+// 完整列表可以通过 LoadPublicSuffixFile 从 public_suffix_list.dat 加载
+var defaultSuffixRules = []string{
+	// 通用顶级域
+	"com", "net", "org", "edu", "gov", "mil", "int", "info", "biz", "io", "dev", "app",
+	"co", "me", "tv", "cc", "xyz", "site", "online", "top", "cloud",
+	// 国家和地区
+	"cn", "com.cn", "net.cn", "org.cn", "gov.cn", "edu.cn", "ac.cn",
+	"hk", "com.hk", "tw", "com.tw", "jp", "co.jp", "ne.jp", "or.jp", "ac.jp",
+	"kr", "co.kr", "uk", "co.uk", "org.uk", "ac.uk", "gov.uk",
+	"de", "fr", "ru", "com.ru", "us", "ca", "eu", "nl", "it", "es", "in", "co.in",
+	"au", "com.au", "net.au", "org.au", "br", "com.br", "sg", "com.sg",
+	"*.ck", "!www.ck",
+	// 允许用户注册子域名的私有后缀
+	"github.io", "gitlab.io", "herokuapp.com", "appspot.com", "blogspot.com",
+	"cloudfront.net", "azurewebsites.net", "vercel.app", "netlify.app", "pages.dev",
+}
+
+// PublicSuffixList 实现 cookiejar.PublicSuffixList，
+// 防止网站为 com.cn、github.io 这类公共后缀设置Cookie
+type PublicSuffixList struct {
+	rules      map[string]bool
+	wildcards  map[string]bool
+	exceptions map[string]bool
+}
+
+// NewPublicSuffixList 使用内置规则创建公共后缀列表
+func NewPublicSuffixList() *PublicSuffixList {
+	l := &PublicSuffixList{
+		rules:      make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+	for _, rule := range defaultSuffixRules {
+		l.AddRule(rule)
+	}
+	return l
+}
+
+// LoadPublicSuffixFile 从 publicsuffix.org 格式的文件加载规则，追加到内置规则之后
+func LoadPublicSuffixFile(filename string) (*PublicSuffixList, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	l := NewPublicSuffixList()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		// 每行只取第一个字段
+		l.AddRule(strings.Fields(line)[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	return l, nil
+}
+
+// AddRule 添加一条规则，支持 "*.ck" 通配和 "!www.ck" 例外
+func (l *PublicSuffixList) AddRule(rule string) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	switch {
+	case strings.HasPrefix(rule, "!"):
+		l.exceptions[rule[1:]] = true
+	case strings.HasPrefix(rule, "*."):
+		l.wildcards[rule[2:]] = true
+	case rule != "":
+		l.rules[rule] = true
+	}
+}
+
+// PublicSuffix 返回域名的公共后缀，没有匹配的规则时按默认规则 "*" 取最后一段
+func (l *PublicSuffixList) PublicSuffix(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	labels := strings.Split(domain, ".")
+
+	// 从最长的候选后缀开始匹配
+	for i := 0; i < len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+
+		if l.exceptions[candidate] {
+			// 例外规则：公共后缀是去掉最左一段后的部分
+			return strings.Join(labels[i+1:], ".")
+		}
+		if l.rules[candidate] {
+			return candidate
+		}
+		if i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")] {
+			return candidate
+		}
+	}
+
+	return labels[len(labels)-1]
+}
+
+// String 返回列表的描述，cookiejar 用它区分不同的列表实现
+func (l *PublicSuffixList) String() string {
+	return fmt.Sprintf("crawler-psl(%d rules)", len(l.rules)+len(l.wildcards)+len(l.exceptions))
+}
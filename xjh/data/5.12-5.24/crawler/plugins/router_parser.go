@@ -0,0 +1,296 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// ParserFunc 把函数适配为 core.Parser，用于编写简单的路由处理函数
+type ParserFunc func(page *core.Page) ([]core.Result, []string)
+
+// Parse 实现Parser接口
+func (f ParserFunc) Parse(page *core.Page) ([]core.Result, []string) {
+	return f(page)
+}
+
+// route 一条路由：匹配条件和处理匹配页面的解析器
+type route struct {
+	// 路由的描述，用于解析器版本
+	pattern string
+
+	// 返回页面是否匹配，以及从URL中提取的变量
+	match func(page *core.Page) (map[string]string, bool)
+
+	parser core.Parser
+}
+
+// RouterParser 按URL路径模板、正则表达式、Content-Type或自定义条件把页面分派给不同的解析器。
+// 按注册顺序使用第一条匹配的路由，没有路由匹配时使用默认解析器。
+// 路径变量和正则表达式的命名分组会加入处理器返回的每个结果的 Data，不覆盖处理器设置的同名字段
+type RouterParser struct {
+	routes   []*route
+	fallback core.Parser
+}
+
+// NewRouterParser 创建路由解析器，fallback 处理没有路由匹配的页面，为nil时这些页面不产生结果和链接
+func NewRouterParser(fallback core.Parser) *RouterParser {
+	return &RouterParser{fallback: fallback}
+}
+
+// Handle 按路径模板注册处理器，如 /item/{id}、/user/{name}/posts。
+// {name} 匹配一个非空路径段，最后一段可以写作 {name...} 匹配剩余的路径（可以为空）。
+// 模板不以 / 开头时第一段是主机名，如 shop.example.com/item/{id}，否则匹配任意主机
+func (r *RouterParser) Handle(pattern string, parser core.Parser) error {
+	template, err := compilePathTemplate(pattern)
+	if err != nil {
+		return fmt.Errorf("路径模板 %q 无效: %w", pattern, err)
+	}
+	r.add("path:"+pattern, template.match, parser)
+	return nil
+}
+
+// HandleRegex 按URL正则表达式注册处理器，命名分组作为变量。
+// 与 -rules、-json-rules 的URL正则一样在URL中查找匹配，不要求匹配完整的URL，
+// 需要整体匹配时自行加上 ^ 和 $，如 ^https://example\.com/archive/(?P<year>\d{4})$
+func (r *RouterParser) HandleRegex(expr string, parser core.Parser) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("正则表达式无效: %w", err)
+	}
+
+	r.add("regex:"+expr, func(page *core.Page) (map[string]string, bool) {
+		match := re.FindStringSubmatch(page.URL)
+		if match == nil {
+			return nil, false
+		}
+		vars := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if name != "" && i < len(match) {
+				vars[name] = match[i]
+			}
+		}
+		return vars, true
+	}, parser)
+	return nil
+}
+
+// HandleContentType 按响应的媒体类型注册处理器，如 application/json，
+// 子类型可以写作 * 匹配该类型的所有子类型，如 image/*
+func (r *RouterParser) HandleContentType(mediaType string, parser core.Parser) error {
+	want := strings.ToLower(strings.TrimSpace(mediaType))
+	major, minor, ok := strings.Cut(want, "/")
+	if !ok || major == "" || minor == "" {
+		return fmt.Errorf("媒体类型 %q 无效", mediaType)
+	}
+
+	r.add("type:"+want, func(page *core.Page) (map[string]string, bool) {
+		got, _, err := mime.ParseMediaType(page.Headers["Content-Type"])
+		if err != nil {
+			return nil, false
+		}
+		if minor == "*" {
+			return nil, strings.HasPrefix(got, major+"/")
+		}
+		return nil, got == want
+	}, parser)
+	return nil
+}
+
+// HandleFunc 按自定义条件注册处理器，name 用于区分条件，条件变化时应随之改变以更新解析器版本
+func (r *RouterParser) HandleFunc(name string, match func(page *core.Page) bool, parser core.Parser) {
+	r.add("func:"+name, func(page *core.Page) (map[string]string, bool) {
+		return nil, match(page)
+	}, parser)
+}
+
+// add 追加一条路由
+func (r *RouterParser) add(pattern string, match func(*core.Page) (map[string]string, bool), parser core.Parser) {
+	r.routes = append(r.routes, &route{pattern: pattern, match: match, parser: parser})
+}
+
+// Route 返回处理页面的解析器和URL变量，没有路由匹配时返回默认解析器
+func (r *RouterParser) Route(page *core.Page) (core.Parser, map[string]string) {
+	for _, route := range r.routes {
+		if vars, ok := route.match(page); ok {
+			return route.parser, vars
+		}
+	}
+	return r.fallback, nil
+}
+
+// Version 实现 core.VersionedParser 接口，由路由和各处理器的版本组成，任何一个变化时版本随之变化
+func (r *RouterParser) Version() string {
+	h := sha256.New()
+	for _, route := range r.routes {
+		fmt.Fprintf(h, "%s=%s\n", route.pattern, core.ParserVersion(route.parser))
+	}
+	if r.fallback != nil {
+		fmt.Fprintf(h, "*=%s\n", core.ParserVersion(r.fallback))
+	}
+	return "router/" + hex.EncodeToString(h.Sum(nil)[:4])
+}
+
+// Assets 实现 core.AssetParser 接口，使用匹配的处理器提取的资源链接
+func (r *RouterParser) Assets(page *core.Page) []string {
+	parser, _ := r.Route(page)
+	if assets, ok := parser.(core.AssetParser); ok {
+		return assets.Assets(page)
+	}
+	return nil
+}
+
+// Parse 实现Parser接口
+func (r *RouterParser) Parse(page *core.Page) ([]core.Result, []string) {
+	parser, vars := r.Route(page)
+	if parser == nil {
+		return nil, nil
+	}
+
+	results, links := parser.Parse(page)
+	addRouteVars(results, vars)
+	return results, links
+}
+
+// ParseRequests 实现 core.RequestParser 接口，匹配的处理器实现了该接口时返回它发现的请求
+func (r *RouterParser) ParseRequests(page *core.Page) ([]core.Result, []string, []*core.URL) {
+	parser, vars := r.Route(page)
+	requestParser, ok := parser.(core.RequestParser)
+	if !ok {
+		results, links := r.Parse(page)
+		return results, links, nil
+	}
+
+	results, links, requests := requestParser.ParseRequests(page)
+	addRouteVars(results, vars)
+	return results, links, requests
+}
+
+// addRouteVars 把URL变量加入结果，不覆盖已有的同名字段
+func addRouteVars(results []core.Result, vars map[string]string) {
+	if len(vars) == 0 {
+		return
+	}
+	for i := range results {
+		if results[i].Data == nil {
+			results[i].Data = make(map[string]interface{}, len(vars))
+		}
+		for name, value := range vars {
+			if _, ok := results[i].Data[name]; !ok {
+				results[i].Data[name] = value
+			}
+		}
+	}
+}
+
+// pathTemplate 编译后的路径模板
+type pathTemplate struct {
+	// 为空时匹配任意主机
+	host string
+
+	segments []templateSegment
+}
+
+// templateSegment 路径模板的一段，name 为空时是字面量
+type templateSegment struct {
+	literal string
+	name    string
+	rest    bool
+}
+
+// compilePathTemplate 解析路径模板
+func compilePathTemplate(pattern string) (*pathTemplate, error) {
+	t := &pathTemplate{}
+	path := pattern
+	if !strings.HasPrefix(pattern, "/") {
+		host, rest, ok := strings.Cut(pattern, "/")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("模板必须以 / 或主机名开头")
+		}
+		t.host = strings.ToLower(host)
+		path = "/" + rest
+	}
+
+	seen := make(map[string]bool)
+	parts := strings.Split(path[1:], "/")
+	for i, part := range parts {
+		if !strings.ContainsAny(part, "{}") {
+			t.segments = append(t.segments, templateSegment{literal: part})
+			continue
+		}
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("变量必须占据整个路径段: %s", part)
+		}
+
+		seg := templateSegment{name: part[1 : len(part)-1]}
+		if strings.HasSuffix(seg.name, "...") {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("{%s} 只能是最后一段", seg.name)
+			}
+			seg.name, seg.rest = strings.TrimSuffix(seg.name, "..."), true
+		}
+		if seg.name == "" || strings.ContainsAny(seg.name, "{}/") {
+			return nil, fmt.Errorf("变量名无效: %s", part)
+		}
+		if seen[seg.name] {
+			return nil, fmt.Errorf("变量 %s 重复", seg.name)
+		}
+		seen[seg.name] = true
+		t.segments = append(t.segments, seg)
+	}
+	return t, nil
+}
+
+// match 用模板匹配页面URL的主机和路径，返回路径变量，变量值已解码
+func (t *pathTemplate) match(page *core.Page) (map[string]string, bool) {
+	u, err := url.Parse(page.URL)
+	if err != nil {
+		return nil, false
+	}
+	if t.host != "" && !strings.EqualFold(u.Hostname(), t.host) && !strings.EqualFold(u.Host, t.host) {
+		return nil, false
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	parts := strings.Split(path[1:], "/")
+
+	vars := make(map[string]string)
+	for i, seg := range t.segments {
+		if seg.rest {
+			rest, err := url.PathUnescape(strings.Join(parts[i:], "/"))
+			if err != nil {
+				return nil, false
+			}
+			vars[seg.name] = rest
+			return vars, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, false
+		}
+		if seg.name == "" {
+			if part != seg.literal {
+				return nil, false
+			}
+			continue
+		}
+		if part == "" {
+			return nil, false
+		}
+		vars[seg.name] = part
+	}
+	return vars, len(parts) == len(t.segments)
+}
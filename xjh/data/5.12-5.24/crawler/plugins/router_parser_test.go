@@ -0,0 +1,103 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// namedParser 返回一个结果，类型为解析器的名称
+func namedParser(name string) core.Parser {
+	return ParserFunc(func(page *core.Page) ([]core.Result, []string) {
+		return []core.Result{{Type: name, Data: map[string]interface{}{"id": "handler"}}}, nil
+	})
+}
+
+// describeRoute 把路由结果描述为 "名称 变量=值..."，变量按名称排序
+func describeRoute(results []core.Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	parts := []string{results[0].Type}
+	var names []string
+	for name := range results[0].Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%v", name, results[0].Data[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRouterParserTemplates(t *testing.T) {
+	router := NewRouterParser(namedParser("fallback"))
+	for _, route := range []struct{ pattern, name string }{
+		{"/item/{sku}", "item"},
+		{"/user/{name}/posts", "posts"},
+		{"/static/{path...}", "static"},
+		{"shop.test/{category}/{rest...}", "shop"},
+		{"/", "home"},
+	} {
+		if err := router.Handle(route.pattern, namedParser(route.name)); err != nil {
+			t.Fatalf("注册 %s 失败: %v", route.pattern, err)
+		}
+	}
+	if err := router.HandleRegex(`/archive/(?P<year>\d{4})/(?P<month>\d{2})`, namedParser("archive")); err != nil {
+		t.Fatalf("注册正则表达式失败: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		// {name} 匹配一个非空路径段，变量值已解码，处理器设置的同名字段不被覆盖
+		{"http://site.test/item/42", "item id=handler sku=42"},
+		{"http://site.test/item/a%20b?x=1", "item id=handler sku=a b"},
+		{"http://site.test/item/", "fallback id=handler"},
+		{"http://site.test/item/42/more", "fallback id=handler"},
+		{"http://site.test/user/bob/posts", "posts id=handler name=bob"},
+		{"http://site.test/user//posts", "fallback id=handler"},
+
+		// {name...} 匹配剩余的路径，可以为空或包含多段
+		{"http://site.test/static/css/site.css", "static id=handler path=css/site.css"},
+		{"http://site.test/static/a%2Fb/c%20d", "static id=handler path=a/b/c d"},
+		{"http://site.test/static/", "static id=handler path="},
+		{"http://site.test/static", "static id=handler path="},
+		{"http://site.test/statics/x", "fallback id=handler"},
+
+		// 带主机名的模板只匹配该主机，忽略端口和大小写
+		{"http://shop.test/books/a/b", "shop category=books id=handler rest=a/b"},
+		{"http://SHOP.test:8080/books", "shop category=books id=handler rest="},
+		{"http://other.test/books/a", "fallback id=handler"},
+
+		{"http://site.test/", "home id=handler"},
+		{"http://site.test", "home id=handler"},
+
+		// 正则表达式在URL中查找匹配
+		{"http://site.test/blog/archive/2024/05/", "archive id=handler month=05 year=2024"},
+	}
+	for _, tt := range tests {
+		results, _ := router.Parse(&core.Page{URL: tt.url})
+		if got := describeRoute(results); got != tt.want {
+			t.Errorf("%s 路由到 %q，期望 %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRouterParserTemplateErrors(t *testing.T) {
+	router := NewRouterParser(nil)
+	for _, pattern := range []string{"", "/item/{}", "/item/x{id}", "/{rest...}/tail", "/{id}/{id}", "/item/{id"} {
+		if err := router.Handle(pattern, namedParser("x")); err == nil {
+			t.Errorf("Handle(%q) 没有返回错误", pattern)
+		}
+	}
+
+	// 没有路由匹配且没有默认解析器时不产生结果
+	if results, links := router.Parse(&core.Page{URL: "http://site.test/"}); results != nil || links != nil {
+		t.Errorf("没有默认解析器时返回 %v %v", results, links)
+	}
+}
@@ -0,0 +1,462 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// 默认尝试的日期格式
+var defaultDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"2006年1月2日",
+	time.RFC1123,
+	"Jan 2, 2006",
+	"2 Jan 2006",
+}
+
+// ExtractionRule 一条提取规则：对URL匹配的页面，按CSS选择器或XPath提取一组字段，生成指定类型的结果
+type ExtractionRule struct {
+	// 结果类型，如 "product"
+	Name string `json:"name"`
+
+	// URL正则表达式，为空时匹配所有页面
+	URL string `json:"url"`
+
+	// 每个匹配的元素生成一个结果，为空时整个页面生成一个结果
+	Selector string `json:"selector"`
+
+	// 用XPath代替 Selector 选择元素，结果必须是节点集
+	XPath string `json:"xpath"`
+
+	// 提取的字段
+	Fields []*FieldRule `json:"fields"`
+
+	// 要跟随的链接的选择器，取元素的 href 或 src 属性
+	Links []string `json:"links"`
+
+	urlRegex  *regexp.Regexp
+	selector  *core.Selector
+	xpath     *core.XPath
+	linkQuery []*core.Selector
+}
+
+// FieldRule 一个字段的提取方式
+type FieldRule struct {
+	// 字段名
+	Name string `json:"name"`
+
+	// 相对于规则选择器的元素选择器，为空时使用元素本身
+	Selector string `json:"selector"`
+
+	// 用相对于规则选择的元素求值的XPath代替 Selector，
+	// 结果是字符串、数字或布尔值时直接作为字段的原始值
+	XPath string `json:"xpath"`
+
+	// 提取内容：text（默认，合并空白后的文本）、attr（属性值）、html（内部HTML）、
+	// list（所有匹配元素的文本或属性值）
+	Kind string `json:"kind"`
+
+	// kind 为 attr 或 list 时读取的属性
+	Attr string `json:"attr"`
+
+	// 对提取的值做正则匹配，有分组时取第一个分组，否则取整个匹配，不匹配时不输出字段
+	Regex string `json:"regex"`
+
+	// 类型转换：string（默认）、number、bool、date
+	Type string `json:"type"`
+
+	// type 为 date 时的日期格式（Go时间格式），为空时尝试常见格式
+	Layout string `json:"layout"`
+
+	selector *core.Selector
+	xpath    *core.XPath
+	regex    *regexp.Regexp
+}
+
+// RulesParser 按声明式规则用CSS选择器或XPath提取结果的解析器。
+// 链接和基本的页面结果由被包装的解析器提供，规则产生的结果追加在后面
+type RulesParser struct {
+	parser core.Parser
+	rules  []*ExtractionRule
+
+	// 规则内容的哈希，用于解析器版本
+	digest string
+}
+
+// NewRulesParser 编译规则并创建解析器，parser 提供链接和基本结果
+func NewRulesParser(parser core.Parser, rules []*ExtractionRule) (*RulesParser, error) {
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("第 %d 条规则 %s: %w", i+1, rule.Name, err)
+		}
+	}
+
+	encoded, _ := json.Marshal(rules)
+	sum := sha256.Sum256(encoded)
+
+	return &RulesParser{
+		parser: parser,
+		rules:  rules,
+		digest: hex.EncodeToString(sum[:4]),
+	}, nil
+}
+
+// LoadRulesParser 从JSON文件加载规则并创建解析器，文件内容是 ExtractionRule 数组
+func LoadRulesParser(parser core.Parser, filename string) (*RulesParser, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	var rules []*ExtractionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解码数据失败: %w", err)
+	}
+
+	return NewRulesParser(parser, rules)
+}
+
+// compile 编译规则中的正则表达式和选择器
+func (r *ExtractionRule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("规则缺少名称")
+	}
+
+	var err error
+	if r.URL != "" {
+		if r.urlRegex, err = regexp.Compile(r.URL); err != nil {
+			return fmt.Errorf("URL正则表达式无效: %w", err)
+		}
+	}
+	if r.Selector != "" && r.XPath != "" {
+		return fmt.Errorf("selector 和 xpath 不能同时设置")
+	}
+	if r.Selector != "" {
+		if r.selector, err = core.CompileSelector(r.Selector); err != nil {
+			return err
+		}
+	}
+	if r.XPath != "" {
+		if r.xpath, err = core.CompileXPath(r.XPath); err != nil {
+			return err
+		}
+	}
+	r.linkQuery = nil
+	for _, link := range r.Links {
+		selector, err := core.CompileSelector(link)
+		if err != nil {
+			return err
+		}
+		r.linkQuery = append(r.linkQuery, selector)
+	}
+
+	for _, field := range r.Fields {
+		if err := field.compile(); err != nil {
+			return fmt.Errorf("字段 %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// compile 检查字段配置并编译选择器和正则表达式
+func (f *FieldRule) compile() error {
+	if f.Name == "" {
+		return fmt.Errorf("字段缺少名称")
+	}
+
+	switch f.Kind {
+	case "", "text", "html", "list":
+	case "attr":
+		if f.Attr == "" {
+			return fmt.Errorf("attr 类型的字段缺少属性名")
+		}
+	default:
+		return fmt.Errorf("未知的提取方式: %s", f.Kind)
+	}
+
+	switch f.Type {
+	case "", "string", "number", "bool", "date":
+	default:
+		return fmt.Errorf("未知的类型: %s", f.Type)
+	}
+
+	if f.Selector != "" && f.XPath != "" {
+		return fmt.Errorf("selector 和 xpath 不能同时设置")
+	}
+
+	var err error
+	if f.Selector != "" {
+		if f.selector, err = core.CompileSelector(f.Selector); err != nil {
+			return err
+		}
+	}
+	if f.XPath != "" {
+		if f.xpath, err = core.CompileXPath(f.XPath); err != nil {
+			return err
+		}
+	}
+	if f.Regex != "" {
+		if f.regex, err = regexp.Compile(f.Regex); err != nil {
+			return fmt.Errorf("正则表达式无效: %w", err)
+		}
+	}
+	return nil
+}
+
+// Version 实现 core.VersionedParser 接口，规则变化时版本随之变化
+func (p *RulesParser) Version() string {
+	return core.ParserVersion(p.parser) + "+rules/" + p.digest
+}
+
+// Assets 实现 core.AssetParser 接口，使用被包装解析器提取的资源链接
+func (p *RulesParser) Assets(page *core.Page) []string {
+	if parser, ok := p.parser.(core.AssetParser); ok {
+		return parser.Assets(page)
+	}
+	return nil
+}
+
+// Parse 实现Parser接口
+func (p *RulesParser) Parse(page *core.Page) ([]core.Result, []string) {
+	results, links := p.parser.Parse(page)
+
+	var matched []*ExtractionRule
+	for _, rule := range p.rules {
+		if rule.urlRegex == nil || rule.urlRegex.MatchString(page.URL) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 || len(page.Content) == 0 {
+		return results, links
+	}
+
+	doc, err := core.ParseHTML(bytes.NewReader(page.Content))
+	if err != nil {
+		fmt.Printf("解析HTML失败 %s: %v\n", page.URL, err)
+		return results, links
+	}
+
+	base, _ := url.Parse(page.URL)
+	for _, rule := range matched {
+		extracted, err := rule.extract(page, doc)
+		if err != nil {
+			fmt.Printf("应用规则 %s 失败 %s: %v\n", rule.Name, page.URL, err)
+		}
+		results = append(results, extracted...)
+		if base != nil {
+			links = append(links, rule.follow(base, doc)...)
+		}
+	}
+	return results, links
+}
+
+// extract 对页面应用规则，生成结果
+func (r *ExtractionRule) extract(page *core.Page, doc *core.Node) ([]core.Result, error) {
+	scopes := []*core.Node{doc}
+	switch {
+	case r.selector != nil:
+		scopes = r.selector.Select(doc)
+	case r.xpath != nil:
+		var err error
+		if scopes, err = r.xpath.Select(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]core.Result, 0, len(scopes))
+	for _, scope := range scopes {
+		data := map[string]interface{}{"url": page.URL}
+		for _, field := range r.Fields {
+			value, ok, err := field.extract(scope)
+			if err != nil {
+				fmt.Printf("提取字段 %s.%s 失败 %s: %v\n", r.Name, field.Name, page.URL, err)
+				continue
+			}
+			if ok {
+				data[field.Name] = value
+			}
+		}
+		results = append(results, core.Result{Type: r.Name, Data: data})
+	}
+	return results, nil
+}
+
+// follow 返回规则中链接选择器匹配的链接
+func (r *ExtractionRule) follow(base *url.URL, doc *core.Node) []string {
+	var links []string
+	for _, selector := range r.linkQuery {
+		for _, n := range selector.Select(doc) {
+			href, ok := n.LookupAttr("href")
+			if !ok {
+				href = n.Attr("src")
+			}
+			if link := resolveLink(base, href); href != "" && link != "" {
+				links = append(links, link)
+			}
+		}
+	}
+	return links
+}
+
+// extract 从元素中提取字段值，元素不存在或正则不匹配时返回false
+func (f *FieldRule) extract(scope *core.Node) (interface{}, bool, error) {
+	nodes := []*core.Node{scope}
+	switch {
+	case f.selector != nil:
+		nodes = f.selector.Select(scope)
+	case f.xpath != nil:
+		value, err := f.xpath.Evaluate(scope)
+		if err != nil {
+			return nil, false, err
+		}
+		selected, ok := value.([]*core.Node)
+		if !ok {
+			return f.convertScalar(core.XPathString(value))
+		}
+		nodes = selected
+	}
+	if len(nodes) == 0 {
+		return nil, false, nil
+	}
+
+	if f.Kind == "list" {
+		values := make([]interface{}, 0, len(nodes))
+		for _, n := range nodes {
+			value, ok, err := f.convert(f.raw(n))
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				values = append(values, value)
+			}
+		}
+		return values, true, nil
+	}
+
+	return f.convert(f.raw(nodes[0]))
+}
+
+// convertScalar 转换XPath的字符串、数字或布尔结果，list 类型的字段得到只有一个值的列表
+func (f *FieldRule) convertScalar(raw string) (interface{}, bool, error) {
+	value, ok, err := f.convert(raw)
+	if err != nil || !ok || f.Kind != "list" {
+		return value, ok, err
+	}
+	return []interface{}{value}, true, nil
+}
+
+// raw 按提取方式读取元素的原始值
+func (f *FieldRule) raw(n *core.Node) string {
+	switch f.Kind {
+	case "attr":
+		return n.Attr(f.Attr)
+	case "html":
+		return n.InnerHTML()
+	case "list":
+		if f.Attr != "" {
+			return n.Attr(f.Attr)
+		}
+	}
+	return strings.Join(strings.Fields(n.Text()), " ")
+}
+
+// convert 对值做正则匹配和类型转换
+func (f *FieldRule) convert(value string) (interface{}, bool, error) {
+	if f.regex != nil {
+		match := f.regex.FindStringSubmatch(value)
+		if match == nil {
+			return nil, false, nil
+		}
+		value = match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+	}
+
+	switch f.Type {
+	case "number":
+		n, err := parseNumber(value)
+		if err != nil {
+			return nil, false, err
+		}
+		return n, true, nil
+	case "bool":
+		b, err := parseBool(value)
+		if err != nil {
+			return nil, false, err
+		}
+		return b, true, nil
+	case "date":
+		t, err := parseDate(value, f.Layout)
+		if err != nil {
+			return nil, false, err
+		}
+		return t, true, nil
+	}
+	return value, true, nil
+}
+
+// numberRegex 文本中的第一个数字，可以带千位分隔符、小数和指数
+var numberRegex = regexp.MustCompile(`[-+]?\d[\d,]*(\.\d+)?([eE][-+]?\d+)?`)
+
+// parseNumber 解析文本中的第一个数字，忽略前后的文字、货币符号和千位分隔符，
+// 如 "Price: $1,299.00"、"12 items"
+func parseNumber(value string) (float64, error) {
+	match := numberRegex.FindString(value)
+	if match == "" {
+		return 0, fmt.Errorf("无法转换为数字: %q", value)
+	}
+
+	n, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法转换为数字: %q", value)
+	}
+	return n, nil
+}
+
+// parseBool 解析布尔值，除 strconv.ParseBool 支持的写法外还支持 yes/no、on/off
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "on", "是":
+		return true, nil
+	case "no", "n", "off", "否", "":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("无法转换为布尔值: %q", value)
+	}
+	return b, nil
+}
+
+// parseDate 按指定格式解析日期，没有指定格式时依次尝试常见格式
+func parseDate(value, layout string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	layouts := defaultDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+
+	for _, l := range layouts {
+		if t, err := time.Parse(l, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法转换为日期: %q", value)
+}
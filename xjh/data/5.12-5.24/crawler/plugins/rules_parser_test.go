@@ -0,0 +1,31 @@
+package plugins
+
+import "testing"
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"42", 42},
+		{"Price: 1,299.00", 1299},
+		{"$1,299.50 USD", 1299.5},
+		{"12 items", 12},
+		{"Only 5 left", 5},
+		{"-3.5", -3.5},
+		{"1.5e3", 1500},
+		{"Rated 4.5 out of 5", 4.5},
+	}
+	for _, tt := range tests {
+		got, err := parseNumber(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, %v，期望 %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "none", "e"} {
+		if _, err := parseNumber(value); err == nil {
+			t.Errorf("parseNumber(%q) 没有返回错误", value)
+		}
+	}
+}
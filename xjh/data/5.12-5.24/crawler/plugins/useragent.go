@@ -0,0 +1,136 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// HeaderProfile 一组请求头配置，对匹配的主机生效
+type HeaderProfile struct {
+	// 主机模式，支持 "example.com"、"*.example.com" 和 "*"
+	Hosts []string `json:"hosts"`
+
+	// 可选的User-Agent列表，有多个时按主机轮换
+	UserAgents []string `json:"user_agents"`
+
+	// Accept-Language 和 Accept 头
+	AcceptLanguage string `json:"accept_language"`
+	Accept         string `json:"accept"`
+
+	// 其他自定义请求头
+	Headers map[string]string `json:"headers"`
+}
+
+// matches 检查主机是否匹配该配置
+func (p *HeaderProfile) matches(host string) bool {
+	for _, pattern := range p.Hosts {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == host {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderProfiles 按主机选择请求头配置，实现 core.HeaderProvider。
+// 配置按顺序匹配，使用第一个匹配的配置。UA列表中有多个UA时，
+// 每个新主机轮流分配一个UA，之后该主机的所有请求都使用同一个UA，
+// 保证发送的UA与检查robots.txt规则组时使用的UA一致
+type HeaderProfiles struct {
+	profiles []*HeaderProfile
+
+	// 主机 -> 分配的UA
+	assigned map[string]string
+
+	// 每个配置的轮换位置
+	next map[*HeaderProfile]int
+
+	mu sync.Mutex
+}
+
+// NewHeaderProfiles 创建按主机选择的请求头配置
+func NewHeaderProfiles(profiles []*HeaderProfile) *HeaderProfiles {
+	return &HeaderProfiles{
+		profiles: profiles,
+		assigned: make(map[string]string),
+		next:     make(map[*HeaderProfile]int),
+	}
+}
+
+// LoadHeaderProfiles 从JSON文件加载请求头配置，文件内容是 HeaderProfile 数组
+func LoadHeaderProfiles(filename string) (*HeaderProfiles, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	var profiles []*HeaderProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("解码数据失败: %w", err)
+	}
+
+	return NewHeaderProfiles(profiles), nil
+}
+
+// AddProfile 追加一个配置，优先级低于已有配置
+func (h *HeaderProfiles) AddProfile(profile *HeaderProfile) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.profiles = append(h.profiles, profile)
+}
+
+// Headers 实现 core.HeaderProvider 接口
+func (h *HeaderProfiles) Headers(host string) map[string]string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, profile := range h.profiles {
+		if !profile.matches(host) {
+			continue
+		}
+
+		headers := make(map[string]string, len(profile.Headers)+3)
+		for k, v := range profile.Headers {
+			headers[k] = v
+		}
+		if profile.AcceptLanguage != "" {
+			headers["Accept-Language"] = profile.AcceptLanguage
+		}
+		if profile.Accept != "" {
+			headers["Accept"] = profile.Accept
+		}
+		if ua := h.userAgent(profile, host); ua != "" {
+			headers["User-Agent"] = ua
+		}
+		return headers
+	}
+
+	return nil
+}
+
+// userAgent 返回主机分配到的UA，首次出现的主机从列表中轮流分配，调用方需持有锁
+func (h *HeaderProfiles) userAgent(profile *HeaderProfile, host string) string {
+	if len(profile.UserAgents) == 0 {
+		return ""
+	}
+	if ua, ok := h.assigned[host]; ok {
+		return ua
+	}
+
+	ua := profile.UserAgents[h.next[profile]%len(profile.UserAgents)]
+	h.next[profile]++
+	h.assigned[host] = ua
+	return ua
+}
@@ -0,0 +1,460 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// ErrNotArchived 请求的URL不在WARC归档中
+var ErrNotArchived = errors.New("归档中没有该URL")
+
+// cdxHeader CDX索引文件的表头：URL键、时间、原始URL、MIME类型、状态码、摘要、记录长度、偏移、文件名
+const cdxHeader = " CDX N b a m s k S V g"
+
+// CDXEntry 索引中的一条记录，指向WARC文件中的一条 response 记录
+type CDXEntry struct {
+	URLKey    string
+	Timestamp string
+	Original  string
+	MIME      string
+	Status    int
+	Digest    string
+	Length    int64
+	Offset    int64
+	Filename  string
+}
+
+// String 按CDX格式输出
+func (e *CDXEntry) String() string {
+	mime := e.MIME
+	if mime == "" {
+		mime = "-"
+	}
+	return fmt.Sprintf("%s %s %s %s %d %s %d %d %s", e.URLKey, e.Timestamp, e.Original,
+		mime, e.Status, e.Digest, e.Length, e.Offset, e.Filename)
+}
+
+// ReplayKey 返回请求在CDX索引中的键。与 core.RequestKey 一样由方法、URL和请求体的哈希组成：
+// GET请求为规范化的URL，其他请求在URL后加上 "#方法:请求体哈希"（规范化的URL不含片段，键中也没有空格）
+func ReplayKey(rawURL string, req core.Request) string {
+	key := CanonicalURL(rawURL)
+	if req.IsGet() {
+		return key
+	}
+	sum := sha256.Sum256(req.Body)
+	return key + "#" + req.Method + ":" + hex.EncodeToString(sum[:8])
+}
+
+// parseCDXLine 解析一行CDX索引
+func parseCDXLine(line string) (*CDXEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 9 {
+		return nil, fmt.Errorf("无效的CDX行 %q", line)
+	}
+
+	status, err1 := strconv.Atoi(fields[4])
+	length, err2 := strconv.ParseInt(fields[6], 10, 64)
+	offset, err3 := strconv.ParseInt(fields[7], 10, 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("无效的CDX行 %q: %w", line, err)
+	}
+
+	return &CDXEntry{
+		URLKey:    fields[0],
+		Timestamp: fields[1],
+		Original:  fields[2],
+		MIME:      fields[3],
+		Status:    status,
+		Digest:    fields[5],
+		Length:    length,
+		Offset:    offset,
+		Filename:  fields[8],
+	}, nil
+}
+
+// warcRecord 解析出的一条WARC记录
+type warcRecord struct {
+	header textproto.MIMEHeader
+	block  []byte
+}
+
+// WARCFetcher 从WARC文件回放页面的Fetcher，不访问网络，索引建立后只读，可以并发使用。
+// 第一次打开WARC文件时为其建立CDX索引（保存为同名的 .cdx 文件），
+// 之后直接加载索引。同一请求有多次抓取时使用最后一次，304响应不会覆盖之前的抓取。
+// 页面URL经过重定向时，根据 metadata 记录中的 page-url 同样可以找到最终的响应；
+// POST等非GET请求按 metadata 记录中的 request-key 索引，同一接口不同请求体的响应分别回放
+type WARCFetcher struct {
+	// 请求键（ReplayKey）-> 索引记录
+	index map[string]*CDXEntry
+
+	// 文件名 -> 路径
+	files map[string]string
+}
+
+// NewWARCFetcher 打开WARC文件并建立索引，paths 可以是文件、目录或通配符
+func NewWARCFetcher(paths ...string) (*WARCFetcher, error) {
+	f := &WARCFetcher{
+		index: make(map[string]*CDXEntry),
+		files: make(map[string]string),
+	}
+
+	var files []string
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			p = filepath.Join(p, "*.warc*")
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的路径 %q: %w", p, err)
+		}
+		for _, m := range matches {
+			if strings.HasSuffix(m, ".warc") || strings.HasSuffix(m, ".warc.gz") {
+				files = append(files, m)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("没有找到WARC文件")
+	}
+
+	// 按文件名排序，文件名中的时间保证后抓取的覆盖先抓取的
+	sort.Strings(files)
+	for _, path := range files {
+		if err := f.open(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// open 加载或建立一个WARC文件的索引
+func (f *WARCFetcher) open(path string) error {
+	entries, err := loadCDX(path)
+	if err != nil {
+		if entries, err = buildCDX(path); err != nil {
+			return fmt.Errorf("建立索引失败 %s: %w", path, err)
+		}
+		if err := saveCDX(path, entries); err != nil {
+			fmt.Printf("保存索引失败 %s: %v\n", path, err)
+		}
+	}
+
+	f.files[filepath.Base(path)] = path
+	for _, e := range entries {
+		if old, ok := f.index[e.URLKey]; ok && e.Status == http.StatusNotModified && old.Status != http.StatusNotModified {
+			continue
+		}
+		f.index[e.URLKey] = e
+	}
+	return nil
+}
+
+// Fetch 实现Fetcher接口，按 ctx 中的请求方式从归档中读取页面
+func (f *WARCFetcher) Fetch(ctx context.Context, url string) (*core.Page, error) {
+	entry, ok := f.index[ReplayKey(url, core.RequestFromContext(ctx))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotArchived, url)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	record, err := readRecordAt(f.files[entry.Filename], entry.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档失败: %w", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.block)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("解析归档的响应失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 与在线抓取的行为保持一致
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP状态码异常: %d", resp.StatusCode)
+	}
+
+	fetched, err := time.Parse(time.RFC3339Nano, record.header.Get("WARC-Date"))
+	if err != nil {
+		fetched = time.Now()
+	}
+
+	return core.NewPage(url, resp, content, fetched), nil
+}
+
+// Entries 返回索引中的所有记录，按URL键排序
+func (f *WARCFetcher) Entries() []*CDXEntry {
+	entries := make([]*CDXEntry, 0, len(f.index))
+	for _, e := range f.index {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URLKey < entries[j].URLKey
+	})
+	return entries
+}
+
+// Len 返回索引中的URL数
+func (f *WARCFetcher) Len() int {
+	return len(f.index)
+}
+
+// loadCDX 加载WARC文件对应的索引，索引不存在或比WARC文件旧时返回错误
+func loadCDX(path string) ([]*CDXEntry, error) {
+	warcInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	cdxInfo, err := os.Stat(path + ".cdx")
+	if err != nil {
+		return nil, err
+	}
+	if cdxInfo.ModTime().Before(warcInfo.ModTime()) {
+		return nil, fmt.Errorf("索引已过期")
+	}
+
+	data, err := os.ReadFile(path + ".cdx")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*CDXEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, " CDX") {
+			continue
+		}
+		e, err := parseCDXLine(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// saveCDX 保存索引
+func saveCDX(path string, entries []*CDXEntry) error {
+	var buf bytes.Buffer
+	buf.WriteString(cdxHeader + "\n")
+	for _, e := range entries {
+		buf.WriteString(e.String() + "\n")
+	}
+	return os.WriteFile(path+".cdx", buf.Bytes(), 0644)
+}
+
+// buildCDX 扫描WARC文件，为每条 response 记录建立索引。
+// response 记录以目标URL为键；metadata 记录中有 request-key 时（非GET请求）改用该键，
+// 否则 page-url 与目标URL不同时（发生了重定向），为页面URL再添加一条指向同一响应的记录
+func buildCDX(path string) ([]*CDXEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*CDXEntry
+	responses := make(map[string]*CDXEntry)
+	name := filepath.Base(path)
+
+	err = scanRecords(file, strings.HasSuffix(path, ".gz"), func(rec *warcRecord, offset, length int64) {
+		switch rec.header.Get("WARC-Type") {
+		case WARCResponse:
+			target := rec.header.Get("WARC-Target-URI")
+			e := &CDXEntry{
+				URLKey:    CanonicalURL(target),
+				Timestamp: "-",
+				Original:  target,
+				MIME:      "-",
+				Digest:    rec.header.Get("WARC-Payload-Digest"),
+				Length:    length,
+				Offset:    offset,
+				Filename:  name,
+			}
+			if t, err := time.Parse(time.RFC3339Nano, rec.header.Get("WARC-Date")); err == nil {
+				e.Timestamp = t.UTC().Format("20060102150405")
+			}
+			if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.block)), nil); err == nil {
+				e.Status = resp.StatusCode
+				if ct := strings.Fields(resp.Header.Get("Content-Type")); len(ct) > 0 {
+					e.MIME = strings.TrimSuffix(ct[0], ";")
+				}
+				resp.Body.Close()
+			}
+			if e.Digest == "" {
+				e.Digest = "-"
+			}
+			entries = append(entries, e)
+			responses[rec.header.Get("WARC-Record-ID")] = e
+		case WARCMetadata:
+			resp, ok := responses[rec.header.Get("WARC-Concurrent-To")]
+			if !ok {
+				return
+			}
+			fields := make(map[string]string)
+			for _, line := range strings.Split(string(rec.block), "\n") {
+				if k, v, ok := strings.Cut(strings.TrimSpace(line), ":"); ok {
+					if _, seen := fields[k]; !seen {
+						fields[k] = strings.TrimSpace(v)
+					}
+				}
+			}
+
+			// 非GET请求的响应只能按请求键找到，同一URL的GET请求不会回放它
+			if key := fields["request-key"]; key != "" {
+				resp.URLKey = key
+				return
+			}
+			if pageURL := fields["page-url"]; pageURL != "" {
+				if key := CanonicalURL(pageURL); key != resp.URLKey {
+					alias := *resp
+					alias.URLKey = key
+					alias.Original = pageURL
+					entries = append(entries, &alias)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// countingReader 统计已读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// scanRecords 依次读取文件中的记录，回调参数为记录及其在文件中的偏移和长度。
+// 压缩文件中每条记录是一个单独的gzip成员
+func scanRecords(r io.Reader, compressed bool, fn func(rec *warcRecord, offset, length int64)) error {
+	counter := &countingReader{r: r}
+	br := bufio.NewReader(counter)
+	pos := func() int64 { return counter.n - int64(br.Buffered()) }
+
+	var zr *gzip.Reader
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		}
+		offset := pos()
+
+		var rec *warcRecord
+		var err error
+		if compressed {
+			if zr == nil {
+				zr, err = gzip.NewReader(br)
+			} else {
+				err = zr.Reset(br)
+			}
+			if err != nil {
+				return fmt.Errorf("偏移 %d: 解压失败: %w", offset, err)
+			}
+			// gzip.Reader 直接使用 bufio.Reader，不会读过当前成员的末尾
+			zr.Multistream(false)
+			if rec, err = readRecord(bufio.NewReader(zr)); err == nil {
+				_, err = io.Copy(io.Discard, zr)
+			}
+		} else {
+			rec, err = readRecord(br)
+		}
+		if err != nil {
+			return fmt.Errorf("偏移 %d: %w", offset, err)
+		}
+
+		fn(rec, offset, pos()-offset)
+	}
+}
+
+// readRecordAt 读取文件中指定偏移处的记录
+func readRecordAt(path string, offset int64) (*warcRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return readRecord(bufio.NewReader(file))
+	}
+
+	zr, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("解压失败: %w", err)
+	}
+	zr.Multistream(false)
+	return readRecord(bufio.NewReader(zr))
+}
+
+// readRecord 读取一条记录：版本行、记录头、内容块和结尾的两个换行
+func readRecord(br *bufio.Reader) (*warcRecord, error) {
+	tp := textproto.NewReader(br)
+
+	version, err := tp.ReadLine()
+	if err != nil {
+		return nil, fmt.Errorf("读取记录失败: %w", err)
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return nil, fmt.Errorf("无效的WARC记录: %q", version)
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && !(errors.Is(err, io.EOF) && len(header) > 0) {
+		return nil, fmt.Errorf("读取记录头失败: %w", err)
+	}
+
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("无效的 Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	block := make([]byte, length)
+	if _, err := io.ReadFull(br, block); err != nil {
+		return nil, fmt.Errorf("读取内容失败: %w", err)
+	}
+
+	// 跳过记录结尾的 \r\n\r\n
+	for i := 0; i < 2; i++ {
+		if line, err := br.ReadString('\n'); err != nil || strings.TrimRight(line, "\r\n") != "" {
+			return nil, fmt.Errorf("记录结尾格式错误")
+		}
+	}
+
+	return &warcRecord{header: header, block: block}, nil
+}
@@ -0,0 +1,95 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// postContext 返回以 body 为请求体的POST请求的 context
+func postContext(body string) context.Context {
+	return core.ContextWithRequest(context.Background(), core.Request{
+		Method:  http.MethodPost,
+		Body:    []byte(body),
+		Headers: map[string]string{"Content-Type": "application/json"},
+	})
+}
+
+func TestWARCReplayDistinguishesRequestBodies(t *testing.T) {
+	// 接口按请求方法和请求体返回不同的内容
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writer, err := NewWARCWriter(WARCOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("创建WARC写入器失败: %v", err)
+	}
+	fetcher := core.NewHTTPFetcher(5 * time.Second)
+	fetcher.SetResponseRecorder(writer)
+
+	api := server.URL + "/api"
+	contexts := map[string]context.Context{
+		"get":   context.Background(),
+		"page1": postContext(`page=1`),
+		"page2": postContext(`page=2`),
+	}
+	live := make(map[string]string)
+	for name, ctx := range contexts {
+		page, err := fetcher.Fetch(ctx, api)
+		if err != nil {
+			t.Fatalf("抓取 %s 失败: %v", name, err)
+		}
+		live[name] = string(page.Content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("关闭WARC写入器失败: %v", err)
+	}
+	if live["page1"] == live["page2"] || live["get"] == live["page1"] {
+		t.Fatalf("接口对不同请求返回了相同的内容: %v", live)
+	}
+
+	replay, err := NewWARCFetcher(dir)
+	if err != nil {
+		t.Fatalf("打开WARC归档失败: %v", err)
+	}
+	for name, ctx := range contexts {
+		page, err := replay.Fetch(ctx, api)
+		if err != nil {
+			t.Errorf("回放 %s 失败: %v", name, err)
+			continue
+		}
+		if string(page.Content) != live[name] {
+			t.Errorf("回放 %s 得到 %s，期望 %s", name, page.Content, live[name])
+		}
+	}
+
+	// 没有归档的请求体不能回放成其他请求的响应
+	if _, err := replay.Fetch(postContext(`page=3`), api); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("回放未归档的请求返回 %v，期望 ErrNotArchived", err)
+	}
+}
+
+func TestHTTPRequestBlockIncludesBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://site.test/api?x=1", nil)
+	if err != nil {
+		t.Fatalf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+
+	got := string(httpRequestBlock(req, []byte(`{"page":2}`)))
+	want := "POST /api?x=1 HTTP/1.1\r\nHost: site.test\r\nAuthorization: ******\r\nContent-Length: 10\r\n\r\n{\"page\":2}"
+	if got != want {
+		t.Errorf("请求报文为 %q，期望 %q", got, want)
+	}
+}
@@ -0,0 +1,312 @@
+package plugins
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/m/xjh/data/5.12-5.24/crawler/core"
+)
+
+// WARC记录类型
+const (
+	WARCInfo     = "warcinfo"
+	WARCRequest  = "request"
+	WARCResponse = "response"
+	WARCMetadata = "metadata"
+)
+
+// redactedHeaders 写入归档前隐藏的请求头，避免凭据进入WARC文件
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// WARCOptions WARC输出配置
+type WARCOptions struct {
+	// 输出目录
+	Dir string
+
+	// 文件名前缀，文件名为 前缀-时间-序号.warc.gz
+	Prefix string
+
+	// 单个文件的大小上限（字节），超出后切换到新文件，0表示不切换
+	MaxSize int64
+
+	// 写入 warcinfo 记录的软件名称
+	Software string
+}
+
+// WARCWriter 把每次抓取的请求和响应写成 WARC 1.1 记录，实现 core.ResponseRecorder。
+// 每个响应写入 request、response 和 metadata 三条记录，每条记录单独gzip压缩，
+// 文件达到大小上限后切换到新文件，每个文件以一条 warcinfo 记录开头
+type WARCWriter struct {
+	options WARCOptions
+
+	file     *os.File
+	size     int64
+	serial   int
+	infoID   string
+	filename string
+
+	mu sync.Mutex
+}
+
+// NewWARCWriter 创建WARC写入器，并打开第一个文件
+func NewWARCWriter(options WARCOptions) (*WARCWriter, error) {
+	if options.Dir == "" {
+		options.Dir = "."
+	}
+	if options.Prefix == "" {
+		options.Prefix = "crawl"
+	}
+	if options.Software == "" {
+		options.Software = core.DefaultUserAgent
+	}
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建WARC目录失败: %w", err)
+	}
+
+	w := &WARCWriter{options: options}
+	if err := w.rotate(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// RecordResponse 实现 core.ResponseRecorder 接口
+func (w *WARCWriter) RecordResponse(ex *core.Exchange) error {
+	date := ex.Time.UTC().Format(time.RFC3339Nano)
+	target := ex.Request.URL.String()
+
+	requestID := newRecordID()
+	responseID := newRecordID()
+
+	// 跟随重定向后方法不变时（307、308）请求体也会重新发送
+	var body []byte
+	if ex.Request.Method == ex.PageRequest.Method {
+		body = ex.PageRequest.Body
+	}
+	request := httpRequestBlock(ex.Request, body)
+	response := httpResponseBlock(ex.Response, ex.Body)
+
+	// 元数据记录把归档的响应关联回页面URL、请求键和重定向链
+	var meta strings.Builder
+	fmt.Fprintf(&meta, "page-url: %s\r\n", ex.URL)
+	if !ex.PageRequest.IsGet() {
+		fmt.Fprintf(&meta, "request-key: %s\r\n", ReplayKey(ex.URL, ex.PageRequest))
+	}
+	for _, u := range ex.Redirects {
+		fmt.Fprintf(&meta, "redirect: %s\r\n", u)
+	}
+	fmt.Fprintf(&meta, "status: %d\r\n", ex.Response.StatusCode)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("WARC写入器已关闭")
+	}
+	if w.options.MaxSize > 0 && w.size >= w.options.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	records := []struct {
+		headers [][2]string
+		block   []byte
+	}{
+		{[][2]string{
+			{"WARC-Type", WARCResponse},
+			{"WARC-Record-ID", responseID},
+			{"WARC-Date", date},
+			{"WARC-Target-URI", target},
+			{"WARC-Warcinfo-ID", w.infoID},
+			{"WARC-Concurrent-To", requestID},
+			{"WARC-Payload-Digest", digest(ex.Body)},
+			{"Content-Type", "application/http;msgtype=response"},
+		}, response},
+		{[][2]string{
+			{"WARC-Type", WARCRequest},
+			{"WARC-Record-ID", requestID},
+			{"WARC-Date", date},
+			{"WARC-Target-URI", target},
+			{"WARC-Warcinfo-ID", w.infoID},
+			{"WARC-Concurrent-To", responseID},
+			{"Content-Type", "application/http;msgtype=request"},
+		}, request},
+		{[][2]string{
+			{"WARC-Type", WARCMetadata},
+			{"WARC-Record-ID", newRecordID()},
+			{"WARC-Date", date},
+			{"WARC-Target-URI", target},
+			{"WARC-Warcinfo-ID", w.infoID},
+			{"WARC-Concurrent-To", responseID},
+			{"Content-Type", "application/warc-fields"},
+		}, []byte(meta.String())},
+	}
+
+	for _, r := range records {
+		if err := w.writeRecord(r.headers, r.block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filename 返回当前正在写入的文件
+func (w *WARCWriter) Filename() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.filename
+}
+
+// Close 关闭当前文件
+func (w *WARCWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotate 关闭当前文件并打开新文件，写入 warcinfo 记录，调用方需持有锁
+func (w *WARCWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("关闭WARC文件失败: %w", err)
+		}
+		w.file = nil
+	}
+
+	w.serial++
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.options.Prefix,
+		time.Now().UTC().Format("20060102150405"), w.serial)
+	path := filepath.Join(w.options.Dir, name)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("创建WARC文件失败: %w", err)
+	}
+	w.file = file
+	w.size = 0
+	w.filename = path
+	w.infoID = newRecordID()
+
+	info := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\n"+
+		"conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n",
+		w.options.Software)
+	return w.writeRecord([][2]string{
+		{"WARC-Type", WARCInfo},
+		{"WARC-Record-ID", w.infoID},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339Nano)},
+		{"WARC-Filename", name},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(info))
+}
+
+// writeRecord 写入一条单独gzip压缩的记录，调用方需持有锁
+func (w *WARCWriter) writeRecord(headers [][2]string, block []byte) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+
+	fmt.Fprint(gz, "WARC/1.1\r\n")
+	for _, h := range headers {
+		fmt.Fprintf(gz, "%s: %s\r\n", h[0], h[1])
+	}
+	fmt.Fprintf(gz, "WARC-Block-Digest: %s\r\n", digest(block))
+	fmt.Fprintf(gz, "Content-Length: %d\r\n\r\n", len(block))
+	gz.Write(block)
+	fmt.Fprint(gz, "\r\n\r\n")
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("压缩WARC记录失败: %w", err)
+	}
+
+	n, err := w.file.Write(buf.Bytes())
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("写入WARC记录失败: %w", err)
+	}
+	return nil
+}
+
+// httpRequestBlock 还原HTTP请求报文，包括请求体，凭据类请求头会被隐藏
+func httpRequestBlock(req *http.Request, body []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	fmt.Fprintf(&buf, "Host: %s\r\n", req.URL.Host)
+
+	header := req.Header.Clone()
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, "******")
+		}
+	}
+	if len(body) > 0 {
+		header.Set("Content-Length", fmt.Sprint(len(body)))
+	}
+	writeHeader(&buf, header)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// httpResponseBlock 还原HTTP响应报文。
+// 响应体已被解除分块和压缩，因此去掉对应的头并按实际长度设置 Content-Length
+func httpResponseBlock(resp *http.Response, body []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+
+	header := resp.Header.Clone()
+	header.Del("Transfer-Encoding")
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", fmt.Sprint(len(body)))
+	writeHeader(&buf, header)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// writeHeader 按名称排序写出报文头
+func writeHeader(buf *bytes.Buffer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range header[name] {
+			fmt.Fprintf(buf, "%s: %s\r\n", name, v)
+		}
+	}
+}
+
+// digest 返回WARC格式的SHA-1摘要
+func digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// newRecordID 生成 urn:uuid 格式的随机记录ID
+func newRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
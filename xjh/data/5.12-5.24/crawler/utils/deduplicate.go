@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"sync"
+)
+
+// 每次扩容时新过滤器的容量倍数和误判率收紧系数
+const (
+	bloomGrowth     = 2
+	bloomTightening = 0.5
+)
+
+// bloomFilter 固定容量的布隆过滤器
+type bloomFilter struct {
+	Bits     []uint64
+	M        uint64
+	K        uint64
+	Capacity uint64
+	Count    uint64
+}
+
+// newBloomFilter 按容量和误判率计算位数组大小和哈希函数个数
+func newBloomFilter(capacity uint64, fpRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{
+		Bits:     make([]uint64, (m+63)/64),
+		M:        m,
+		K:        k,
+		Capacity: capacity,
+	}
+}
+
+// test 检查元素是否可能存在
+func (f *bloomFilter) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.K; i++ {
+		pos := (h1 + i*h2) % f.M
+		if f.Bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add 添加元素
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.K; i++ {
+		pos := (h1 + i*h2) % f.M
+		f.Bits[pos/64] |= 1 << (pos % 64)
+	}
+	f.Count++
+}
+
+// BloomChecker 是基于可扩展布隆过滤器的URL去重器。
+// 内存占用远小于 SimpleChecker，代价是有一定概率把新URL误判为已爬取
+type BloomChecker struct {
+	// 初始容量
+	capacity uint64
+
+	// 目标误判率
+	fpRate float64
+
+	// 过滤器链，写满后追加一个更大、误判率更低的过滤器
+	filters []*bloomFilter
+
+	mu sync.RWMutex
+}
+
+// NewBloomChecker 创建一个新的布隆过滤器去重器
+func NewBloomChecker(capacity uint64, fpRate float64) *BloomChecker {
+	if capacity == 0 {
+		capacity = 1 << 20
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+
+	c := &BloomChecker{
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+	c.reset()
+	return c
+}
+
+// reset 重建第一个过滤器，调用方需持有锁
+func (c *BloomChecker) reset() {
+	// 各级误判率按几何级数收紧，使总误判率不超过 fpRate
+	c.filters = []*bloomFilter{newBloomFilter(c.capacity, c.fpRate*(1-bloomTightening))}
+}
+
+// IsDuplicate 检查URL是否已存在
+func (c *BloomChecker) IsDuplicate(url string) bool {
+	h1, h2 := bloomHash(url)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.contains(h1, h2)
+}
+
+// MarkAsDuplicate 标记URL为已存在
+func (c *BloomChecker) MarkAsDuplicate(url string) {
+	h1, h2 := bloomHash(url)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.contains(h1, h2) {
+		return
+	}
+	c.current().add(h1, h2)
+}
+
+// Clear 清空去重器
+func (c *BloomChecker) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reset()
+}
+
+// Count 返回已标记的URL数量（近似值）
+func (c *BloomChecker) Count() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var n uint64
+	for _, f := range c.filters {
+		n += f.Count
+	}
+	return n
+}
+
+// contains 在所有过滤器中查找，调用方需持有锁
+func (c *BloomChecker) contains(h1, h2 uint64) bool {
+	for _, f := range c.filters {
+		if f.test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// current 返回可写入的过滤器，当前过滤器写满时自动扩容，调用方需持有写锁
+func (c *BloomChecker) current() *bloomFilter {
+	last := c.filters[len(c.filters)-1]
+	if last.Count < last.Capacity {
+		return last
+	}
+
+	fpRate := c.fpRate * (1 - bloomTightening) * math.Pow(bloomTightening, float64(len(c.filters)))
+	next := newBloomFilter(last.Capacity*bloomGrowth, fpRate)
+	c.filters = append(c.filters, next)
+	return next
+}
+
+// bloomSnapshot 是布隆过滤器去重器的持久化格式
+type bloomSnapshot struct {
+	Capacity uint64
+	FPRate   float64
+	Filters  []*bloomFilter
+}
+
+// SaveToFile 将去重器状态保存到文件
+func (c *BloomChecker) SaveToFile(filename string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// 创建文件
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer file.Close()
+
+	snapshot := bloomSnapshot{
+		Capacity: c.capacity,
+		FPRate:   c.fpRate,
+		Filters:  c.filters,
+	}
+	if err := gob.NewEncoder(file).Encode(&snapshot); err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFromFile 从文件加载去重器状态
+func (c *BloomChecker) LoadFromFile(filename string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 打开文件
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var snapshot bloomSnapshot
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return fmt.Errorf("解码数据失败: %w", err)
+	}
+	if err := snapshot.validate(); err != nil {
+		return fmt.Errorf("解码数据失败: %w", err)
+	}
+
+	c.capacity = snapshot.Capacity
+	c.fpRate = snapshot.FPRate
+	c.filters = snapshot.Filters
+	return nil
+}
+
+// validate 检查快照是否完整，损坏的文件不能在查询时越界或除零
+func (s *bloomSnapshot) validate() error {
+	if s.Capacity == 0 || !(s.FPRate > 0 && s.FPRate < 1) {
+		return fmt.Errorf("无效的容量 %d 或误判率 %v", s.Capacity, s.FPRate)
+	}
+	if len(s.Filters) == 0 {
+		return fmt.Errorf("没有过滤器")
+	}
+	for i, f := range s.Filters {
+		if f == nil {
+			return fmt.Errorf("第 %d 个过滤器为空", i+1)
+		}
+		if f.M == 0 || f.K == 0 || f.Capacity == 0 {
+			return fmt.Errorf("第 %d 个过滤器的参数无效: M=%d K=%d 容量=%d", i+1, f.M, f.K, f.Capacity)
+		}
+		if uint64(len(f.Bits)) != (f.M+63)/64 {
+			return fmt.Errorf("第 %d 个过滤器的位数组长度为 %d，期望 %d", i+1, len(f.Bits), (f.M+63)/64)
+		}
+	}
+	return nil
+}
+
+// bloomHash 计算双重哈希所需的两个哈希值
+func bloomHash(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	h1 := h.Sum64()
+
+	h.Write([]byte{0xff})
+	h2 := h.Sum64() | 1 // 保证为奇数，避免步长为0
+
+	return h1, h2
+}
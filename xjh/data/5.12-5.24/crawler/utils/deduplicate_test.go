@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSnapshot 把快照编码到临时文件
+func writeSnapshot(t *testing.T, snapshot *bloomSnapshot) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "bloom.gob")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+	if err := gob.NewEncoder(file).Encode(snapshot); err != nil {
+		t.Fatalf("编码快照失败: %v", err)
+	}
+	return filename
+}
+
+func TestBloomCheckerLoadFromFile(t *testing.T) {
+	checker := NewBloomChecker(100, 0.01)
+	checker.MarkAsDuplicate("http://example.com/a")
+	filename := filepath.Join(t.TempDir(), "bloom.gob")
+	if err := checker.SaveToFile(filename); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	loaded := NewBloomChecker(100, 0.01)
+	if err := loaded.LoadFromFile(filename); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if !loaded.IsDuplicate("http://example.com/a") {
+		t.Error("加载后没有找到已标记的URL")
+	}
+
+	// 损坏的快照返回错误，去重器保持原状
+	valid := func() *bloomFilter { return newBloomFilter(100, 0.01) }
+	tests := map[string]func(f *bloomFilter){
+		"位数组被截断": func(f *bloomFilter) { f.Bits = f.Bits[:1] },
+		"M为0":    func(f *bloomFilter) { f.M = 0 },
+		"K为0":    func(f *bloomFilter) { f.K = 0 },
+		"容量为0":   func(f *bloomFilter) { f.Capacity = 0 },
+	}
+	for name, corrupt := range tests {
+		f := valid()
+		corrupt(f)
+		filename := writeSnapshot(t, &bloomSnapshot{Capacity: 100, FPRate: 0.01, Filters: []*bloomFilter{f}})
+		if err := loaded.LoadFromFile(filename); err == nil {
+			t.Errorf("%s: 加载损坏的快照没有返回错误", name)
+		}
+	}
+	filename = writeSnapshot(t, &bloomSnapshot{Capacity: 100, FPRate: 0, Filters: []*bloomFilter{valid()}})
+	if err := loaded.LoadFromFile(filename); err == nil {
+		t.Error("误判率为0的快照没有返回错误")
+	}
+	if !loaded.IsDuplicate("http://example.com/a") {
+		t.Error("加载失败后去重器的状态被修改")
+	}
+}
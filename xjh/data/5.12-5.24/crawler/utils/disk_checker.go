@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// diskRecordSize 每条日志记录的长度：8字节URL哈希 + 8字节标记时间（UnixNano）
+const diskRecordSize = 16
+
+// DiskChecker 是持久化到磁盘的URL去重器。
+// 每次标记都追加一条 (URL哈希, 时间) 记录到日志文件，启动时重放日志重建内存索引，
+// 因此同一爬取任务再次运行时不会重新抓取已访问的URL。
+// 设置TTL后，标记时间超过TTL的URL会重新变为可爬取
+type DiskChecker struct {
+	// 日志文件路径
+	path string
+
+	// 过期时间，0表示永不过期
+	ttl time.Duration
+
+	// URL哈希 -> 最近一次标记的时间（UnixNano）
+	index map[uint64]int64
+
+	file   *os.File
+	writer *bufio.Writer
+
+	// 追加日志记录时的第一个错误，由 Flush 和 Close 报告
+	err error
+
+	mu sync.Mutex
+}
+
+// NewDiskChecker 打开或创建磁盘去重器，并从日志文件中恢复索引
+func NewDiskChecker(path string, ttl time.Duration) (*DiskChecker, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	c := &DiskChecker{
+		path:  path,
+		ttl:   ttl,
+		index: make(map[uint64]int64),
+		file:  file,
+	}
+
+	if err := c.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// 定位到文件末尾继续追加
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("定位文件失败: %w", err)
+	}
+	c.writer = bufio.NewWriter(file)
+
+	return c, nil
+}
+
+// load 重放日志文件，重建内存索引
+func (c *DiskChecker) load() error {
+	reader := bufio.NewReader(c.file)
+	record := make([]byte, diskRecordSize)
+
+	for {
+		_, err := io.ReadFull(reader, record)
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// 上次运行在写入中途退出，忽略不完整的尾部记录
+			return c.file.Truncate(c.offset(reader))
+		}
+		if err != nil {
+			return fmt.Errorf("读取日志失败: %w", err)
+		}
+
+		hash := binary.LittleEndian.Uint64(record[0:8])
+		marked := int64(binary.LittleEndian.Uint64(record[8:16]))
+		c.index[hash] = marked
+	}
+}
+
+// offset 返回最后一条完整记录的结束位置
+func (c *DiskChecker) offset(reader *bufio.Reader) int64 {
+	pos, err := c.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	pos -= int64(reader.Buffered())
+	return pos - pos%diskRecordSize
+}
+
+// IsDuplicate 检查URL是否已存在且未过期
+func (c *DiskChecker) IsDuplicate(url string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.seen(diskHash(url), time.Now())
+}
+
+// MarkAsDuplicate 标记URL为已存在
+func (c *DiskChecker) MarkAsDuplicate(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mark(diskHash(url), time.Now())
+}
+
+// Clear 清空去重器，同时清空日志文件
+func (c *DiskChecker) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.index = make(map[uint64]int64)
+	c.err = nil
+	c.writer.Reset(c.file)
+	if err := c.file.Truncate(0); err == nil {
+		c.file.Seek(0, io.SeekStart)
+	}
+}
+
+// Len 返回索引中的URL数量（包括已过期的）
+func (c *DiskChecker) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.index)
+}
+
+// Compact 重写日志文件，只保留每个URL最新且未过期的记录
+func (c *DiskChecker) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	tmpPath := c.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for hash, marked := range c.index {
+		if c.ttl > 0 && now.Sub(time.Unix(0, marked)) >= c.ttl {
+			delete(c.index, hash)
+			continue
+		}
+		if _, err := writer.Write(diskRecord(hash, marked)); err != nil {
+			tmp.Close()
+			return fmt.Errorf("写入日志失败: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入日志失败: %w", err)
+	}
+	tmp.Close()
+
+	// 用新文件替换旧日志
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("写入日志失败: %w", err)
+	}
+	c.file.Close()
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("替换日志失败: %w", err)
+	}
+
+	file, err := os.OpenFile(c.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	c.file = file
+	c.writer = bufio.NewWriter(file)
+	// 新日志由内存索引重写，之前写入失败的记录已经补齐
+	c.err = nil
+	return nil
+}
+
+// Flush 将缓冲的记录写入磁盘
+func (c *DiskChecker) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("写入日志失败: %w", err)
+	}
+	return c.file.Sync()
+}
+
+// Close 刷新缓冲并关闭日志文件，返回之前追加记录或刷新时的错误
+func (c *DiskChecker) Close() error {
+	flushErr := c.Flush()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 写入失败时仍关闭文件，返回写入的错误
+	if err := c.file.Close(); err != nil && flushErr == nil {
+		return err
+	}
+	return flushErr
+}
+
+// seen 检查哈希是否存在且未过期，调用方需持有锁
+func (c *DiskChecker) seen(hash uint64, now time.Time) bool {
+	marked, ok := c.index[hash]
+	if !ok {
+		return false
+	}
+	return c.ttl <= 0 || now.Sub(time.Unix(0, marked)) < c.ttl
+}
+
+// mark 更新索引并追加日志记录，调用方需持有锁
+func (c *DiskChecker) mark(hash uint64, now time.Time) {
+	c.index[hash] = now.UnixNano()
+	// 写入失败时仍保留内存中的标记，记录第一个错误由Flush报告，Compact会按内存索引重写日志
+	if _, err := c.writer.Write(diskRecord(hash, now.UnixNano())); err != nil && c.err == nil {
+		c.err = fmt.Errorf("写入日志失败: %w", err)
+	}
+}
+
+// diskRecord 编码一条日志记录
+func diskRecord(hash uint64, marked int64) []byte {
+	record := make([]byte, diskRecordSize)
+	binary.LittleEndian.PutUint64(record[0:8], hash)
+	binary.LittleEndian.PutUint64(record[8:16], uint64(marked))
+	return record
+}
+
+// diskHash 计算URL的64位哈希
+func diskHash(url string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(url))
+	return h.Sum64()
+}